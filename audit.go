@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Every mutating handler, login and logout append an entry to the audit log.
+// The change has already been made when the entry is written, so a failure
+// to record it is logged instead of failing the request.
+
+// recordAudit records an action of the authenticated user. before and after
+// are stored as JSON; pass nil when the action has no such state.
+func recordAudit(r *http.Request, action, entityType string, entityID int, before, after interface{}) {
+	entry := AuditEntry{Action: action, EntityType: entityType}
+	if user, ok := currentUser(r); ok {
+		entry.ActorID, entry.ActorUsername = &user.ID, user.Username
+	}
+	if entityID != 0 {
+		entry.EntityID = &entityID
+	}
+	writeAudit(r, entry, before, after)
+}
+
+// writeAudit adds the client address and the before/after state to entry and stores it.
+func writeAudit(r *http.Request, entry AuditEntry, before, after interface{}) {
+	entry.IPAddress, entry.UserAgent = clientIP(r), r.UserAgent()
+
+	var err error
+	if entry.Before, err = auditJSON(before); err == nil {
+		entry.After, err = auditJSON(after)
+	}
+	if err == nil {
+		err = auditStore.RecordAudit(r.Context(), entry)
+	}
+	if err != nil {
+		fmt.Printf("Error writing audit log (%s %s): %v\n", entry.Action, entry.EntityType, err)
+	}
+}
+
+func auditJSON(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
+
+// auditState is the state to log for a store lookup, or nil if it failed.
+func auditState[T any](value T, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// auditCatalogEntry returns the current catalog entry of the given type for
+// the audit log, or nil if it does not exist.
+func auditCatalogEntry(ctx context.Context, entityType string, id int) interface{} {
+	switch entityType {
+	case "site":
+		sites, _ := catalogStore.ListSites(ctx)
+		for _, site := range sites {
+			if site.ID == id {
+				return site
+			}
+		}
+	case "shift_hours":
+		shifts, _ := catalogStore.ListShiftHours(ctx)
+		for _, shift := range shifts {
+			if shift.ID == id {
+				return shift
+			}
+		}
+	case "event_title":
+		titles, _ := catalogStore.ListEventTitles(ctx)
+		for _, title := range titles {
+			if title.ID == id {
+				return title
+			}
+		}
+	case "severity":
+		severities, _ := catalogStore.ListSeverities(ctx)
+		for _, severity := range severities {
+			if severity.ID == id {
+				return severity
+			}
+		}
+	case "event_category":
+		categories, _ := catalogStore.ListEventCategories(ctx)
+		for _, category := range categories {
+			if category.ID == id {
+				return category
+			}
+		}
+	case "affected_service":
+		services, _ := catalogStore.ListAffectedServices(ctx)
+		for _, service := range services {
+			if service.ID == id {
+				return service
+			}
+		}
+	case "health_check_item":
+		items, _ := catalogStore.ListHealthCheckItems(ctx)
+		for _, item := range items {
+			if item.ID == id {
+				return item
+			}
+		}
+	}
+	return nil
+}
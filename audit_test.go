@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAuditReportChanges(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	ctx := context.Background()
+	author := sessionUser{ID: 1, Username: "admin", Role: "user"}
+
+	r := testRequest(t, http.MethodPost, testReport("2024-03-01"), author, nil)
+	r.Header.Set("User-Agent", "report-form")
+	w := httptest.NewRecorder()
+	createReportHandler(w, r)
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("got %d (%v), want the report created", w.Code, err)
+	}
+	w = httptest.NewRecorder()
+	deleteReportHandler(w, testRequest(t, http.MethodDelete, nil, author, map[string]string{"id": strconv.Itoa(created.ID)}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want the report deleted", w.Code, w.Body.String())
+	}
+
+	entries, total, err := s.ListAudit(ctx, AuditFilter{EntityType: "report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(entries) != 2 {
+		t.Fatalf("got %d entries %+v, want the create and the delete", total, entries)
+	}
+	deleted, create := entries[0], entries[1] // newest first
+	if create.Action != "create" || create.ActorID == nil || *create.ActorID != 1 || create.ActorUsername != "admin" ||
+		create.EntityID == nil || *create.EntityID != created.ID || create.IPAddress != "192.0.2.1" || create.UserAgent != "report-form" {
+		t.Errorf("got create entry %+v", create)
+	}
+	var after DailyReport
+	if err := json.Unmarshal(create.After, &after); err != nil || after.ID != created.ID || create.Before != nil {
+		t.Errorf("create entry has before %s and after %s, want only the new report", create.Before, create.After)
+	}
+	if deleted.Action != "delete" || deleted.Before == nil || deleted.After != nil {
+		t.Errorf("got delete entry %s with before %s and after %s, want only the deleted report", deleted.Action, deleted.Before, deleted.After)
+	}
+
+	// Entries are append-only
+	if _, err := s.db.Exec("UPDATE audit_log SET action = 'view'"); err == nil {
+		t.Error("audit log entries can be changed")
+	}
+	if _, err := s.db.Exec("DELETE FROM audit_log"); err == nil {
+		t.Error("audit log entries can be deleted")
+	}
+}
+
+func TestAuditUserPasswordChange(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	useTestSessions(s)
+	admin := sessionUser{ID: 1, Username: "admin", Role: "admin"}
+	id, err := s.CreateUser(context.Background(), User{Username: "operator", FullName: "Operator", Role: "user"}, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]string{"username": "operator", "full_name": "Night Operator", "role": "user", "password": "s3cret-pass"}
+	w := httptest.NewRecorder()
+	updateUserHandler(w, testRequest(t, http.MethodPut, body, admin, map[string]string{"id": strconv.Itoa(id)}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want the user updated", w.Code, w.Body.String())
+	}
+
+	entries, _, err := s.ListAudit(context.Background(), AuditFilter{EntityType: "user", EntityID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want the update", len(entries))
+	}
+	after := string(entries[0].After)
+	if !strings.Contains(after, `"password_changed":true`) || !strings.Contains(after, "Night Operator") || strings.Contains(after, "s3cret-pass") {
+		t.Errorf("got after %s, want the new name and password_changed without the password", after)
+	}
+}
+
+func TestExportAuditLogHandler(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	ctx := context.Background()
+	actorID, reportID := 1, 7
+	entries := []AuditEntry{
+		{ActorID: &actorID, ActorUsername: "admin", Action: "login", EntityType: "session", IPAddress: "192.0.2.1"},
+		{ActorID: &actorID, ActorUsername: "admin", Action: "update", EntityType: "report", EntityID: &reportID,
+			Before: json.RawMessage(`{"status":"draft","comment":"a, \"quoted\" value"}`), After: json.RawMessage(`{"status":"submitted"}`)},
+		{Action: "login_failed", EntityType: "session", IPAddress: "198.51.100.7", UserAgent: "curl/8"},
+	}
+	for _, entry := range entries {
+		if err := s.RecordAudit(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string // actions, newest first
+	}{
+		{"", []string{"login_failed", "update", "login"}},
+		{"?entity_type=session", []string{"login_failed", "login"}},
+		{"?actor_id=1&action=update", []string{"update"}},
+		{"?limit=1&offset=1", []string{"login_failed", "update", "login"}}, // the export is never paged
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			exportAuditLogHandler(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit-log/export"+tt.query, nil))
+			if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+				t.Fatalf("got %d %s, want CSV", w.Code, w.Header().Get("Content-Type"))
+			}
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(records[0], ",") != "id,occurred_at,actor_id,actor_username,action,entity_type,entity_id,before,after,ip_address,user_agent" {
+				t.Errorf("got header %q", records[0])
+			}
+			var got []string
+			for _, record := range records[1:] {
+				got = append(got, record[4])
+				if record[4] == "update" && (record[6] != "7" || record[7] != `{"status":"draft","comment":"a, \"quoted\" value"}`) {
+					t.Errorf("got update row %q, want report 7 with its state as JSON", record)
+				}
+				if record[4] == "login_failed" && (record[2] != "" || record[9] != "198.51.100.7" || record[10] != "curl/8") {
+					t.Errorf("got failed login row %q, want no actor, the client address and agent", record)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got actions %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	exportAuditLogHandler(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit-log/export?date_from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got %d for an invalid date, want 400", w.Code)
+	}
+}
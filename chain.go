@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locking a report seals it into a hash chain. A link holds the SHA-256 of
+// the report's canonical form and of the previous link, and is signed with the
+// server's Ed25519 key, so changing a sealed report or rewriting the chain
+// without the key shows up when the chain is verified.
+
+// genesisHash is the previous hash of the first link.
+var genesisHash = strings.Repeat("0", 64)
+
+// canonicalVersion is the version of the canonical form new links are sealed
+// with. Version 1 had the three health check booleans the checklist replaced.
+const canonicalVersion = 2
+
+// canonicalReport is what a seal covers: the report's own data, referring to
+// users and catalog entries by ID so that renaming them does not break it.
+type canonicalReport struct {
+	ID              int                     `json:"id"`
+	ReportDate      string                  `json:"report_date"`
+	SiteID          *int                    `json:"site_id"`
+	ShiftHoursID    *int                    `json:"shift_hours_id"`
+	ShiftManagerIDs []int                   `json:"shift_manager_ids"`
+	EventTitleIDs   []int                   `json:"event_title_ids"`
+	HealthChecks    []canonicalHealthResult `json:"health_checks"`
+	EventsPart3     []canonicalEventPart3   `json:"events_part3"`
+	EventsPart4     []canonicalEventPart4   `json:"events_part4"`
+	CreatedBy       int                     `json:"created_by"`
+	CreatedAt       string                  `json:"created_at"`
+	Status          string                  `json:"status"`
+	StatusComment   string                  `json:"status_comment"`
+}
+
+// The canonical forms of events and health check results are frozen: they
+// must marshal to the same JSON as when the first reports were sealed, so
+// they do not follow the API types. A new field needs a new canonical
+// version. ID is always 0; seals have included it from the start.
+
+type canonicalEventPart3 struct {
+	ID                int    `json:"id"`
+	EventSummary      string `json:"event_summary"`
+	Trigger           string `json:"trigger_info"`
+	StartTime         string `json:"start_time"`
+	EndTime           string `json:"end_time"`
+	RCANumber         string `json:"rca_number"`
+	SeverityID        *int   `json:"severity_id,omitempty"`
+	CategoryID        *int   `json:"category_id,omitempty"`
+	AffectedServiceID *int   `json:"affected_service_id,omitempty"`
+}
+
+type canonicalEventPart4 struct {
+	ID                int    `json:"id"`
+	EventSummary      string `json:"event_summary"`
+	Trigger           string `json:"trigger_info"`
+	StartTime         string `json:"start_time"`
+	EndTime           string `json:"end_time"`
+	SeverityID        *int   `json:"severity_id,omitempty"`
+	CategoryID        *int   `json:"category_id,omitempty"`
+	AffectedServiceID *int   `json:"affected_service_id,omitempty"`
+}
+
+type canonicalHealthResult struct {
+	ItemID        int      `json:"item_id"`
+	Status        string   `json:"status"`
+	Comment       string   `json:"comment,omitempty"`
+	ReadingNumber *float64 `json:"reading_number,omitempty"`
+	ReadingText   string   `json:"reading_text,omitempty"`
+	ReadingUnit   string   `json:"reading_unit,omitempty"`
+}
+
+// canonicalReportV1 is version 1 of the canonical form.
+type canonicalReportV1 struct {
+	ID                 int                   `json:"id"`
+	ReportDate         string                `json:"report_date"`
+	SiteID             *int                  `json:"site_id"`
+	ShiftHoursID       *int                  `json:"shift_hours_id"`
+	ShiftManagerIDs    []int                 `json:"shift_manager_ids"`
+	EventTitleIDs      []int                 `json:"event_title_ids"`
+	HealthPowerSources bool                  `json:"health_power_sources"`
+	HealthHumidityTemp bool                  `json:"health_humidity_temp"`
+	HealthFireSystem   bool                  `json:"health_fire_system"`
+	EventsPart3        []canonicalEventPart3 `json:"events_part3"`
+	EventsPart4        []canonicalEventPart4 `json:"events_part4"`
+	CreatedBy          int                   `json:"created_by"`
+	CreatedAt          string                `json:"created_at"`
+	Status             string                `json:"status"`
+	StatusComment      string                `json:"status_comment"`
+}
+
+// v1 turns the report back into version 1. The migration to the checklist
+// made the booleans items 1 to 3, a ticked box an ok result. It made no
+// warnings, so a report with one has changed since it was sealed.
+func (c canonicalReport) v1() (canonicalReportV1, error) {
+	passed := make(map[int]bool, len(c.HealthChecks))
+	for _, result := range c.HealthChecks {
+		if result.Status == healthWarning {
+			return canonicalReportV1{}, fmt.Errorf("health check item %d has a warning, which version 1 cannot hold", result.ItemID)
+		}
+		passed[result.ItemID] = result.Status == healthOK
+	}
+	return canonicalReportV1{
+		ID:                 c.ID,
+		ReportDate:         c.ReportDate,
+		SiteID:             c.SiteID,
+		ShiftHoursID:       c.ShiftHoursID,
+		ShiftManagerIDs:    c.ShiftManagerIDs,
+		EventTitleIDs:      c.EventTitleIDs,
+		HealthPowerSources: passed[legacyHealthItems[0].ItemID],
+		HealthHumidityTemp: passed[legacyHealthItems[1].ItemID],
+		HealthFireSystem:   passed[legacyHealthItems[2].ItemID],
+		EventsPart3:        c.EventsPart3,
+		EventsPart4:        c.EventsPart4,
+		CreatedBy:          c.CreatedBy,
+		CreatedAt:          c.CreatedAt,
+		Status:             c.Status,
+		StatusComment:      c.StatusComment,
+	}, nil
+}
+
+// reportContentHash returns the hex SHA-256 of the report's canonical JSON in
+// the given version of the canonical form.
+func reportContentHash(report DailyReport, version int) (string, error) {
+	input := revisionInput(report)
+	canonical := canonicalReport{
+		ID:              report.ID,
+		ReportDate:      input.ReportDate,
+		SiteID:          input.SiteID,
+		ShiftHoursID:    input.ShiftHoursID,
+		ShiftManagerIDs: append([]int{}, input.ShiftManagerIDs...),
+		EventTitleIDs:   append([]int{}, input.EventTitleIDs...),
+		HealthChecks:    []canonicalHealthResult{},
+		EventsPart3:     []canonicalEventPart3{},
+		EventsPart4:     []canonicalEventPart4{},
+		CreatedBy:       report.CreatedBy.ID,
+		CreatedAt:       report.CreatedAt,
+		Status:          report.Status,
+		StatusComment:   report.StatusComment,
+	}
+	sort.Ints(canonical.ShiftManagerIDs)
+	sort.Ints(canonical.EventTitleIDs)
+	for _, result := range report.HealthChecks {
+		canonical.HealthChecks = append(canonical.HealthChecks, canonicalHealthResult{
+			ItemID:        result.ItemID,
+			Status:        result.Status,
+			Comment:       result.Comment,
+			ReadingNumber: result.ReadingNumber,
+			ReadingText:   result.ReadingText,
+			ReadingUnit:   result.ReadingUnit,
+		})
+	}
+	sort.Slice(canonical.HealthChecks, func(i, j int) bool { return canonical.HealthChecks[i].ItemID < canonical.HealthChecks[j].ItemID })
+	// Event IDs are not content. An RCA is covered by its number, which never
+	// changes. Incidents can still be put together after a report is locked,
+	// so they are left out. Open follows from the end time. Catalog entries
+	// can be renamed, so only their IDs count. Times are read with the site's
+	// offset, which is not content.
+	for _, event := range report.EventsPart3 {
+		canonical.EventsPart3 = append(canonical.EventsPart3, canonicalEventPart3{
+			EventSummary:      event.EventSummary,
+			Trigger:           event.Trigger,
+			StartTime:         storedTimestamp(event.StartTime),
+			EndTime:           storedTimestamp(event.EndTime),
+			RCANumber:         event.RCANumber,
+			SeverityID:        event.SeverityID,
+			CategoryID:        event.CategoryID,
+			AffectedServiceID: event.AffectedServiceID,
+		})
+	}
+	for _, event := range report.EventsPart4 {
+		canonical.EventsPart4 = append(canonical.EventsPart4, canonicalEventPart4{
+			EventSummary:      event.EventSummary,
+			Trigger:           event.Trigger,
+			StartTime:         storedTimestamp(event.StartTime),
+			EndTime:           storedTimestamp(event.EndTime),
+			SeverityID:        event.SeverityID,
+			CategoryID:        event.CategoryID,
+			AffectedServiceID: event.AffectedServiceID,
+		})
+	}
+
+	var value interface{} = canonical
+	switch version {
+	case 1:
+		var err error
+		if value, err = canonical.v1(); err != nil {
+			return "", err
+		}
+	case canonicalVersion:
+	default:
+		return "", fmt.Errorf("unknown canonical form version %d", version)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// contentMatches checks the report against the content hash of its link, in
+// the version of the canonical form the link was sealed with.
+func contentMatches(report DailyReport, link ChainLink) bool {
+	hash, err := reportContentHash(report, link.Version)
+	return err == nil && hash == link.ContentHash
+}
+
+// chainLinkHash is the hash a link is signed with and the next link refers to.
+func chainLinkHash(seq, reportID int, contentHash, prevHash string) string {
+	sum := sha256.Sum256([]byte(strconv.Itoa(seq) + "\n" + strconv.Itoa(reportID) + "\n" + contentHash + "\n" + prevHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// newChainLink builds and signs the link sealing report after prev (nil for the first link).
+func newChainLink(key ed25519.PrivateKey, prev *ChainLink, report DailyReport) (ChainLink, error) {
+	if key == nil {
+		return ChainLink{}, errors.New("no chain signing key loaded")
+	}
+	link := ChainLink{Seq: 1, ReportID: report.ID, PrevHash: genesisHash, Version: canonicalVersion}
+	if prev != nil {
+		link.Seq, link.PrevHash = prev.Seq+1, prev.Hash
+	}
+	var err error
+	if link.ContentHash, err = reportContentHash(report, link.Version); err != nil {
+		return link, err
+	}
+	link.Hash = chainLinkHash(link.Seq, link.ReportID, link.ContentHash, link.PrevHash)
+	link.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(link.Hash)))
+	return link, nil
+}
+
+// verifyChain checks links in order against the current reports and names
+// every report or link that no longer matches. unsealed lists locked reports
+// missing from the chain.
+func verifyChain(links []ChainLink, reports map[int]DailyReport, unsealed []int, key ed25519.PublicKey) ChainVerification {
+	result := ChainVerification{Links: len(links), PublicKey: base64.StdEncoding.EncodeToString(key), Problems: []ChainProblem{}}
+	problem := func(link ChainLink, format string, args ...interface{}) {
+		result.Problems = append(result.Problems, ChainProblem{Seq: link.Seq, ReportID: link.ReportID, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	prevHash := genesisHash
+	for i, link := range links {
+		if link.Seq != i+1 {
+			problem(link, "expected link %d, links are missing", i+1)
+		}
+		if link.PrevHash != prevHash {
+			problem(link, "does not follow the previous link")
+		}
+		if chainLinkHash(link.Seq, link.ReportID, link.ContentHash, link.PrevHash) != link.Hash {
+			problem(link, "link has been modified")
+		}
+		signature, err := base64.StdEncoding.DecodeString(link.Signature)
+		if err != nil || !ed25519.Verify(key, []byte(link.Hash), signature) {
+			problem(link, "signature is not valid for the server key")
+		}
+
+		report, ok := reports[link.ReportID]
+		if !ok {
+			problem(link, "report is missing or deleted")
+		} else if !contentMatches(report, link) {
+			problem(link, "report has been modified since it was sealed")
+		}
+		prevHash = link.Hash
+	}
+
+	for _, id := range unsealed {
+		result.Problems = append(result.Problems, ChainProblem{ReportID: id, Problem: "report is locked but not sealed"})
+	}
+	result.Valid = len(result.Problems) == 0
+	return result
+}
+
+// loadChainKey reads the PEM-encoded Ed25519 signing key at path. With create
+// set, a missing key is generated and written there.
+func loadChainKey(path string, create bool) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		return createChainKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: no PEM private key found", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+func createChainKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Generated chain signing key %s; back it up, sealed reports can only be verified with it\n", path)
+	return key, nil
+}
+
+// runChainCommand implements `daily_report verify` and `daily_report seal`.
+// Locked reports are only sealed by the server as they are locked; seal is
+// for reports locked before the chain existed.
+func runChainCommand(db *sql.DB, driver, keyFile, command string) error {
+	s, err := newStore(db, driver)
+	if err != nil {
+		return err
+	}
+	if s.chainKey, err = loadChainKey(keyFile, command == "seal"); err != nil {
+		return err
+	}
+
+	if command == "seal" {
+		sealed, err := s.SealLockedReports(context.Background())
+		fmt.Printf("Sealed %d locked report(s) into the report chain\n", sealed)
+		return err
+	}
+
+	result, err := s.VerifyChain(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, p := range result.Problems {
+		if p.Seq != 0 {
+			fmt.Printf("link %d, report %d: %s\n", p.Seq, p.ReportID, p.Problem)
+		} else {
+			fmt.Printf("report %d: %s\n", p.ReportID, p.Problem)
+		}
+	}
+	if !result.Valid {
+		return fmt.Errorf("%d problem(s) in %d link(s)", len(result.Problems), result.Links)
+	}
+	fmt.Printf("Report chain intact: %d link(s) verified\n", result.Links)
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strconv"
+	"testing"
+)
+
+// Content hashes of sealedReport computed by earlier builds: version 1 before
+// the health checklist, with power sources and fire system ticked, version 2
+// with the checklist, as in sealedReport(checklistResults), and version 2 with
+// classified events and a reading, as in classifiedReport.
+const (
+	sealedHashV1           = "7e8b30873af472e70ff04a4c803fbd282049288137bf9657d863d5f5856ac76b"
+	sealedHashV2           = "2ae6da83e699d219959b13bbb4a28a58412054de16137e7ae550fea0165825bd"
+	sealedHashV2Classified = "3dab28fdb1b69aa44203e76e584ff8ceb29eed45132f5acf76f31f497702530a"
+)
+
+// What the checklist migration made of the version 1 booleans
+var migratedResults = []HealthCheckResult{
+	{ItemID: 1, Name: "Power Sources", Status: healthOK},
+	{ItemID: 2, Name: "Humidity & Temperature", Status: healthFail},
+	{ItemID: 3, Name: "Fire System", Status: healthOK},
+}
+
+var checklistResults = []HealthCheckResult{
+	{ItemID: 3, Name: "Fire System", Status: healthOK},
+	{ItemID: 1, Name: "Power Sources", Status: healthOK, Comment: "on mains"},
+	{ItemID: 2, Status: healthWarning},
+}
+
+// sealedReport is a locked report as it is read today.
+func sealedReport(healthChecks []HealthCheckResult) DailyReport {
+	rcaID := 4
+	return DailyReport{
+		ID: 7, ReportDate: "2024-03-11T00:00:00Z", Site: &Site{ID: 1, Name: "Main Site"},
+		ShiftHours:    &ShiftHours{ID: 3, Name: "Night Shift", StartTime: "22:00:00", EndTime: "06:00:00"},
+		ShiftManagers: []User{{ID: 2, Username: "operator"}, {ID: 1, Username: "admin"}},
+		EventTitles:   []EventTitle{{ID: 2, Title: "Security Check"}, {ID: 1, Title: "System Maintenance"}},
+		HealthChecks:  append([]HealthCheckResult{}, healthChecks...),
+		EventsPart3: []EventPart3{{ID: 31, EventSummary: "UPS alarm", Trigger: "monitor", StartTime: "2024-03-11T23:00:00Z",
+			EndTime: "2024-03-12T01:30:00Z", RCANumber: "RCA-7", RCAID: &rcaID}},
+		EventsPart4: []EventPart4{{ID: 32, EventSummary: "Door check", StartTime: "2024-03-11T22:30:00Z", EndTime: "2024-03-11T22:40:00Z"}},
+		CreatedBy:   User{ID: 1, Username: "admin"}, CreatedAt: "2024-03-11T21:55:00Z", Status: statusLocked, Version: 4,
+	}
+}
+
+// classifiedReport is sealedReport with classified events and a temperature
+// reading.
+func classifiedReport() DailyReport {
+	report := sealedReport(checklistResults)
+	high, power, dataHall, reading := 5, 2, 1, 27.5
+	report.EventsPart3[0].EventClassification = EventClassification{SeverityID: &high, Severity: "Site down", CategoryID: &power, Category: "Power"}
+	report.EventsPart4[0].EventClassification = EventClassification{AffectedServiceID: &dataHall, AffectedService: "Data hall"}
+	report.HealthChecks[2].ReadingNumber, report.HealthChecks[2].ReadingUnit = &reading, "°C"
+	return report
+}
+
+func TestReportContentHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  DailyReport
+		version int
+		want    string
+	}{
+		{"version 1", sealedReport(migratedResults), 1, sealedHashV1},
+		{"version 2", sealedReport(checklistResults), 2, sealedHashV2},
+		{"version 2 with classification and readings", classifiedReport(), 2, sealedHashV2Classified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reportContentHash(tt.report, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// The same report read on a site with another time zone
+	onSiteClock := sealedReport(checklistResults)
+	onSiteClock.EventsPart3[0].StartTime, onSiteClock.EventsPart3[0].EndTime = "2024-03-11T23:00:00+03:30", "2024-03-12T01:30:00+03:30"
+	onSiteClock.EventsPart4[0].StartTime, onSiteClock.EventsPart4[0].EndTime = "2024-03-11T22:30:00+03:30", "2024-03-11T22:40:00+03:30"
+	if got, err := reportContentHash(onSiteClock, 2); err != nil || got != sealedHashV2 {
+		t.Errorf("with the site offset got %s (%v), want %s", got, err, sealedHashV2)
+	}
+	if _, err := reportContentHash(sealedReport(nil), canonicalVersion+1); err == nil {
+		t.Error("unknown version accepted")
+	}
+}
+
+func TestVerifyChainAcrossUpgrades(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// link seals report 7 as the first link
+	link := func(contentHash string, version int) ChainLink {
+		link := ChainLink{Seq: 1, ReportID: 7, ContentHash: contentHash, Version: version, PrevHash: genesisHash}
+		link.Hash = chainLinkHash(link.Seq, link.ReportID, link.ContentHash, link.PrevHash)
+		link.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(link.Hash)))
+		return link
+	}
+	current, err := newChainLink(key, nil, sealedReport(checklistResults))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]HealthCheckResult{}, migratedResults...)
+	tampered[1].Status = healthOK
+	// Still not ok, as version 1 has it, but a warning did not exist then
+	warned := append([]HealthCheckResult{}, migratedResults...)
+	warned[1].Status = healthWarning
+	failed := append([]HealthCheckResult{}, checklistResults...)
+	failed[2].Status = healthFail
+
+	tests := []struct {
+		name   string
+		link   ChainLink
+		report DailyReport
+		valid  bool
+	}{
+		{"sealed with booleans", link(sealedHashV1, 1), sealedReport(migratedResults), true},
+		{"sealed with checklist", link(sealedHashV2, canonicalVersion), sealedReport(checklistResults), true},
+		{"sealed now", current, sealedReport(checklistResults), true},
+		{"changed since sealed with booleans", link(sealedHashV1, 1), sealedReport(tampered), false},
+		{"fail changed to warning since sealed with booleans", link(sealedHashV1, 1), sealedReport(warned), false},
+		{"warning changed to fail since sealed", current, sealedReport(failed), false},
+		{"changed since sealed now", current, sealedReport(migratedResults), false},
+		{"wrong version", link(sealedHashV1, canonicalVersion), sealedReport(migratedResults), false},
+		{"no version", link(sealedHashV2, 0), sealedReport(checklistResults), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := verifyChain([]ChainLink{tt.link}, map[int]DailyReport{7: tt.report}, nil, key.Public().(ed25519.PublicKey))
+			if result.Valid != tt.valid {
+				t.Errorf("got valid %v, want %v: %+v", result.Valid, tt.valid, result.Problems)
+			}
+		})
+	}
+}
+
+func TestChainVersionBackfill(t *testing.T) {
+	s := openTestStore(t)
+	ids := []int{createTestReport(t, s, testReport("2024-02-01")), createTestReport(t, s, testReport("2024-04-01"))}
+	// Back to before versions were recorded, with the checklist migrated on 2024-03-01
+	if err := migrateDown(s.db, driverSQLite, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("UPDATE schema_migrations SET applied_at = '2024-03-01 00:00:00' WHERE version = 11"); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range ids {
+		_, err := s.db.Exec(`INSERT INTO report_chain (seq, report_id, content_hash, prev_hash, hash, signature, sealed_at)
+            VALUES ($1, $2, 'content', $3, $4, 'signature', $5)`, i+1, id, strconv.Itoa(i), strconv.Itoa(i+1), []string{"2024-02-01 12:00:00", "2024-04-01 12:00:00"}[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateUp(s.db, driverSQLite); err != nil {
+		t.Fatal(err)
+	}
+	links, err := s.ListChain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || links[0].Version != 1 || links[1].Version != canonicalVersion {
+		t.Errorf("got links %+v, want version 1 before the checklist and %d after", links, canonicalVersion)
+	}
+	if _, err := s.db.Exec("UPDATE report_chain SET canonical_version = 2"); err == nil {
+		t.Error("links can be changed after the backfill")
+	}
+}
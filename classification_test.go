@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// seedClassifiedEvents saves three reports with an alarm each: a high
+// severity power event in Part 3, a high severity cooling event affecting the
+// data hall in Part 4 and an unclassified one. It returns the catalog IDs.
+func seedClassifiedEvents(t *testing.T, s *sqlStore) (high, power, cooling, dataHall int) {
+	t.Helper()
+	ctx := context.Background()
+	var err error
+	if high, err = s.CreateSeverity(ctx, Severity{Name: "Site down", Level: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if power, err = s.CreateEventCategory(ctx, EventCategory{Name: "Power"}); err != nil {
+		t.Fatal(err)
+	}
+	if cooling, err = s.CreateEventCategory(ctx, EventCategory{Name: "Cooling"}); err != nil {
+		t.Fatal(err)
+	}
+	if dataHall, err = s.CreateAffectedService(ctx, AffectedService{Name: "Data hall"}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := testReport("2024-03-01")
+	first.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "07:00",
+		EventClassification: EventClassification{SeverityID: &high, CategoryID: &power}}}
+	createTestReport(t, s, first)
+
+	second := testReport("2024-03-02")
+	second.EventsPart4 = []EventPart4{{EventSummary: "Chiller alarm", StartTime: "09:00",
+		EventClassification: EventClassification{SeverityID: &high, CategoryID: &cooling, AffectedServiceID: &dataHall}}}
+	createTestReport(t, s, second)
+
+	third := testReport("2024-03-03")
+	third.EventsPart3 = []EventPart3{{EventSummary: "Door alarm", StartTime: "10:00"}}
+	createTestReport(t, s, third)
+	return high, power, cooling, dataHall
+}
+
+func TestCatalogFilters(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s *sqlStore) {
+		useTestStore(s)
+		high, power, cooling, dataHall := seedClassifiedEvents(t, s)
+
+		tests := []struct {
+			name    string
+			query   string
+			reports []string // report dates
+			events  []string // summaries found searching for "alarm"
+		}{
+			{"no filter", "", []string{"2024-03-01", "2024-03-02", "2024-03-03"}, []string{"Chiller alarm", "Door alarm", "UPS alarm"}},
+			{"severity in either part", fmt.Sprintf("severity_id=%d", high), []string{"2024-03-01", "2024-03-02"}, []string{"Chiller alarm", "UPS alarm"}},
+			{"category", fmt.Sprintf("category_id=%d", cooling), []string{"2024-03-02"}, []string{"Chiller alarm"}},
+			{"affected service", fmt.Sprintf("affected_service_id=%d", dataHall), []string{"2024-03-02"}, []string{"Chiller alarm"}},
+			{"combined", fmt.Sprintf("severity_id=%d&category_id=%d", high, power), []string{"2024-03-01"}, []string{"UPS alarm"}},
+			{"no event matches all", fmt.Sprintf("category_id=%d&affected_service_id=%d", power, dataHall), []string{}, []string{}},
+			{"unknown entry", "severity_id=999", []string{}, []string{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				getReportsHandler(w, httptest.NewRequest(http.MethodGet, "/api/reports?"+tt.query, nil))
+				var page ReportPage
+				if err := json.NewDecoder(w.Body).Decode(&page); err != nil || w.Code != http.StatusOK {
+					t.Fatalf("list got %d (%v), want 200", w.Code, err)
+				}
+				dates := []string{}
+				for _, report := range page.Reports {
+					dates = append(dates, report.ReportDate[:10])
+				}
+				sort.Strings(dates)
+				if strings.Join(dates, ",") != strings.Join(tt.reports, ",") || page.Total != len(tt.reports) {
+					t.Errorf("list got %d reports %q, want %q", page.Total, dates, tt.reports)
+				}
+
+				w = httptest.NewRecorder()
+				searchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search?q=alarm&"+tt.query, nil))
+				var results SearchPage
+				if err := json.NewDecoder(w.Body).Decode(&results); err != nil || w.Code != http.StatusOK {
+					t.Fatalf("search got %d (%v), want 200", w.Code, err)
+				}
+				summaries := searchSummaries(results.Results)
+				sort.Strings(summaries)
+				if strings.Join(summaries, ",") != strings.Join(tt.events, ",") || results.Total != len(tt.events) {
+					t.Errorf("search got %d events %q, want %q", results.Total, summaries, tt.events)
+				}
+			})
+		}
+
+		w := httptest.NewRecorder()
+		getReportsHandler(w, httptest.NewRequest(http.MethodGet, "/api/reports?severity_id=high", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("list got %d for a severity name, want 400", w.Code)
+		}
+		w = httptest.NewRecorder()
+		searchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search?q=alarm&category_id=-1", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("search got %d for a negative category, want 400", w.Code)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	Server   ServerConfig   `yaml:"server"`
+	Site     SiteConfig     `yaml:"site"`
+	Session  SessionConfig  `yaml:"session"`
+	Trash    TrashConfig    `yaml:"trash"`
+	Chain    ChainConfig    `yaml:"chain"`
+	RCA      RCAConfig      `yaml:"rca"`
+}
+
+type DatabaseConfig struct {
+	Driver          string        `yaml:"driver"`
+	DSN             string        `yaml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	AutoMigrate     bool          `yaml:"auto_migrate"`
+}
+
+type ServerConfig struct {
+	ListenAddr string    `yaml:"listen_addr"`
+	StaticDir  string    `yaml:"static_dir"`
+	TLS        TLSConfig `yaml:"tls"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type SiteConfig struct {
+	// TimeZone is the IANA name of the site's time zone. Shifts, event times
+	// and sensor readings are wall-clock times in it.
+	TimeZone string `yaml:"time_zone"`
+	location *time.Location
+}
+
+// Location returns the site time zone, UTC until the configuration has been
+// validated.
+func (s SiteConfig) Location() *time.Location {
+	if s.location == nil {
+		return time.UTC
+	}
+	return s.location
+}
+
+// wallClock returns what the site clock showed at t, without a zone, the way
+// wall-clock times are stored.
+func (s SiteConfig) wallClock(t time.Time) time.Time {
+	t = t.In(s.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// timestamp formats a stored wall-clock time as RFC 3339 with the site's UTC
+// offset, so that it is read back as the same wall-clock time.
+func (s SiteConfig) timestamp(value string) string {
+	for _, layout := range []string{time.RFC3339, timestampLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), s.Location()).Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+type SessionConfig struct {
+	Secret       string        `yaml:"secret"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	MaxAge       time.Duration `yaml:"max_age"`
+	SecureCookie bool          `yaml:"secure_cookie"`
+	// Retention is how long expired and revoked sessions are kept before
+	// they are purged.
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted items stay restorable; 0 keeps them forever.
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+type RCAConfig struct {
+	// EscalationDays is how long a Part 3 event may go without an RCA, and an
+	// RCA action may stay open past its due date, before the event is flagged;
+	// 0 disables escalation.
+	EscalationDays     int           `yaml:"escalation_days"`
+	EscalationInterval time.Duration `yaml:"escalation_interval"`
+}
+
+type ChainConfig struct {
+	// SigningKeyFile holds the Ed25519 key locked reports are sealed with. It
+	// is generated on first start.
+	SigningKeyFile string `yaml:"signing_key_file"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Database: DatabaseConfig{
+			Driver:          driverPostgres,
+			DSN:             "host=localhost port=5432 user=postgres dbname=daily_reports sslmode=disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Server: ServerConfig{
+			ListenAddr: ":8084",
+			StaticDir:  "./static",
+		},
+		Site: SiteConfig{
+			TimeZone: "UTC",
+		},
+		Session: SessionConfig{
+			IdleTimeout:   5 * time.Minute,
+			MaxAge:        24 * time.Hour,
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Chain: ChainConfig{
+			SigningKeyFile: "report_chain.key",
+		},
+		RCA: RCAConfig{
+			EscalationDays:     7,
+			EscalationInterval: time.Hour,
+		},
+	}
+}
+
+// loadConfig reads the YAML file at path on top of the defaults, then applies
+// DAILY_REPORT_* environment overrides and validates the result. A missing file
+// is only an error when required is set.
+func loadConfig(path string, required bool) (Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !required:
+	default:
+		return cfg, err
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.validate()
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	strs := map[string]*string{
+		"DAILY_REPORT_DB_DRIVER":      &c.Database.Driver,
+		"DAILY_REPORT_DB_DSN":         &c.Database.DSN,
+		"DAILY_REPORT_LISTEN_ADDR":    &c.Server.ListenAddr,
+		"DAILY_REPORT_STATIC_DIR":     &c.Server.StaticDir,
+		"DAILY_REPORT_TLS_CERT_FILE":  &c.Server.TLS.CertFile,
+		"DAILY_REPORT_TLS_KEY_FILE":   &c.Server.TLS.KeyFile,
+		"DAILY_REPORT_SITE_TIME_ZONE": &c.Site.TimeZone,
+		"DAILY_REPORT_SESSION_SECRET": &c.Session.Secret,
+		"DAILY_REPORT_CHAIN_KEY_FILE": &c.Chain.SigningKeyFile,
+	}
+	ints := map[string]*int{
+		"DAILY_REPORT_DB_MAX_OPEN_CONNS":   &c.Database.MaxOpenConns,
+		"DAILY_REPORT_DB_MAX_IDLE_CONNS":   &c.Database.MaxIdleConns,
+		"DAILY_REPORT_RCA_ESCALATION_DAYS": &c.RCA.EscalationDays,
+	}
+	durations := map[string]*time.Duration{
+		"DAILY_REPORT_DB_CONN_MAX_LIFETIME":    &c.Database.ConnMaxLifetime,
+		"DAILY_REPORT_SESSION_IDLE_TIMEOUT":    &c.Session.IdleTimeout,
+		"DAILY_REPORT_SESSION_MAX_AGE":         &c.Session.MaxAge,
+		"DAILY_REPORT_SESSION_RETENTION":       &c.Session.Retention,
+		"DAILY_REPORT_SESSION_PURGE_INTERVAL":  &c.Session.PurgeInterval,
+		"DAILY_REPORT_TRASH_RETENTION":         &c.Trash.Retention,
+		"DAILY_REPORT_TRASH_PURGE_INTERVAL":    &c.Trash.PurgeInterval,
+		"DAILY_REPORT_RCA_ESCALATION_INTERVAL": &c.RCA.EscalationInterval,
+	}
+	bools := map[string]*bool{
+		"DAILY_REPORT_DB_AUTO_MIGRATE":       &c.Database.AutoMigrate,
+		"DAILY_REPORT_SESSION_SECURE_COOKIE": &c.Session.SecureCookie,
+	}
+
+	for name, dst := range strs {
+		if v, ok := lookup(name); ok {
+			*dst = v
+		}
+	}
+	for name, dst := range ints {
+		if v, ok := lookup(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range durations {
+		if v, ok := lookup(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = d
+		}
+	}
+	for name, dst := range bools {
+		if v, ok := lookup(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = b
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	var problems []string
+
+	if c.Database.Driver != driverPostgres && c.Database.Driver != driverSQLite {
+		problems = append(problems, fmt.Sprintf("database.driver must be %q or %q", driverPostgres, driverSQLite))
+	}
+	if c.Database.DSN == "" {
+		problems = append(problems, "database.dsn is required")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		problems = append(problems, "database pool sizes must not be negative")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, "database.max_idle_conns must not exceed database.max_open_conns")
+	}
+	if c.Server.ListenAddr == "" {
+		problems = append(problems, "server.listen_addr is required")
+	}
+	if info, err := os.Stat(c.Server.StaticDir); err != nil || !info.IsDir() {
+		problems = append(problems, fmt.Sprintf("server.static_dir %q is not a directory", c.Server.StaticDir))
+	}
+	if c.Server.TLS.Enabled() && (c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "") {
+		problems = append(problems, "server.tls needs both cert_file and key_file")
+	}
+	if loc, err := time.LoadLocation(c.Site.TimeZone); err != nil {
+		problems = append(problems, fmt.Sprintf("site.time_zone %q is not a known time zone", c.Site.TimeZone))
+	} else {
+		c.Site.location = loc
+	}
+	if len(c.Session.Secret) < 32 {
+		problems = append(problems, "session.secret must be at least 32 bytes")
+	}
+	if c.Session.IdleTimeout <= 0 {
+		problems = append(problems, "session.idle_timeout must be positive")
+	}
+	if c.Session.MaxAge < c.Session.IdleTimeout {
+		problems = append(problems, "session.max_age must not be shorter than session.idle_timeout")
+	}
+	if c.Session.Retention < 0 {
+		problems = append(problems, "session.retention must not be negative")
+	}
+	if c.Session.PurgeInterval <= 0 {
+		problems = append(problems, "session.purge_interval must be positive")
+	}
+	if c.Trash.Retention < 0 {
+		problems = append(problems, "trash.retention must not be negative")
+	}
+	if c.Trash.PurgeInterval <= 0 {
+		problems = append(problems, "trash.purge_interval must be positive")
+	}
+	if c.RCA.EscalationDays < 0 {
+		problems = append(problems, "rca.escalation_days must not be negative")
+	}
+	if c.RCA.EscalationInterval <= 0 {
+		problems = append(problems, "rca.escalation_interval must be positive")
+	}
+	if c.Chain.SigningKeyFile == "" {
+		problems = append(problems, "chain.signing_key_file is required")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validTestConfig is the default configuration made valid for the test.
+func validTestConfig(t *testing.T) Config {
+	c := defaultConfig()
+	c.Server.StaticDir = t.TempDir()
+	c.Session.Secret = strings.Repeat("s", 32)
+	return c
+}
+
+// tehran is a site time zone 3:30 ahead of UTC.
+var tehran = time.FixedZone("+0330", 3*60*60+30*60)
+
+// useTestSiteZone sets the site time zone for the rest of the test.
+func useTestSiteZone(t *testing.T, loc *time.Location) {
+	site := cfg.Site
+	cfg.Site = SiteConfig{TimeZone: loc.String(), location: loc}
+	t.Cleanup(func() { cfg.Site = site })
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	file := `
+database:
+  driver: sqlite
+  dsn: file.db
+server:
+  listen_addr: ":9000"
+  static_dir: "` + dir + `"
+session:
+  secret: "` + strings.Repeat("f", 32) + `"
+  idle_timeout: 10m
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DAILY_REPORT_LISTEN_ADDR", ":9443")
+	t.Setenv("DAILY_REPORT_SESSION_IDLE_TIMEOUT", "2m")
+	t.Setenv("DAILY_REPORT_DB_MAX_OPEN_CONNS", "8")
+	t.Setenv("DAILY_REPORT_SITE_TIME_ZONE", "Asia/Tehran")
+
+	c, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.ListenAddr != ":9443" || c.Session.IdleTimeout != 2*time.Minute || c.Database.MaxOpenConns != 8 {
+		t.Errorf("got listen %q, idle timeout %v and %d connections, want the environment values", c.Server.ListenAddr, c.Session.IdleTimeout, c.Database.MaxOpenConns)
+	}
+	if c.Database.Driver != driverSQLite || c.Database.DSN != "file.db" || c.Session.Secret != strings.Repeat("f", 32) {
+		t.Errorf("got driver %q and DSN %q, want the file values", c.Database.Driver, c.Database.DSN)
+	}
+	if c.Session.MaxAge != 24*time.Hour {
+		t.Errorf("got max age %v, want the default", c.Session.MaxAge)
+	}
+	if c.Site.Location().String() != "Asia/Tehran" {
+		t.Errorf("got site time zone %v, want Asia/Tehran", c.Site.Location())
+	}
+
+	t.Setenv("DAILY_REPORT_DB_MAX_OPEN_CONNS", "many")
+	if _, err := loadConfig(path, true); err == nil || !strings.Contains(err.Error(), "DAILY_REPORT_DB_MAX_OPEN_CONNS") {
+		t.Errorf("got %v, want an error naming the invalid variable", err)
+	}
+	if _, err := loadConfig(filepath.Join(dir, "missing.yaml"), true); err == nil {
+		t.Error("a required missing file loaded")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string // empty if valid
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"short secret", func(c *Config) { c.Session.Secret = "too-short" }, "session.secret must be at least 32 bytes"},
+		{"TLS without key", func(c *Config) { c.Server.TLS.CertFile = "server.crt" }, "server.tls needs both cert_file and key_file"},
+		{"TLS without cert", func(c *Config) { c.Server.TLS.KeyFile = "server.key" }, "server.tls needs both cert_file and key_file"},
+		{"TLS", func(c *Config) { c.Server.TLS.CertFile, c.Server.TLS.KeyFile = "server.crt", "server.key" }, ""},
+		{"unknown driver", func(c *Config) { c.Database.Driver = "mysql" }, `database.driver must be "postgres" or "sqlite"`},
+		{"unknown time zone", func(c *Config) { c.Site.TimeZone = "Mars/Olympus" }, `site.time_zone "Mars/Olympus" is not a known time zone`},
+		{"missing static dir", func(c *Config) { c.Server.StaticDir = filepath.Join(c.Server.StaticDir, "missing") }, "is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validTestConfig(t)
+			tt.change(&c)
+			err := c.validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("got %v, want valid", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
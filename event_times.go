@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event times are entered as HH:MM and stored as full timestamps. The date
+// comes from the report date and the shift: on a shift that crosses midnight
+// (Night Shift 22:00–06:00) a time before the shift start is on the next day.
+
+const timestampLayout = "2006-01-02 15:04:05"
+
+// shiftWindow is the time span a shift covers on a report date. Reports
+// without a shift cover the whole day.
+type shiftWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+func newShiftWindow(reportDate string, shift *ShiftHours) (shiftWindow, error) {
+	date, err := time.Parse("2006-01-02", reportDate)
+	if err != nil {
+		return shiftWindow{}, fmt.Errorf("invalid report date %q, expected YYYY-MM-DD", reportDate)
+	}
+	if shift == nil {
+		return shiftWindow{Start: date, End: date.AddDate(0, 0, 1)}, nil
+	}
+
+	start, err := clockOffset(shift.StartTime)
+	if err != nil {
+		return shiftWindow{}, err
+	}
+	end, err := clockOffset(shift.EndTime)
+	if err != nil {
+		return shiftWindow{}, err
+	}
+	window := shiftWindow{Start: date.Add(start), End: date.Add(end)}
+	if !window.End.After(window.Start) {
+		window.End = window.End.AddDate(0, 0, 1)
+	}
+	return window, nil
+}
+
+// crossesMidnight reports whether the shift runs past the end of its first day.
+func (w shiftWindow) crossesMidnight() bool {
+	return w.End.After(w.Start.Truncate(24*time.Hour).AddDate(0, 0, 1))
+}
+
+// eventTime places an HH:MM value in the window. A full timestamp with a UTC
+// offset is converted to the site clock; one without is taken as site time.
+func (w shiftWindow) eventTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return cfg.Site.wallClock(t), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", timestampLayout, "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	offset, err := clockOffset(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	day := w.Start.Truncate(24 * time.Hour)
+	t := day.Add(offset)
+	if w.crossesMidnight() && t.Before(w.Start) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// eventTimes resolves an event's start and end. Empty values stay NULL.
+func (w shiftWindow) eventTimes(start, end string) (interface{}, interface{}, error) {
+	var startAt, endAt time.Time
+	var err error
+	if start != "" {
+		if startAt, err = w.eventTime(start); err != nil {
+			return nil, nil, err
+		}
+	}
+	if end != "" {
+		if endAt, err = w.eventTime(end); err != nil {
+			return nil, nil, err
+		}
+	}
+	if start != "" && end != "" && endAt.Before(startAt) {
+		return nil, nil, fmt.Errorf("end time %s is before start time %s", endAt.Format(timestampLayout), startAt.Format(timestampLayout))
+	}
+	return nullTimestamp(start, startAt), nullTimestamp(end, endAt), nil
+}
+
+// storedTimestamp formats an event time the way the drivers return the stored
+// column: the site's wall-clock time labelled UTC. Seals cover this form, so
+// they do not depend on the site time zone.
+func storedTimestamp(value string) string {
+	for _, layout := range []string{time.RFC3339, timestampLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC).Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+func nullTimestamp(value string, t time.Time) interface{} {
+	if value == "" {
+		return nil
+	}
+	return t.Format(timestampLayout)
+}
+
+// clockOffset parses a time of day (HH:MM or HH:MM:SS) into an offset from midnight.
+func clockOffset(value string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+}
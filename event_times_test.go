@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEventTimes(t *testing.T) {
+	night := &ShiftHours{ID: 3, Name: "Night Shift", StartTime: "22:00:00", EndTime: "06:00:00"}
+	morning := &ShiftHours{ID: 1, Name: "Morning Shift", StartTime: "06:00:00", EndTime: "14:00:00"}
+
+	tests := []struct {
+		name      string
+		shift     *ShiftHours
+		start     string
+		end       string
+		wantStart interface{}
+		wantEnd   interface{}
+		wantErr   bool
+	}{
+		{"day shift", morning, "08:00", "09:30", "2024-03-11 08:00:00", "2024-03-11 09:30:00", false},
+		{"night before midnight", night, "22:30", "23:45", "2024-03-11 22:30:00", "2024-03-11 23:45:00", false},
+		{"night across midnight", night, "23:30", "01:15", "2024-03-11 23:30:00", "2024-03-12 01:15:00", false},
+		{"night after midnight", night, "02:00", "05:59", "2024-03-12 02:00:00", "2024-03-12 05:59:00", false},
+		{"without shift", nil, "00:10", "23:50", "2024-03-11 00:10:00", "2024-03-11 23:50:00", false},
+		{"open event", night, "23:00", "", "2024-03-11 23:00:00", nil, false},
+		{"full timestamps", night, "2024-03-11 23:00:00", "2024-03-12T00:30:00Z", "2024-03-11 23:00:00", "2024-03-12 00:30:00", false},
+		{"offset converted to the site clock", night, "2024-03-12T01:30:00+03:30", "2024-03-11T23:30:00-05:00", "2024-03-11 22:00:00", "2024-03-12 04:30:00", false},
+		{"end before start", morning, "10:00", "09:00", nil, nil, true},
+		{"end before start across midnight", night, "01:00", "23:00", nil, nil, true},
+		{"invalid time", morning, "8 o'clock", "", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := newShiftWindow("2024-03-11", tt.shift)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, end, err := window.eventTimes(tt.start, tt.end)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (start != tt.wantStart || end != tt.wantEnd) {
+				t.Errorf("got %v to %v, want %v to %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestEventTimeOnSiteClock(t *testing.T) {
+	useTestSiteZone(t, tehran)
+	night := &ShiftHours{ID: 3, Name: "Night Shift", StartTime: "22:00:00", EndTime: "06:00:00"}
+	window, err := newShiftWindow("2024-03-11", night)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-03-11T23:30:00+03:30", time.Date(2024, 3, 11, 23, 30, 0, 0, time.UTC)},
+		{"2024-03-11T20:00:00Z", time.Date(2024, 3, 11, 23, 30, 0, 0, time.UTC)},
+		{"2024-03-11T22:30:00Z", time.Date(2024, 3, 12, 2, 0, 0, 0, time.UTC)},
+		{"2024-03-11 23:30:00", time.Date(2024, 3, 11, 23, 30, 0, 0, time.UTC)},
+		{"23:30", time.Date(2024, 3, 11, 23, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		var errs ValidationErrors
+		errs.checkEvent("events_part3[0]", "UPS alarm", tt.value, "", &window)
+		if len(errs) > 0 {
+			t.Errorf("%s: got %+v, want it within the night shift of 2024-03-11", tt.value, errs)
+		}
+		got, err := window.eventTime(tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNightShiftEventsStored(t *testing.T) {
+	s := openTestStore(t)
+	useTestSiteZone(t, tehran)
+	night := 3
+	input := testReport("2024-03-11")
+	input.ShiftHoursID = &night
+	input.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "23:30", EndTime: "2024-03-11T21:45:00Z"}}
+	input.EventsPart4 = []EventPart4{{EventSummary: "Door check", StartTime: "2024-03-12T05:30:00+03:30", EndTime: "05:45"}}
+	id := createTestReport(t, s, input)
+
+	report, err := s.GetReport(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part3, part4 := report.EventsPart3[0], report.EventsPart4[0]
+	if part3.StartTime != "2024-03-11T23:30:00+03:30" || part3.EndTime != "2024-03-12T01:15:00+03:30" {
+		t.Errorf("got Part 3 event from %s to %s, want 23:30 to 01:15 the next day", part3.StartTime, part3.EndTime)
+	}
+	if part4.StartTime != "2024-03-12T05:30:00+03:30" || part4.EndTime != "2024-03-12T05:45:00+03:30" {
+		t.Errorf("got Part 4 event from %s to %s, want 05:30 to 05:45 on 2024-03-12", part4.StartTime, part4.EndTime)
+	}
+}
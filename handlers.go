@@ -1,20 +1,27 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Authentication handlers
 func homeHandler(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "./static/login.html")
+	http.ServeFile(w, r, filepath.Join(cfg.Server.StaticDir, "login.html"))
 }
 
 func loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -28,27 +35,35 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user User
-	var hashedPassword string
-	err := db.QueryRow("SELECT id, username, full_name, role, password FROM users WHERE username = $1",
-		credentials.Username).Scan(&user.ID, &user.Username, &user.FullName, &user.Role, &hashedPassword)
-
+	failed := AuditEntry{ActorUsername: credentials.Username, Action: "login_failed", EntityType: "user"}
+	user, hashedPassword, err := userStore.GetUserCredentials(r.Context(), credentials.Username)
 	if err != nil {
+		writeAudit(r, failed, nil, nil)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(credentials.Password)); err != nil {
+		failed.ActorID, failed.EntityID = &user.ID, &user.ID
+		writeAudit(r, failed, nil, nil)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
 	session, _ := store.Get(r, "session")
+	if err := store.renew(session); err != nil {
+		http.Error(w, "Session error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	session.Values["user_id"] = user.ID
 	session.Values["username"] = user.Username
 	session.Values["role"] = user.Role
 	session.Values["last_activity"] = time.Now().Unix()
-	session.Save(r, w)
+	if err := session.Save(r, w); err != nil {
+		http.Error(w, "Session error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAudit(r, AuditEntry{ActorID: &user.ID, ActorUsername: user.Username, Action: "login", EntityType: "user", EntityID: &user.ID}, nil, nil)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
@@ -56,6 +71,10 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 
 func logoutHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := store.Get(r, "session")
+	if userID, ok := session.Values["user_id"].(int); ok {
+		username, _ := session.Values["username"].(string)
+		writeAudit(r, AuditEntry{ActorID: &userID, ActorUsername: username, Action: "logout", EntityType: "user", EntityID: &userID}, nil, nil)
+	}
 	session.Values["user_id"] = nil
 	session.Options.MaxAge = -1
 	session.Save(r, w)
@@ -69,17 +88,16 @@ func checkAuthHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Session error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	
+
 	// Check if session has expired
-	lastActivity, ok := session.Values["last_activity"].(int64)
-	if !ok || time.Now().Unix()-lastActivity > int64(5*time.Minute.Seconds()) {
+	if sessionIdleExpired(session) {
 		// Session expired
 		session.Options.MaxAge = -1
 		session.Save(r, w)
 		http.Error(w, "Session expired", http.StatusUnauthorized)
 		return
 	}
-	
+
 	userID, ok := session.Values["user_id"].(int)
 	if !ok {
 		fmt.Println("User not authenticated")
@@ -91,12 +109,10 @@ func checkAuthHandler(w http.ResponseWriter, r *http.Request) {
 	session.Values["last_activity"] = time.Now().Unix()
 	session.Save(r, w)
 
-	var user User
-	err = db.QueryRow("SELECT id, username, full_name, role FROM users WHERE id = $1", userID).
-		Scan(&user.ID, &user.Username, &user.FullName, &user.Role)
+	user, err := userStore.GetUser(r.Context(), userID)
 	if err != nil {
 		fmt.Printf("Database error fetching user: %v\n", err)
-		if err == sql.ErrNoRows {
+		if err == errNotFound {
 			http.Error(w, "User not found", http.StatusNotFound)
 		} else {
 			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
@@ -108,76 +124,134 @@ func checkAuthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+type contextKey string
+
+const (
+	sessionUserKey contextKey = "session_user"
+	apiTokenKey    contextKey = "api_token"
+)
+
+// sessionUser is the authenticated user attached to the request context by requireAuth.
+type sessionUser struct {
+	ID       int
+	Username string
+	Role     string
+}
+
+func currentUser(r *http.Request) (sessionUser, bool) {
+	user, ok := r.Context().Value(sessionUserKey).(sessionUser)
+	return user, ok
+}
+
+// sessionIdleExpired reports whether the session saw no activity within session.idle_timeout.
+func sessionIdleExpired(session *sessions.Session) bool {
+	lastActivity, ok := session.Values["last_activity"].(int64)
+	return !ok || time.Since(time.Unix(lastActivity, 0)) > cfg.Session.IdleTimeout
+}
+
 func requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session, _ := store.Get(r, "session")
-		
+
 		// Check if session has expired
-		lastActivity, ok := session.Values["last_activity"].(int64)
-		if !ok || time.Now().Unix()-lastActivity > int64(5*time.Minute.Seconds()) {
+		if sessionIdleExpired(session) {
 			// Session expired
 			session.Options.MaxAge = -1
 			session.Save(r, w)
 			http.Error(w, "Session expired", http.StatusUnauthorized)
 			return
 		}
-		
-		if _, ok := session.Values["user_id"]; !ok {
+
+		userID, ok := session.Values["user_id"].(int)
+		if !ok {
 			http.Error(w, "Authentication required", http.StatusUnauthorized)
 			return
 		}
-		
+		role, _ := session.Values["role"].(string)
+		username, _ := session.Values["username"].(string)
+
 		// Update last activity
 		session.Values["last_activity"] = time.Now().Unix()
 		session.Save(r, w)
-		
-		next(w, r)
+
+		ctx := context.WithValue(r.Context(), sessionUserKey, sessionUser{ID: userID, Username: username, Role: role})
+		next(w, r.WithContext(ctx))
 	}
 }
 
 func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := currentUser(r)
+		if user.Role != "admin" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// requireOwnerOrAdmin must run inside requireAuth.
+func requireOwnerOrAdmin(owner ownerFunc, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		session, _ := store.Get(r, "session")
-		
-		// Check if session has expired
-		lastActivity, ok := session.Values["last_activity"].(int64)
-		if !ok || time.Now().Unix()-lastActivity > int64(5*time.Minute.Seconds()) {
-			// Session expired
-			session.Options.MaxAge = -1
-			session.Save(r, w)
-			http.Error(w, "Session expired", http.StatusUnauthorized)
+		user, _ := currentUser(r)
+		if user.Role == "admin" {
+			next(w, r)
 			return
 		}
-		
-		role, ok := session.Values["role"].(string)
-		if !ok || role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+
+		ownerID, err := owner(r)
+		if err != nil {
+			if err == errNotFound {
+				http.Error(w, "Not found", http.StatusNotFound)
+			} else {
+				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			}
 			return
 		}
-		
-		// Update last activity
-		session.Values["last_activity"] = time.Now().Unix()
-		session.Save(r, w)
-		
+
+		if ownerID != user.ID {
+			fmt.Printf("Permission denied: User %d tried to access %s owned by %d\n", user.ID, r.URL.Path, ownerID)
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+
 		next(w, r)
 	}
 }
 
+// requireAPIToken authenticates devices by the bearer token in the
+// Authorization header and attaches the token to the request context.
+func requireAPIToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			http.Error(w, "API token required", http.StatusUnauthorized)
+			return
+		}
+
+		apiToken, err := sensorStore.UseAPIToken(r.Context(), hashAPIToken(strings.TrimSpace(token)))
+		if err != nil {
+			if err == errNotFound {
+				http.Error(w, "Invalid API token", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiTokenKey, apiToken)
+		next(w, r.WithContext(ctx))
+	}
+}
+
 // User handlers
 func getUsersHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, username, full_name, role FROM users ORDER BY full_name")
+	users, err := userStore.ListUsers(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		rows.Scan(&user.ID, &user.Username, &user.FullName, &user.Role)
-		users = append(users, user)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
@@ -202,13 +276,14 @@ func createUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var id int
-	err = db.QueryRow("INSERT INTO users (username, password, full_name, role) VALUES ($1, $2, $3, $4) RETURNING id",
-		user.Username, string(hashedPassword), user.FullName, user.Role).Scan(&id)
+	created := User{Username: user.Username, FullName: user.FullName, Role: user.Role}
+	id, err := userStore.CreateUser(r.Context(), created, string(hashedPassword))
 	if err != nil {
 		http.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
 	}
+	created.ID = id
+	recordAudit(r, "create", "user", id, nil, created)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]int{"id": id})
@@ -230,19 +305,42 @@ func updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	current, err := userStore.GetUser(r.Context(), id)
+	if err != nil {
+		if err == errNotFound {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var hashedPassword []byte
 	if user.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 		if err != nil {
 			http.Error(w, "Error hashing password", http.StatusInternalServerError)
 			return
 		}
-		_, err = db.Exec("UPDATE users SET username = $1, full_name = $2, role = $3, password = $4 WHERE id = $5",
-			user.Username, user.FullName, user.Role, string(hashedPassword), id)
-	} else {
-		_, err := db.Exec("UPDATE users SET username = $1, full_name = $2, role = $3 WHERE id = $4",
-			user.Username, user.FullName, user.Role, id)
-		if err != nil {
-			http.Error(w, "Error updating user", http.StatusInternalServerError)
+	}
+
+	updated := User{ID: id, Username: user.Username, FullName: user.FullName, Role: user.Role}
+	err = userStore.UpdateUser(r.Context(), updated, string(hashedPassword))
+	if err != nil {
+		http.Error(w, "Error updating user", http.StatusInternalServerError)
+		return
+	}
+	// The password itself is never logged, only that it changed
+	recordAudit(r, "update", "user", id, current, struct {
+		User
+		PasswordChanged bool `json:"password_changed,omitempty"`
+	}{updated, user.Password != ""})
+
+	// Credentials or privileges changed: force the user to log in again
+	if user.Password != "" || user.Role != current.Role {
+		if _, err := store.revokeUserSessions(id); err != nil {
+			fmt.Printf("Error revoking sessions for user %d: %v\n", id, err)
+			http.Error(w, "User updated but sessions could not be revoked", http.StatusInternalServerError)
 			return
 		}
 	}
@@ -254,29 +352,170 @@ func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	_, err := db.Exec("DELETE FROM users WHERE id = $1", id)
-	if err != nil {
+	user, _ := currentUser(r)
+	if id == user.ID {
+		http.Error(w, "You cannot delete your own account", http.StatusBadRequest)
+		return
+	}
+	before := auditState(userStore.GetUser(r.Context(), id))
+	if err := userStore.DeleteUser(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Error deleting user", http.StatusInternalServerError)
 		return
 	}
+	// A deleted user is signed out everywhere
+	if _, err := store.revokeUserSessions(id); err != nil {
+		fmt.Printf("Error revoking sessions of deleted user %d: %v\n", id, err)
+	}
+	recordAudit(r, "delete", "user", id, before, nil)
 
 	w.WriteHeader(http.StatusOK)
 }
 
-// Shift hours handlers
-func getShiftHoursHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, name, start_time, end_time FROM shift_hours ORDER BY start_time")
+// Session handlers
+func getUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	sessionList, err := store.activeSessions(id)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessionList)
+}
+
+func revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := store.revokeUserSessions(id)
+	if err != nil {
+		http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "revoke_sessions", "user", id, nil, map[string]int64{"revoked": revoked})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": revoked})
+}
+
+func revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	found, err := store.revokeSession(id)
+	if err != nil {
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	recordAudit(r, "revoke", "session", id, nil, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Site handlers
+func getSitesHandler(w http.ResponseWriter, r *http.Request) {
+	sites, err := catalogStore.ListSites(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var shifts []ShiftHours
-	for rows.Next() {
-		var shift ShiftHours
-		rows.Scan(&shift.ID, &shift.Name, &shift.StartTime, &shift.EndTime)
-		shifts = append(shifts, shift)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sites)
+}
+
+func createSiteHandler(w http.ResponseWriter, r *http.Request) {
+	var site Site
+	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	id, err := catalogStore.CreateSite(r.Context(), site)
+	if err != nil {
+		http.Error(w, "Error creating site", http.StatusInternalServerError)
+		return
+	}
+	site.ID = id
+	recordAudit(r, "create", "site", id, nil, site)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func updateSiteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var site Site
+	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	site.ID = id
+
+	before := auditCatalogEntry(r.Context(), "site", id)
+	if err := catalogStore.UpdateSite(r.Context(), site); err != nil {
+		http.Error(w, "Error updating site", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "site", id, before, site)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteSiteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if id == defaultSiteID {
+		http.Error(w, "The main site cannot be deleted", http.StatusBadRequest)
+		return
+	}
+	user, _ := currentUser(r)
+	before := auditCatalogEntry(r.Context(), "site", id)
+	if err := catalogStore.DeleteSite(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting site", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "site", id, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Shift hours handlers
+func getShiftHoursHandler(w http.ResponseWriter, r *http.Request) {
+	shifts, err := catalogStore.ListShiftHours(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -290,13 +529,13 @@ func createShiftHoursHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var id int
-	err := db.QueryRow("INSERT INTO shift_hours (name, start_time, end_time) VALUES ($1, $2, $3) RETURNING id",
-		shift.Name, shift.StartTime, shift.EndTime).Scan(&id)
+	id, err := catalogStore.CreateShiftHours(r.Context(), shift)
 	if err != nil {
 		http.Error(w, "Error creating shift hours", http.StatusInternalServerError)
 		return
 	}
+	shift.ID = id
+	recordAudit(r, "create", "shift_hours", id, nil, shift)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]int{"id": id})
@@ -311,13 +550,14 @@ func updateShiftHoursHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	shift.ID = id
 
-	_, err := db.Exec("UPDATE shift_hours SET name = $1, start_time = $2, end_time = $3 WHERE id = $4",
-		shift.Name, shift.StartTime, shift.EndTime, id)
-	if err != nil {
+	before := auditCatalogEntry(r.Context(), "shift_hours", id)
+	if err := catalogStore.UpdateShiftHours(r.Context(), shift); err != nil {
 		http.Error(w, "Error updating shift hours", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "update", "shift_hours", id, before, shift)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -326,30 +566,28 @@ func deleteShiftHoursHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	_, err := db.Exec("DELETE FROM shift_hours WHERE id = $1", id)
-	if err != nil {
+	user, _ := currentUser(r)
+	before := auditCatalogEntry(r.Context(), "shift_hours", id)
+	if err := catalogStore.DeleteShiftHours(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Shift hours not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Error deleting shift hours", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "delete", "shift_hours", id, before, nil)
 
 	w.WriteHeader(http.StatusOK)
 }
 
 // Event titles handlers
 func getEventTitlesHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, title FROM event_titles ORDER BY title")
+	titles, err := catalogStore.ListEventTitles(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var titles []EventTitle
-	for rows.Next() {
-		var title EventTitle
-		rows.Scan(&title.ID, &title.Title)
-		titles = append(titles, title)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(titles)
@@ -362,12 +600,13 @@ func createEventTitleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var id int
-	err := db.QueryRow("INSERT INTO event_titles (title) VALUES ($1) RETURNING id", title.Title).Scan(&id)
+	id, err := catalogStore.CreateEventTitle(r.Context(), title)
 	if err != nil {
 		http.Error(w, "Error creating event title", http.StatusInternalServerError)
 		return
 	}
+	title.ID = id
+	recordAudit(r, "create", "event_title", id, nil, title)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]int{"id": id})
@@ -382,12 +621,14 @@ func updateEventTitleHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	title.ID = id
 
-	_, err := db.Exec("UPDATE event_titles SET title = $1 WHERE id = $2", title.Title, id)
-	if err != nil {
+	before := auditCatalogEntry(r.Context(), "event_title", id)
+	if err := catalogStore.UpdateEventTitle(r.Context(), title); err != nil {
 		http.Error(w, "Error updating event title", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "update", "event_title", id, before, title)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -396,230 +637,1607 @@ func deleteEventTitleHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	_, err := db.Exec("DELETE FROM event_titles WHERE id = $1", id)
-	if err != nil {
+	user, _ := currentUser(r)
+	before := auditCatalogEntry(r.Context(), "event_title", id)
+	if err := catalogStore.DeleteEventTitle(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Event title not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Error deleting event title", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "delete", "event_title", id, before, nil)
 
 	w.WriteHeader(http.StatusOK)
 }
 
-// Report handlers
-func getReportsHandler(w http.ResponseWriter, r *http.Request) {
-	dateFilter := r.URL.Query().Get("date")
+// Event catalog handlers: severities, categories and affected services
+func getSeveritiesHandler(w http.ResponseWriter, r *http.Request) {
+	severities, err := catalogStore.ListSeverities(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if severities == nil {
+		severities = []Severity{}
+	}
 
-	// Log the request
-	fmt.Println("Received request for /api/reports")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(severities)
+}
 
-	query := `
-        SELECT dr.id, dr.report_date, dr.health_power_sources, dr.health_humidity_temp, 
-               dr.health_fire_system, dr.created_at,
-               u.id, u.username, u.full_name, u.role,
-               sh.id, sh.name, sh.start_time, sh.end_time
-        FROM daily_reports dr
-        JOIN users u ON dr.created_by = u.id
-        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id
-    `
+func createSeverityHandler(w http.ResponseWriter, r *http.Request) {
+	var severity Severity
+	if err := json.NewDecoder(r.Body).Decode(&severity); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	severity.Name = strings.TrimSpace(severity.Name)
+	if severity.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
 
-	args := []interface{}{}
-	if dateFilter != "" {
-		query += " WHERE dr.report_date = $1"
-		args = append(args, dateFilter)
+	id, err := catalogStore.CreateSeverity(r.Context(), severity)
+	if err != nil {
+		http.Error(w, "Error creating severity", http.StatusInternalServerError)
+		return
 	}
+	severity.ID = id
+	recordAudit(r, "create", "severity", id, nil, severity)
 
-	query += " ORDER BY dr.report_date DESC, dr.created_at DESC"
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
 
-	// Log the query
-	fmt.Printf("Executing query: %s with args: %v\n", query, args)
+func updateSeverityHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
 
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		fmt.Printf("Database query error: %v\n", err)
-		http.Error(w, "Database query error: "+err.Error(), http.StatusInternalServerError)
+	var severity Severity
+	if err := json.NewDecoder(r.Body).Decode(&severity); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	severity.ID, severity.Name = id, strings.TrimSpace(severity.Name)
+	if severity.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
 		return
 	}
-	defer rows.Close()
-
-	var reports []DailyReport
-	for rows.Next() {
-		var report DailyReport
-		var shiftID sql.NullInt64
-		var shiftName, shiftStart, shiftEnd sql.NullString
-		var createdByUser User
 
-		err := rows.Scan(&report.ID, &report.ReportDate, &report.HealthPowerSources,
-			&report.HealthHumidityTemp, &report.HealthFireSystem, &report.CreatedAt,
-			&createdByUser.ID, &createdByUser.Username, &createdByUser.FullName, &createdByUser.Role,
-			&shiftID, &shiftName, &shiftStart, &shiftEnd)
-		if err != nil {
-			fmt.Printf("Row scanning error: %v\n", err)
-			http.Error(w, "Row scanning error: "+err.Error(), http.StatusInternalServerError)
+	before := auditCatalogEntry(r.Context(), "severity", id)
+	if err := catalogStore.UpdateSeverity(r.Context(), severity); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Severity not found", http.StatusNotFound)
 			return
 		}
+		http.Error(w, "Error updating severity", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "severity", id, before, severity)
 
-		report.CreatedBy = createdByUser
+	w.WriteHeader(http.StatusOK)
+}
 
-		if shiftID.Valid {
-			report.ShiftHours = &ShiftHours{
-				ID:        int(shiftID.Int64),
-				Name:      shiftName.String,
-				StartTime: shiftStart.String,
-				EndTime:   shiftEnd.String,
-			}
-		}
+func deleteSeverityHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
 
-		// Load shift managers
-		managerRows, err := db.Query(`
-            SELECT u.id, u.username, u.full_name, u.role 
-            FROM users u 
-            JOIN report_shift_managers rsm ON u.id = rsm.user_id 
-            WHERE rsm.report_id = $1`, report.ID)
-		if err != nil {
-			fmt.Printf("Error loading shift managers: %v\n", err)
-			http.Error(w, "Error loading shift managers: "+err.Error(), http.StatusInternalServerError)
+	user, _ := currentUser(r)
+	before := auditCatalogEntry(r.Context(), "severity", id)
+	if err := catalogStore.DeleteSeverity(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Severity not found", http.StatusNotFound)
 			return
 		}
+		http.Error(w, "Error deleting severity", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "severity", id, before, nil)
 
-		for managerRows.Next() {
-			var manager User
-			err := managerRows.Scan(&manager.ID, &manager.Username, &manager.FullName, &manager.Role)
-			if err != nil {
-				managerRows.Close()
-				fmt.Printf("Error scanning shift manager: %v\n", err)
-				http.Error(w, "Error scanning shift manager: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			report.ShiftManagers = append(report.ShiftManagers, manager)
-		}
-		managerRows.Close()
+	w.WriteHeader(http.StatusOK)
+}
 
-		// Load event titles
-		titleRows, err := db.Query(`
-            SELECT et.id, et.title 
-            FROM event_titles et 
-            JOIN report_event_titles ret ON et.id = ret.event_title_id 
-            WHERE ret.report_id = $1`, report.ID)
-		if err != nil {
-			fmt.Printf("Error loading event titles: %v\n", err)
-			http.Error(w, "Error loading event titles: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+func getEventCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := catalogStore.ListEventCategories(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if categories == nil {
+		categories = []EventCategory{}
+	}
 
-		for titleRows.Next() {
-			var title EventTitle
-			err := titleRows.Scan(&title.ID, &title.Title)
-			if err != nil {
-				titleRows.Close()
-				fmt.Printf("Error scanning event title: %v\n", err)
-				http.Error(w, "Error scanning event title: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			report.EventTitles = append(report.EventTitles, title)
-		}
-		titleRows.Close()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
 
-		// Load Part 3 events
-		part3Rows, err := db.Query(`
-            SELECT id, event_summary, trigger_info, start_time, end_time, rca_number 
-            FROM report_events_part3 
-            WHERE report_id = $1 
-            ORDER BY id`, report.ID)
-		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer part3Rows.Close()
+func createEventCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var category EventCategory
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
 
-		for part3Rows.Next() {
-			var event EventPart3
-			var startTime, endTime sql.NullString
-			err := part3Rows.Scan(&event.ID, &event.EventSummary, &event.Trigger, &startTime, &endTime, &event.RCANumber)
-			if err != nil {
-				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if startTime.Valid {
-				// Extract only the time portion from TIMESTAMP values
-				if len(startTime.String) >= 19 { // TIMESTAMP format: "YYYY-MM-DD HH:MM:SS"
-					event.StartTime = startTime.String[11:16] // Extract HH:MM
-				} else {
-					event.StartTime = startTime.String
-				}
+	id, err := catalogStore.CreateEventCategory(r.Context(), category)
+	if err != nil {
+		http.Error(w, "Error creating event category", http.StatusInternalServerError)
+		return
+	}
+	category.ID = id
+	recordAudit(r, "create", "event_category", id, nil, category)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func updateEventCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var category EventCategory
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	category.ID, category.Name = id, strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	before := auditCatalogEntry(r.Context(), "event_category", id)
+	if err := catalogStore.UpdateEventCategory(r.Context(), category); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Event category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating event category", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "event_category", id, before, category)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteEventCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	user, _ := currentUser(r)
+	before := auditCatalogEntry(r.Context(), "event_category", id)
+	if err := catalogStore.DeleteEventCategory(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Event category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting event category", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "event_category", id, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getAffectedServicesHandler(w http.ResponseWriter, r *http.Request) {
+	services, err := catalogStore.ListAffectedServices(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if services == nil {
+		services = []AffectedService{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services)
+}
+
+func createAffectedServiceHandler(w http.ResponseWriter, r *http.Request) {
+	var service AffectedService
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	service.Name = strings.TrimSpace(service.Name)
+	if service.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := catalogStore.CreateAffectedService(r.Context(), service)
+	if err != nil {
+		http.Error(w, "Error creating affected service", http.StatusInternalServerError)
+		return
+	}
+	service.ID = id
+	recordAudit(r, "create", "affected_service", id, nil, service)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func updateAffectedServiceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var service AffectedService
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	service.ID, service.Name = id, strings.TrimSpace(service.Name)
+	if service.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	before := auditCatalogEntry(r.Context(), "affected_service", id)
+	if err := catalogStore.UpdateAffectedService(r.Context(), service); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Affected service not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating affected service", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "affected_service", id, before, service)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteAffectedServiceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	user, _ := currentUser(r)
+	before := auditCatalogEntry(r.Context(), "affected_service", id)
+	if err := catalogStore.DeleteAffectedService(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Affected service not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting affected service", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "affected_service", id, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Health check item handlers
+func getHealthCheckItemsHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := catalogStore.ListHealthCheckItems(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []HealthCheckItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// decodeHealthCheckItem reads the payload of item id (0 for a new item);
+// items are active unless the payload says otherwise.
+func decodeHealthCheckItem(w http.ResponseWriter, r *http.Request, id int) (HealthCheckItem, bool) {
+	item := HealthCheckItem{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return item, false
+	}
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return item, false
+	}
+
+	shifts, err := catalogStore.ListShiftHours(r.Context())
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return item, false
+	}
+	for _, id := range item.ShiftHoursIDs {
+		found := false
+		for _, shift := range shifts {
+			found = found || shift.ID == id
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("Unknown shift %d", id), http.StatusBadRequest)
+			return item, false
+		}
+	}
+	if item.ReadingType == "" {
+		item.ReadingType = readingNone
+	}
+	item.Unit = strings.TrimSpace(item.Unit)
+	if err := item.checkReadingDefinition(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return item, false
+	}
+
+	item.SensorKey = strings.TrimSpace(item.SensorKey)
+	if item.SensorKey != "" {
+		if item.ReadingType == readingNone {
+			http.Error(w, "Items with a sensor need a reading type", http.StatusBadRequest)
+			return item, false
+		}
+		items, err := catalogStore.ListHealthCheckItems(r.Context())
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return item, false
+		}
+		for _, other := range items {
+			if other.SensorKey == item.SensorKey && other.ID != id {
+				http.Error(w, fmt.Sprintf("Sensor %q is already used by %q", item.SensorKey, other.Name), http.StatusBadRequest)
+				return item, false
 			}
-			if endTime.Valid {
-				// Extract only the time portion from TIMESTAMP values
-				if len(endTime.String) >= 19 { // TIMESTAMP format: "YYYY-MM-DD HH:MM:SS"
-					event.EndTime = endTime.String[11:16] // Extract HH:MM
-				} else {
-					event.EndTime = endTime.String
-				}
+		}
+	}
+
+	if item.ShiftHoursIDs == nil {
+		item.ShiftHoursIDs = []int{}
+	}
+	if item.Options == nil {
+		item.Options = []HealthCheckOption{}
+	}
+	return item, true
+}
+
+func createHealthCheckItemHandler(w http.ResponseWriter, r *http.Request) {
+	item, ok := decodeHealthCheckItem(w, r, 0)
+	if !ok {
+		return
+	}
+
+	id, err := catalogStore.CreateHealthCheckItem(r.Context(), item)
+	if err != nil {
+		http.Error(w, "Error creating health check item", http.StatusInternalServerError)
+		return
+	}
+	item.ID = id
+	recordAudit(r, "create", "health_check_item", id, nil, item)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func updateHealthCheckItemHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	item, ok := decodeHealthCheckItem(w, r, id)
+	if !ok {
+		return
+	}
+	item.ID = id
+
+	before := auditCatalogEntry(r.Context(), "health_check_item", id)
+	if err := catalogStore.UpdateHealthCheckItem(r.Context(), item); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Health check item not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating health check item", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "health_check_item", id, before, item)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteHealthCheckItemHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	user, _ := currentUser(r)
+	before := auditCatalogEntry(r.Context(), "health_check_item", id)
+	if err := catalogStore.DeleteHealthCheckItem(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Health check item not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting health check item", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "health_check_item", id, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Sensor handlers
+func recordSensorReadingsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Readings []SensorReading `json:"readings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	items, err := catalogStore.ListHealthCheckItems(r.Context())
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errs := validateSensorReadings(payload.Readings, items); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	token := r.Context().Value(apiTokenKey).(APIToken)
+	if err := sensorStore.RecordSensorReadings(r.Context(), token.ID, payload.Readings); err != nil {
+		fmt.Printf("Error recording sensor readings: %v\n", err)
+		http.Error(w, "Error recording sensor readings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"accepted": len(payload.Readings)})
+}
+
+// getLatestSensorReadingsHandler offers the latest reading of each item within
+// the shift of ?report_date=&shift_hours_id= as suggestions for a new report.
+func getLatestSensorReadingsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var shift *ShiftHours
+	if value := query.Get("shift_hours_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid shift_hours_id", http.StatusBadRequest)
+			return
+		}
+		shifts, err := catalogStore.ListShiftHours(r.Context())
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range shifts {
+			if shifts[i].ID == id {
+				shift = &shifts[i]
 			}
-			report.EventsPart3 = append(report.EventsPart3, event)
 		}
+		if shift == nil {
+			http.Error(w, "Shift not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	window, err := newShiftWindow(query.Get("report_date"), shift)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	readings, err := sensorStore.LatestSensorReadings(r.Context(), window.Start, window.End)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items, err := catalogStore.ListHealthCheckItems(r.Context())
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(healthSuggestions(readings, items))
+}
+
+func getAPITokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := sensorStore.ListAPITokens(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tokens == nil {
+		tokens = []APIToken{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// createAPITokenHandler returns the new token; only its hash is kept, so it
+// cannot be shown again.
+func createAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	token, hash, err := newAPIToken()
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+	user, _ := currentUser(r)
+	id, err := sensorStore.CreateAPIToken(r.Context(), payload.Name, hash, user.ID)
+	if err != nil {
+		http.Error(w, "Error creating API token", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "create", "api_token", id, nil, map[string]string{"name": payload.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "name": payload.Name, "token": token})
+}
+
+func revokeAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := sensorStore.RevokeAPIToken(r.Context(), id); err != nil {
+		if err == errNotFound {
+			http.Error(w, "API token not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error revoking API token", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "revoke", "api_token", id, nil, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RCA handlers
+func getRCAsHandler(w http.ResponseWriter, r *http.Request) {
+	rcas, err := rcaStore.ListRCAs(r.Context())
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rcas == nil {
+		rcas = []RCA{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rcas)
+}
+
+func getRCAHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid RCA ID", http.StatusBadRequest)
+		return
+	}
+
+	rca, err := rcaStore.GetRCA(r.Context(), id)
+	if err == errNotFound {
+		http.Error(w, "RCA not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rca)
+}
+
+// decodeRCA reads an RCA payload. The number is assigned on creation and
+// cannot be changed.
+func decodeRCA(w http.ResponseWriter, r *http.Request) (RCA, bool) {
+	var rca RCA
+	if err := json.NewDecoder(r.Body).Decode(&rca); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return rca, false
+	}
+	rca.Title = strings.TrimSpace(rca.Title)
+	if rca.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return rca, false
+	}
+
+	if rca.OwnerID != nil {
+		found, err := userExists(r.Context(), *rca.OwnerID)
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return rca, false
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("Unknown owner %d", *rca.OwnerID), http.StatusBadRequest)
+			return rca, false
+		}
+	}
+	return rca, true
+}
+
+// userExists reports whether id is a user that is not in the trash.
+func userExists(ctx context.Context, id int) (bool, error) {
+	users, err := userStore.ListUsers(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, user := range users {
+		if user.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func createRCAHandler(w http.ResponseWriter, r *http.Request) {
+	rca, ok := decodeRCA(w, r)
+	if !ok {
+		return
+	}
+
+	user, _ := currentUser(r)
+	id, number, err := rcaStore.CreateRCA(r.Context(), rca, user.ID)
+	if err != nil {
+		fmt.Printf("Error creating RCA: %v\n", err)
+		http.Error(w, "Error creating RCA", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "create", "rca", id, nil, auditState(rcaStore.GetRCA(r.Context(), id)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "number": number})
+}
+
+func updateRCAHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	rca, ok := decodeRCA(w, r)
+	if !ok {
+		return
+	}
+	rca.ID = id
+
+	before := auditState(rcaStore.GetRCA(r.Context(), id))
+	if err := rcaStore.UpdateRCA(r.Context(), rca); err != nil {
+		if err == errNotFound {
+			http.Error(w, "RCA not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating RCA", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "rca", id, before, auditState(rcaStore.GetRCA(r.Context(), id)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteRCAHandler moves an RCA to the trash. Events keep referring to it, so
+// existing reports still show its number.
+func deleteRCAHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	user, _ := currentUser(r)
+	before := auditState(rcaStore.GetRCA(r.Context(), id))
+	if err := rcaStore.DeleteRCA(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "RCA not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting RCA", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "rca", id, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RCA action handlers
+func getRCAActionsHandler(w http.ResponseWriter, r *http.Request) {
+	var filter RCAActionFilter
+	query := r.URL.Query()
+	if value := query.Get("assignee_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid assignee_id", http.StatusBadRequest)
+			return
+		}
+		filter.AssigneeID = id
+	}
+	if query.Get("overdue") == "true" {
+		filter.DueBefore = time.Now().UTC().Format("2006-01-02")
+	}
+
+	actions, err := rcaStore.ListRCAActions(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actions)
+}
+
+// getRCAActionSummaryHandler counts the open and overdue actions of every
+// assignee, unassigned actions last.
+func getRCAActionSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	actions, err := rcaStore.ListRCAActions(r.Context(), RCAActionFilter{})
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summaries := []RCAActionSummary{}
+	index := map[int]int{}
+	for _, action := range actions {
+		key := 0
+		if action.AssigneeID != nil {
+			key = *action.AssigneeID
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, RCAActionSummary{Assignee: action.Assignee})
+		}
+		summaries[i].Open++
+		if action.Overdue {
+			summaries[i].Overdue++
+		}
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i].Assignee, summaries[j].Assignee
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Username < b.Username
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// decodeRCAAction reads an action payload. Actions without a status are open.
+func decodeRCAAction(w http.ResponseWriter, r *http.Request) (RCAAction, bool) {
+	var action RCAAction
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return action, false
+	}
+	action.Description = strings.TrimSpace(action.Description)
+	if action.Description == "" {
+		http.Error(w, "Description is required", http.StatusBadRequest)
+		return action, false
+	}
+	if action.DueDate != "" {
+		if _, err := time.Parse("2006-01-02", action.DueDate); err != nil {
+			http.Error(w, "Invalid due_date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return action, false
+		}
+	}
+	if action.Status == "" {
+		action.Status = rcaActionOpen
+	}
+	if !rcaActionStatuses[action.Status] {
+		http.Error(w, fmt.Sprintf("Unknown status %q", action.Status), http.StatusBadRequest)
+		return action, false
+	}
+
+	if action.AssigneeID != nil {
+		found, err := userExists(r.Context(), *action.AssigneeID)
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return action, false
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("Unknown assignee %d", *action.AssigneeID), http.StatusBadRequest)
+			return action, false
+		}
+	}
+	return action, true
+}
+
+// rcaActionFromPath returns the action addressed by the URL, which must belong
+// to the RCA in the URL.
+func rcaActionFromPath(w http.ResponseWriter, r *http.Request) (RCAAction, bool) {
+	vars := mux.Vars(r)
+	rcaID, _ := strconv.Atoi(vars["id"])
+	id, err := strconv.Atoi(vars["actionId"])
+	if err != nil {
+		http.Error(w, "Invalid action ID", http.StatusBadRequest)
+		return RCAAction{}, false
+	}
+
+	action, err := rcaStore.GetRCAAction(r.Context(), id)
+	if err == errNotFound || (err == nil && action.RCAID != rcaID) {
+		http.Error(w, "Action not found", http.StatusNotFound)
+		return action, false
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return action, false
+	}
+	return action, true
+}
+
+func createRCAActionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rcaID, _ := strconv.Atoi(vars["id"])
+
+	// Admins pass the route policy without the RCA being looked up
+	if _, err := rcaStore.RCAOwner(r.Context(), rcaID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "RCA not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	action, ok := decodeRCAAction(w, r)
+	if !ok {
+		return
+	}
+	action.RCAID = rcaID
+
+	id, err := rcaStore.CreateRCAAction(r.Context(), action)
+	if err != nil {
+		fmt.Printf("Error creating RCA action: %v\n", err)
+		http.Error(w, "Error creating action", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "create", "rca_action", id, nil, auditState(rcaStore.GetRCAAction(r.Context(), id)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func updateRCAActionHandler(w http.ResponseWriter, r *http.Request) {
+	before, ok := rcaActionFromPath(w, r)
+	if !ok {
+		return
+	}
+	action, ok := decodeRCAAction(w, r)
+	if !ok {
+		return
+	}
+	action.ID = before.ID
+
+	if err := rcaStore.UpdateRCAAction(r.Context(), action); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Action not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating action", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "rca_action", action.ID, before, auditState(rcaStore.GetRCAAction(r.Context(), action.ID)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteRCAActionHandler(w http.ResponseWriter, r *http.Request) {
+	before, ok := rcaActionFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := rcaStore.DeleteRCAAction(r.Context(), before.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Action not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting action", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "rca_action", before.ID, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// changeRCAActionStatusHandler lets the assignee, the RCA owner or an admin
+// move an action to another status.
+func changeRCAActionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid action ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !rcaActionStatuses[req.Status] {
+		http.Error(w, fmt.Sprintf("Unknown status %q", req.Status), http.StatusBadRequest)
+		return
+	}
+
+	before, err := rcaStore.GetRCAAction(r.Context(), id)
+	if err == errNotFound {
+		http.Error(w, "Action not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user, _ := currentUser(r)
+	if user.Role != "admin" && (before.AssigneeID == nil || *before.AssigneeID != user.ID) {
+		ownerID, err := rcaStore.RCAOwner(r.Context(), before.RCAID)
+		if err != nil && err != errNotFound {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ownerID != user.ID {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := rcaStore.SetRCAActionStatus(r.Context(), id, req.Status); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Action not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error changing action status", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "change_status", "rca_action", id, before, auditState(rcaStore.GetRCAAction(r.Context(), id)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getRCAEscalationsHandler(w http.ResponseWriter, r *http.Request) {
+	escalations, err := rcaStore.ListRCAEscalations(r.Context())
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escalations)
+}
+
+// Incident handlers
+func getIncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !incidentStatuses[status] {
+		http.Error(w, fmt.Sprintf("Unknown status %q", status), http.StatusBadRequest)
+		return
+	}
+
+	incidents, err := incidentStore.ListIncidents(r.Context(), status)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+func getIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return
+	}
+
+	incident, err := incidentStore.GetIncident(r.Context(), id)
+	if err == errNotFound {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incident)
+}
+
+// decodeIncident reads an incident payload. Incidents without a status are open.
+func decodeIncident(w http.ResponseWriter, r *http.Request) (Incident, bool) {
+	var incident Incident
+	if err := json.NewDecoder(r.Body).Decode(&incident); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return incident, false
+	}
+	incident.Title = strings.TrimSpace(incident.Title)
+	if incident.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return incident, false
+	}
+	if incident.Status == "" {
+		incident.Status = incidentOpen
+	}
+	if !incidentStatuses[incident.Status] {
+		http.Error(w, fmt.Sprintf("Unknown status %q", incident.Status), http.StatusBadRequest)
+		return incident, false
+	}
+	return incident, true
+}
+
+func createIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	incident, ok := decodeIncident(w, r)
+	if !ok {
+		return
+	}
+
+	user, _ := currentUser(r)
+	id, err := incidentStore.CreateIncident(r.Context(), incident, user.ID)
+	if err != nil {
+		fmt.Printf("Error creating incident: %v\n", err)
+		http.Error(w, "Error creating incident", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "create", "incident", id, nil, auditState(incidentStore.GetIncident(r.Context(), id)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func updateIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return
+	}
+
+	incident, ok := decodeIncident(w, r)
+	if !ok {
+		return
+	}
+	incident.ID = id
+
+	before := auditState(incidentStore.GetIncident(r.Context(), id))
+	if err := incidentStore.UpdateIncident(r.Context(), incident); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Incident not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating incident", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "update", "incident", id, before, auditState(incidentStore.GetIncident(r.Context(), id)))
 
-		// Check for errors from iterating over part 3 rows
-		if err = part3Rows.Err(); err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteIncidentHandler moves an incident to the trash. Its events keep
+// referring to it until they are attached to another incident.
+func deleteIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	user, _ := currentUser(r)
+	before := auditState(incidentStore.GetIncident(r.Context(), id))
+	if err := incidentStore.DeleteIncident(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Incident not found", http.StatusNotFound)
 			return
 		}
+		http.Error(w, "Error deleting incident", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "delete", "incident", id, before, nil)
 
-		// Load Part 4 events
-		part4Rows, err := db.Query(`
-            SELECT id, event_summary, trigger_info, start_time, end_time 
-            FROM report_events_part4 
-            WHERE report_id = $1 
-            ORDER BY id`, report.ID)
-		if err != nil {
-			fmt.Printf("Error loading Part 4 events: %v\n", err)
-			http.Error(w, "Error loading Part 4 events: "+err.Error(), http.StatusInternalServerError)
+	w.WriteHeader(http.StatusOK)
+}
+
+func attachIncidentEventHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		EventID int `json:"event_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	before, err := incidentStore.GetIncident(r.Context(), id)
+	if err == errNotFound {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = incidentStore.AttachIncidentEvent(r.Context(), id, req.EventID)
+	var attached *eventAttachedError
+	switch {
+	case err == errNotFound:
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	case errors.As(err, &attached):
+		http.Error(w, fmt.Sprintf("Event %d already belongs to incident %d", req.EventID, attached.IncidentID), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Error attaching event", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "attach_event", "incident", id, before, auditState(incidentStore.GetIncident(r.Context(), id)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func detachIncidentEventHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return
+	}
+	eventID, err := strconv.Atoi(vars["eventId"])
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := incidentStore.GetIncident(r.Context(), id)
+	if err == errNotFound {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := incidentStore.DetachIncidentEvent(r.Context(), id, eventID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Event is not part of this incident", http.StatusNotFound)
 			return
 		}
+		http.Error(w, "Error detaching event", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "detach_event", "incident", id, before, auditState(incidentStore.GetIncident(r.Context(), id)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Report handlers
+const (
+	defaultReportPageSize = 50
+	maxReportPageSize     = 500
+)
+
+func getReportsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseReportFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reports, total, err := reportStore.ListReports(r.Context(), filter)
+	if err != nil {
+		fmt.Printf("Error loading reports: %v\n", err)
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if reports == nil {
+		reports = []DailyReport{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReportPage{Reports: reports, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// parseReportFilter reads the list filters, sort order and page from the query string.
+func parseReportFilter(query url.Values) (ReportFilter, error) {
+	filter := ReportFilter{
+		Date:     query.Get("date"),
+		DateFrom: query.Get("date_from"),
+		DateTo:   query.Get("date_to"),
+		Sort:     query.Get("sort"),
+		Limit:    defaultReportPageSize,
+	}
+
+	for _, date := range []string{filter.Date, filter.DateFrom, filter.DateTo} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return filter, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
 
-		for part4Rows.Next() {
-			var event EventPart4
-			var startTime, endTime sql.NullString
-			err := part4Rows.Scan(&event.ID, &event.EventSummary, &event.Trigger, &startTime, &endTime)
-			if err != nil {
-				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	ints := map[string]*int{
+		"site_id":             &filter.SiteID,
+		"shift_hours_id":      &filter.ShiftHoursID,
+		"created_by":          &filter.CreatedBy,
+		"shift_manager_id":    &filter.ShiftManagerID,
+		"event_title_id":      &filter.EventTitleID,
+		"severity_id":         &filter.SeverityID,
+		"category_id":         &filter.CategoryID,
+		"affected_service_id": &filter.AffectedServiceID,
+		"limit":               &filter.Limit,
+		"offset":              &filter.Offset,
+	}
+	for name, dst := range ints {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid %s %q", name, value)
+		}
+		*dst = n
+	}
+	if filter.Limit == 0 || filter.Limit > maxReportPageSize {
+		return filter, fmt.Errorf("limit must be between 1 and %d", maxReportPageSize)
+	}
+
+	bools := map[string]**bool{
+		"health_failed":   &filter.HealthFailed,
+		"has_rca_events":  &filter.HasRCAEvents,
+		"has_open_events": &filter.HasOpenEvents,
+	}
+	for name, dst := range bools {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s %q", name, value)
+		}
+		*dst = &b
+	}
+
+	if status := query.Get("status"); status != "" {
+		known := false
+		for _, s := range reportStatuses {
+			known = known || s == status
+		}
+		if !known {
+			return filter, fmt.Errorf("invalid status %q", status)
+		}
+		filter.Status = status
+	}
+
+	if filter.Sort != "" {
+		if _, ok := reportSortColumns[strings.TrimPrefix(filter.Sort, "-")]; !ok {
+			return filter, fmt.Errorf("invalid sort %q", filter.Sort)
+		}
+	}
+	return filter, nil
+}
+
+func getReportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := reportStore.GetReport(r.Context(), id)
+	if err != nil {
+		if err == errNotFound {
+			http.Error(w, "Report not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", reportETag(report.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// newReportDraftHandler returns the draft of the report for the shift after
+// previous_report_id, or after the latest report of site_id (the main site by
+// default).
+func newReportDraftHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	previousID := 0
+	if value := query.Get("previous_report_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid previous_report_id", http.StatusBadRequest)
+			return
+		}
+		previousID = id
+	} else {
+		siteID := defaultSiteID
+		if value := query.Get("site_id"); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil || id <= 0 {
+				http.Error(w, "Invalid site_id", http.StatusBadRequest)
 				return
 			}
-			if startTime.Valid {
-				// Extract only the time portion from TIMESTAMP values
-				if len(startTime.String) >= 19 { // TIMESTAMP format: "YYYY-MM-DD HH:MM:SS"
-					event.StartTime = startTime.String[11:16] // Extract HH:MM
-				} else {
-					event.StartTime = startTime.String
-				}
-			}
-			if endTime.Valid {
-				// Extract only the time portion from TIMESTAMP values
-				if len(endTime.String) >= 19 { // TIMESTAMP format: "YYYY-MM-DD HH:MM:SS"
-					event.EndTime = endTime.String[11:16] // Extract HH:MM
-				} else {
-					event.EndTime = endTime.String
-				}
+			siteID = id
+		}
+		id, err := reportStore.LatestReportID(r.Context(), siteID)
+		if err != nil {
+			if err == errNotFound {
+				http.Error(w, "No previous report for this site", http.StatusNotFound)
+			} else {
+				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 			}
-			report.EventsPart4 = append(report.EventsPart4, event)
+			return
+		}
+		previousID = id
+	}
+
+	previous, err := reportStore.GetReport(r.Context(), previousID)
+	if err != nil {
+		if err == errNotFound {
+			http.Error(w, "Report not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		}
-		part4Rows.Close()
+		return
+	}
+	shifts, err := catalogStore.ListShiftHours(r.Context())
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	draft, err := reportDraft(previous, shifts)
+	if err != nil {
+		fmt.Printf("Error building draft after report %d: %v\n", previousID, err)
+		http.Error(w, "Error building draft: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(draft)
+}
+
+func createReportHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+
+	var reportData ReportInput
+	if err := json.NewDecoder(r.Body).Decode(&reportData); err != nil {
+		fmt.Printf("Error decoding report creation JSON: %v\n", err)
+		http.Error(w, "Invalid JSON in report creation: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validReport(w, r, &reportData) {
+		return
+	}
+	reportID, err := reportStore.CreateReport(r.Context(), reportData, user.ID)
+	var duplicate *duplicateReportError
+	if errors.As(err, &duplicate) {
+		writeDuplicateReport(w, duplicate)
+		return
+	}
+	if errors.Is(err, errInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Database error creating report: %v\n", err)
+		http.Error(w, "Error creating report: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "create", "report", reportID, nil, auditState(reportStore.GetReport(r.Context(), reportID)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": reportID})
+}
+
+func updateReportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var reportData ReportInput
+	if err := json.NewDecoder(r.Body).Decode(&reportData); err != nil {
+		fmt.Printf("Error decoding report update JSON: %v\n", err)
+		http.Error(w, "Invalid JSON in report update: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Ownership is enforced by the route policy, the status and version here,
+	// so that a stale payload is not answered with validation errors
+	if !reportEditable(w, r, id) {
+		return
+	}
+	current, err := reportStore.GetReport(r.Context(), id)
+	if err != nil {
+		writeReportUpdateError(w, r, id, err)
+		return
+	}
+	if current.Version != version {
+		writeVersionMismatch(w, r, id)
+		return
+	}
+	if !validReport(w, r, &reportData, revisionInput(current)) {
+		return
+	}
+
+	user, _ := currentUser(r)
+	newVersion, err := reportStore.UpdateReport(r.Context(), id, reportData, user.ID, version)
+	if err != nil {
+		writeReportUpdateError(w, r, id, err)
+		return
+	}
+	recordAudit(r, "update", "report", id, current, auditState(reportStore.GetReport(r.Context(), id)))
+
+	w.Header().Set("ETag", reportETag(newVersion))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Report updated successfully", "version": newVersion})
+}
+
+func reportETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion reads the report version an update is based on from the
+// If-Match header. The version must be given: "*" would overwrite concurrent
+// edits unseen.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		http.Error(w, "If-Match header with the report's ETag is required", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		http.Error(w, "Invalid If-Match header "+header, http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
+
+// writeVersionMismatch answers 412 Precondition Failed with the report as it
+// is now, so the client can merge its changes.
+func writeVersionMismatch(w http.ResponseWriter, r *http.Request, id int) {
+	report, err := reportStore.GetReport(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", reportETag(report.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":           "Report has been changed by someone else",
+		"current_version": report.Version,
+		"current":         report,
+	})
+}
+
+func writeReportUpdateError(w http.ResponseWriter, r *http.Request, id int, err error) {
+	if err == errVersionMismatch {
+		writeVersionMismatch(w, r, id)
+		return
+	}
+	if err == errNotFound {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err == errReportLocked {
+		http.Error(w, "Report is locked and can no longer be changed", http.StatusConflict)
+		return
+	}
+	var duplicate *duplicateReportError
+	if errors.As(err, &duplicate) {
+		writeDuplicateReport(w, duplicate)
+		return
+	}
+	if errors.Is(err, errInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("Error updating report %d: %v\n", id, err)
+	http.Error(w, "Failed to update report: "+err.Error(), http.StatusInternalServerError)
+}
+
+// reportEditable checks that the current user may still change the report in
+// its current status and writes the error response if not.
+func reportEditable(w http.ResponseWriter, r *http.Request, id int) bool {
+	status, err := reportStore.ReportStatus(r.Context(), id)
+	if err == errNotFound {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	user, _ := currentUser(r)
+	if err := checkReportEditable(user, status); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// writeDuplicateReport answers 409 Conflict with the report that already
+// covers the site, date and shift.
+func writeDuplicateReport(w http.ResponseWriter, duplicate *duplicateReportError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":              "A report already exists for this site, date and shift",
+		"existing_report_id": duplicate.ExistingID,
+		"existing_report":    fmt.Sprintf("/api/reports/%d", duplicate.ExistingID),
+	})
+}
+
+// validReport validates a report payload, rating its health check readings,
+// and writes the 422 (or 500) response when it cannot be saved. saved are the
+// versions of the report already stored (see validateReport).
+func validReport(w http.ResponseWriter, r *http.Request, input *ReportInput, saved ...ReportInput) bool {
+	errs, err := validateReport(r.Context(), input, saved...)
+	if err != nil {
+		fmt.Printf("Error validating report: %v\n", err)
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return false
+	}
+	return true
+}
+
+func deleteReportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
 
-		reports = append(reports, report)
+	if !reportEditable(w, r, id) {
+		return
 	}
-
-	// Check for errors from iterating over rows
-	if err = rows.Err(); err != nil {
-		fmt.Printf("Rows iteration error: %v\n", err)
-		http.Error(w, "Rows iteration error: "+err.Error(), http.StatusInternalServerError)
+	user, _ := currentUser(r)
+	before := auditState(reportStore.GetReport(r.Context(), id))
+	if err := reportStore.DeleteReport(r.Context(), id, user.ID); err != nil {
+		if err == errNotFound {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		if err == errReportLocked {
+			http.Error(w, "Report is locked and can no longer be deleted", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error deleting report", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "delete", "report", id, before, nil)
 
-	fmt.Printf("Returning %d reports\n", len(reports))
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(reports)
+	w.WriteHeader(http.StatusOK)
 }
 
-func getReportHandler(w http.ResponseWriter, r *http.Request) {
+// Report status handlers
+func changeReportStatusHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -627,294 +2245,226 @@ func getReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var report DailyReport
-	var shiftID sql.NullInt64
-	var shiftName, shiftStart, shiftEnd sql.NullString
-	var createdByUser User
-
-	err = db.QueryRow(`
-        SELECT dr.id, dr.report_date, dr.health_power_sources, dr.health_humidity_temp, 
-               dr.health_fire_system, dr.created_at,
-               u.id, u.username, u.full_name, u.role,
-               sh.id, sh.name, sh.start_time, sh.end_time
-        FROM daily_reports dr
-        JOIN users u ON dr.created_by = u.id
-        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id
-        WHERE dr.id = $1`, id).Scan(
-		&report.ID, &report.ReportDate, &report.HealthPowerSources,
-		&report.HealthHumidityTemp, &report.HealthFireSystem, &report.CreatedAt,
-		&createdByUser.ID, &createdByUser.Username, &createdByUser.FullName, &createdByUser.Role,
-		&shiftID, &shiftName, &shiftStart, &shiftEnd)
+	var req struct {
+		Status  string `json:"status"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
 
+	ownerID, err := reportStore.ReportOwner(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Report not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-		}
+		writeReportStatusError(w, id, err)
 		return
 	}
-
-	report.CreatedBy = createdByUser
-
-	if shiftID.Valid {
-		report.ShiftHours = &ShiftHours{
-			ID:        int(shiftID.Int64),
-			Name:      shiftName.String,
-			StartTime: shiftStart.String,
-			EndTime:   shiftEnd.String,
-		}
+	status, err := reportStore.ReportStatus(r.Context(), id)
+	if err != nil {
+		writeReportStatusError(w, id, err)
+		return
 	}
 
-	// Load shift managers
-	managerRows, err := db.Query(`
-        SELECT u.id, u.username, u.full_name, u.role 
-        FROM users u 
-        JOIN report_shift_managers rsm ON u.id = rsm.user_id 
-        WHERE rsm.report_id = $1`, report.ID)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	user, _ := currentUser(r)
+	transition, ok := findTransition(status, req.Status)
+	switch {
+	case !ok:
+		http.Error(w, fmt.Sprintf("Cannot change a %s report to %q", status, req.Status), http.StatusConflict)
+		return
+	case !canTransition(user, ownerID, transition):
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	case transition.CommentRequired && req.Comment == "":
+		writeValidationErrors(w, ValidationErrors{{Field: "comment", Message: "a comment is required when the report is " + req.Status}})
 		return
 	}
-	defer managerRows.Close()
 
-	for managerRows.Next() {
-		var manager User
-		err := managerRows.Scan(&manager.ID, &manager.Username, &manager.FullName, &manager.Role)
-		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		report.ShiftManagers = append(report.ShiftManagers, manager)
+	if err := reportStore.SetReportStatus(r.Context(), id, status, req.Status, req.Comment, user.ID); err != nil {
+		writeReportStatusError(w, id, err)
+		return
 	}
+	recordAudit(r, "change_status", "report", id, map[string]string{"status": status},
+		map[string]string{"status": req.Status, "comment": req.Comment})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": req.Status})
+}
 
-	// Check for errors from iterating over manager rows
-	if err = managerRows.Err(); err != nil {
+func writeReportStatusError(w http.ResponseWriter, id int, err error) {
+	switch err {
+	case errNotFound:
+		http.Error(w, "Report not found", http.StatusNotFound)
+	case errStatusChanged:
+		http.Error(w, "Report status has changed, reload and try again", http.StatusConflict)
+	default:
+		fmt.Printf("Error changing status of report %d: %v\n", id, err)
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func getReportStatusHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
 		return
 	}
 
-	// Load event titles
-	titleRows, err := db.Query(`
-        SELECT et.id, et.title 
-        FROM event_titles et 
-        JOIN report_event_titles ret ON et.id = ret.event_title_id 
-        WHERE ret.report_id = $1`, report.ID)
+	history, err := reportStore.ListStatusHistory(r.Context(), id)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer titleRows.Close()
-
-	for titleRows.Next() {
-		var title EventTitle
-		err := titleRows.Scan(&title.ID, &title.Title)
-		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		report.EventTitles = append(report.EventTitles, title)
+	if history == nil {
+		history = []StatusChange{}
 	}
 
-	// Check for errors from iterating over title rows
-	if err = titleRows.Err(); err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// Revision handlers
+func getReportRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
 		return
 	}
 
-	// Load Part 3 events
-	part3Rows, err := db.Query(`
-        SELECT id, event_summary, trigger_info, start_time, end_time, rca_number 
-        FROM report_events_part3 
-        WHERE report_id = $1 
-        ORDER BY id`, report.ID)
+	revisions, err := reportStore.ListRevisions(r.Context(), id)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer part3Rows.Close()
-
-	for part3Rows.Next() {
-		var event EventPart3
-		var startTime, endTime sql.NullString
-		err := part3Rows.Scan(&event.ID, &event.EventSummary, &event.Trigger, &startTime, &endTime, &event.RCANumber)
-		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if startTime.Valid {
-			event.StartTime = startTime.String
-		}
-		if endTime.Valid {
-			event.EndTime = endTime.String
-		}
-		report.EventsPart3 = append(report.EventsPart3, event)
+	if revisions == nil {
+		revisions = []ReportRevision{}
 	}
 
-	// Check for errors from iterating over part 3 rows
-	if err = part3Rows.Err(); err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func getReportRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+	revision, ok := loadRevision(w, r, id, vars["revision"])
+	if !ok {
 		return
 	}
 
-	// Load Part 4 events
-	part4Rows, err := db.Query(`
-        SELECT id, event_summary, trigger_info, start_time, end_time 
-        FROM report_events_part4 
-        WHERE report_id = $1 
-        ORDER BY id`, report.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// getReportRevisionDiffHandler compares revisions ?from=N&to=M of a report.
+func getReportRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
 		return
 	}
-	defer part4Rows.Close()
-
-	for part4Rows.Next() {
-		var event EventPart4
-		var startTime, endTime sql.NullString
-		err := part4Rows.Scan(&event.ID, &event.EventSummary, &event.Trigger, &startTime, &endTime)
-		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if startTime.Valid {
-			event.StartTime = startTime.String
-		}
-		if endTime.Valid {
-			event.EndTime = endTime.String
-		}
-		report.EventsPart4 = append(report.EventsPart4, event)
+	query := r.URL.Query()
+	if query.Get("from") == "" || query.Get("to") == "" {
+		http.Error(w, "Both from and to revisions are required", http.StatusBadRequest)
+		return
 	}
-
-	// Check for errors from iterating over part 4 rows
-	if err = part4Rows.Err(); err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	from, ok := loadRevision(w, r, id, query.Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := loadRevision(w, r, id, query.Get("to"))
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(report)
+	json.NewEncoder(w).Encode(diffRevisions(from, to))
 }
 
-func createReportHandler(w http.ResponseWriter, r *http.Request) {
-	session, _ := store.Get(r, "session")
-	userID := session.Values["user_id"].(int)
-
-	var reportData struct {
-		ReportDate         string       `json:"report_date"`
-		ShiftHoursID       *int         `json:"shift_hours_id"`
-		ShiftManagerIDs    []int        `json:"shift_manager_ids"`
-		EventTitleIDs      []int        `json:"event_title_ids"`
-		HealthPowerSources bool         `json:"health_power_sources"`
-		HealthHumidityTemp bool         `json:"health_humidity_temp"`
-		HealthFireSystem   bool         `json:"health_fire_system"`
-		EventsPart3        []EventPart3 `json:"events_part3"`
-		EventsPart4        []EventPart4 `json:"events_part4"`
+// restoreReportRevisionHandler saves an old revision as the report's current
+// contents. The restore is itself recorded as a new revision.
+func restoreReportRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&reportData); err != nil {
-		fmt.Printf("Error decoding report creation JSON: %v\n", err)
-		http.Error(w, "Invalid JSON in report creation: "+err.Error(), http.StatusBadRequest)
+	revision, ok := loadRevision(w, r, id, vars["revision"])
+	if !ok {
 		return
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	if !reportEditable(w, r, id) {
 		return
 	}
-	defer tx.Rollback()
-
-	var reportID int
-	err = tx.QueryRow(`
-        INSERT INTO daily_reports (report_date, shift_hours_id, health_power_sources, 
-                                 health_humidity_temp, health_fire_system, created_by) 
-        VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
-		reportData.ReportDate, reportData.ShiftHoursID, reportData.HealthPowerSources,
-		reportData.HealthHumidityTemp, reportData.HealthFireSystem, userID).Scan(&reportID)
-
+	current, err := reportStore.GetReport(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Error creating report", http.StatusInternalServerError)
+		writeReportUpdateError(w, r, id, err)
 		return
 	}
 
-	// Insert shift managers
-	for _, managerID := range reportData.ShiftManagerIDs {
-		_, err = tx.Exec("INSERT INTO report_shift_managers (report_id, user_id) VALUES ($1, $2)",
-			reportID, managerID)
-		if err != nil {
-			http.Error(w, "Error adding shift managers", http.StatusInternalServerError)
-			return
-		}
+	// Catalog entries the revision refers to may have been deleted since
+	input := revisionInput(*revision.Snapshot)
+	if !validReport(w, r, &input, revisionInput(current), input) {
+		return
 	}
-
-	// Insert event titles
-	for _, titleID := range reportData.EventTitleIDs {
-		_, err = tx.Exec("INSERT INTO report_event_titles (report_id, event_title_id) VALUES ($1, $2)",
-			reportID, titleID)
-		if err != nil {
-			http.Error(w, "Error adding event titles", http.StatusInternalServerError)
-			return
-		}
+	user, _ := currentUser(r)
+	if _, err := reportStore.UpdateReport(r.Context(), id, input, user.ID, 0); err != nil {
+		writeReportUpdateError(w, r, id, err)
+		return
 	}
+	recordAudit(r, "restore_revision", "report", id, current, auditState(reportStore.GetReport(r.Context(), id)))
 
-	// Insert Part 3 events
-	for _, event := range reportData.EventsPart3 {
-		// Convert time-only values to TIMESTAMP format if needed
-		startTime := event.StartTime
-		endTime := event.EndTime
-		
-		// If we have time-only values (HH:MM format), convert them to TIMESTAMP
-		if startTime != "" && len(startTime) <= 5 { // HH:MM format
-			startTime = "1970-01-01 " + startTime + ":00"
-		}
-		if endTime != "" && len(endTime) <= 5 { // HH:MM format
-			endTime = "1970-01-01 " + endTime + ":00"
-		}
-		
-		_, err = tx.Exec(`
-            INSERT INTO report_events_part3 (report_id, event_summary, trigger_info, start_time, end_time, rca_number) 
-            VALUES ($1, $2, $3, $4, $5, $6)`,
-			reportID, event.EventSummary, event.Trigger, startTime, endTime, event.RCANumber)
-		if err != nil {
-			fmt.Printf("Database error adding Part 3 events: %v\n", err)
-			http.Error(w, "Error adding Part 3 events: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Report restored successfully",
+		"restored_from": revision.Revision,
+	})
+}
 
-	// Insert Part 4 events
-	for _, event := range reportData.EventsPart4 {
-		// Convert time-only values to TIMESTAMP format if needed
-		startTime := event.StartTime
-		endTime := event.EndTime
-		
-		// If we have time-only values (HH:MM format), convert them to TIMESTAMP
-		if startTime != "" && len(startTime) <= 5 { // HH:MM format
-			startTime = "1970-01-01 " + startTime + ":00"
-		}
-		if endTime != "" && len(endTime) <= 5 { // HH:MM format
-			endTime = "1970-01-01 " + endTime + ":00"
-		}
-		
-		_, err = tx.Exec(`
-            INSERT INTO report_events_part4 (report_id, event_summary, trigger_info, start_time, end_time) 
-            VALUES ($1, $2, $3, $4, $5)`,
-			reportID, event.EventSummary, event.Trigger, startTime, endTime)
-		if err != nil {
-			fmt.Printf("Database error adding Part 4 events: %v\n", err)
-			http.Error(w, "Error adding Part 4 events: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+func loadRevision(w http.ResponseWriter, r *http.Request, reportID int, value string) (ReportRevision, bool) {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		http.Error(w, "Invalid revision "+value, http.StatusBadRequest)
+		return ReportRevision{}, false
+	}
+	revision, err := reportStore.GetRevision(r.Context(), reportID, number)
+	if err == errNotFound {
+		http.Error(w, fmt.Sprintf("Revision %d not found", number), http.StatusNotFound)
+		return revision, false
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return revision, false
 	}
+	return revision, true
+}
 
-	tx.Commit()
+// Duplicate report handlers
+func getDuplicateReportsHandler(w http.ResponseWriter, r *http.Request) {
+	groups, err := reportStore.ListDuplicateReports(r.Context())
+	if err != nil {
+		fmt.Printf("Error finding duplicate reports: %v\n", err)
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if groups == nil {
+		groups = []DuplicateGroup{}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"id": reportID})
+	json.NewEncoder(w).Encode(groups)
 }
 
-func updateReportHandler(w http.ResponseWriter, r *http.Request) {
+func mergeDuplicateReportsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -922,250 +2472,284 @@ func updateReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var reportData struct {
-		ReportDate         string       `json:"report_date"`
-		ShiftHoursID       *int         `json:"shift_hours_id"`
-		ShiftManagerIDs    []int        `json:"shift_manager_ids"`
-		EventTitleIDs      []int        `json:"event_title_ids"`
-		HealthPowerSources bool         `json:"health_power_sources"`
-		HealthHumidityTemp bool         `json:"health_humidity_temp"`
-		HealthFireSystem   bool         `json:"health_fire_system"`
-		EventsPart3        []EventPart3 `json:"events_part3"`
-		EventsPart4        []EventPart4 `json:"events_part4"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&reportData); err != nil {
-		fmt.Printf("Error decoding report update JSON: %v\n", err)
-		http.Error(w, "Invalid JSON in report update: "+err.Error(), http.StatusBadRequest)
+	user, _ := currentUser(r)
+	before := auditState(reportStore.GetReport(r.Context(), id))
+	merged, err := reportStore.MergeDuplicateReports(r.Context(), id, user.ID)
+	if err != nil {
+		if err == errNotFound {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		var notMergeable *mergeStatusError
+		if errors.As(err, &notMergeable) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		fmt.Printf("Error merging duplicates into report %d: %v\n", id, err)
+		http.Error(w, "Error merging reports: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "merge_duplicates", "report", id, before, auditState(reportStore.GetReport(r.Context(), id)))
 
-	// Check if report exists and user has permission to update it
-	var existingReport struct {
-		ID        int `json:"id"`
-		CreatedBy int `json:"created_by"`
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"report_id": id, "merged": merged})
+}
+
+// Trash handlers
+func getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	itemType := r.URL.Query().Get("type")
+	if _, ok := trashTypes[itemType]; itemType != "" && !ok {
+		http.Error(w, "Invalid type "+itemType, http.StatusBadRequest)
+		return
 	}
 
-	err = db.QueryRow("SELECT id, created_by FROM daily_reports WHERE id = $1", id).Scan(&existingReport.ID, &existingReport.CreatedBy)
+	items, err := trashStore.ListTrash(r.Context(), itemType)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Report not found", http.StatusNotFound)
-		} else {
-			fmt.Printf("Database error checking report existence: %v\n", err)
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-		}
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if items == nil {
+		items = []TrashItem{}
+	}
 
-	// Get user info from session
-	session, err := store.Get(r, "session")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+func restoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemType := vars["type"]
+	if _, ok := trashTypes[itemType]; !ok {
+		http.Error(w, "Invalid type "+itemType, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		fmt.Printf("Session error: %v\n", err)
-		http.Error(w, "Session error: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
 
-	userID, ok := session.Values["user_id"].(int)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	err = trashStore.RestoreTrash(r.Context(), itemType, id)
+	var duplicate *duplicateReportError
+	if errors.As(err, &duplicate) {
+		writeDuplicateReport(w, duplicate)
 		return
 	}
-
-	userRole, ok := session.Values["role"].(string)
-	if !ok {
-		fmt.Printf("Session role error\n")
-		http.Error(w, "Session error: Role not found in session", http.StatusInternalServerError)
+	if err == errNotFound {
+		http.Error(w, "Item not found in the trash", http.StatusNotFound)
 		return
 	}
-
-	// Check if user can update this report (owner or admin)
-	if existingReport.CreatedBy != userID && userRole != "admin" {
-		fmt.Printf("Permission denied: User %d tried to update report %d created by %d\n", userID, id, existingReport.CreatedBy)
-		http.Error(w, "Permission denied", http.StatusForbidden)
+	if err != nil {
+		fmt.Printf("Error restoring %s %d: %v\n", itemType, id, err)
+		http.Error(w, "Error restoring item: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "restore", itemType, id, nil, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"type": itemType, "id": id, "message": "Restored"})
+}
 
-	tx, err := db.Begin()
+func getReportChainHandler(w http.ResponseWriter, r *http.Request) {
+	links, err := chainStore.ListChain(r.Context())
 	if err != nil {
-		fmt.Printf("Error beginning transaction: %v\n", err)
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer tx.Rollback()
+	if links == nil {
+		links = []ChainLink{}
+	}
 
-	// Update main report
-	_, err = tx.Exec(`
-		UPDATE daily_reports SET 
-			report_date = $1, 
-			shift_hours_id = $2, 
-			health_power_sources = $3, 
-			health_humidity_temp = $4, 
-			health_fire_system = $5
-		WHERE id = $6`,
-		reportData.ReportDate, reportData.ShiftHoursID, reportData.HealthPowerSources,
-		reportData.HealthHumidityTemp, reportData.HealthFireSystem, id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
 
+// verifyReportChainHandler walks the whole chain. A broken chain is still a
+// successful check: the result lists what failed.
+func verifyReportChainHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := chainStore.VerifyChain(r.Context())
 	if err != nil {
-		fmt.Printf("Error updating daily_reports: %v\n", err)
-		http.Error(w, "Failed to update report: "+err.Error(), http.StatusInternalServerError)
+		fmt.Printf("Error verifying report chain: %v\n", err)
+		http.Error(w, "Error verifying report chain: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Delete existing relationships
-	_, err = tx.Exec("DELETE FROM report_shift_managers WHERE report_id = $1", id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
 	if err != nil {
-		fmt.Printf("Error deleting report_shift_managers: %v\n", err)
-		http.Error(w, "Failed to update shift managers: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	_, err = tx.Exec("DELETE FROM report_event_titles WHERE report_id = $1", id)
+	entries, total, err := auditStore.ListAudit(r.Context(), filter)
 	if err != nil {
-		fmt.Printf("Error deleting report_event_titles: %v\n", err)
-		http.Error(w, "Failed to update event titles: "+err.Error(), http.StatusInternalServerError)
+		fmt.Printf("Error loading audit log: %v\n", err)
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditPage{Entries: entries, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
 
-	_, err = tx.Exec("DELETE FROM report_events_part3 WHERE report_id = $1", id)
+// exportAuditLogHandler writes every entry matching the filters as CSV; limit
+// and offset are ignored.
+func exportAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
 	if err != nil {
-		fmt.Printf("Error deleting report_events_part3: %v\n", err)
-		http.Error(w, "Failed to update Part 3 events: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	filter.Limit, filter.Offset = 0, 0
 
-	_, err = tx.Exec("DELETE FROM report_events_part4 WHERE report_id = $1", id)
+	entries, _, err := auditStore.ListAudit(r.Context(), filter)
 	if err != nil {
-		fmt.Printf("Error deleting report_events_part4: %v\n", err)
-		http.Error(w, "Failed to update Part 4 events: "+err.Error(), http.StatusInternalServerError)
+		fmt.Printf("Error exporting audit log: %v\n", err)
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Re-insert shift managers
-	for _, managerID := range reportData.ShiftManagerIDs {
-		_, err = tx.Exec("INSERT INTO report_shift_managers (report_id, user_id) VALUES ($1, $2)", id, managerID)
-		if err != nil {
-			fmt.Printf("Error inserting report_shift_managers: %v\n", err)
-			http.Error(w, "Failed to update shift managers: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log.csv"`)
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "occurred_at", "actor_id", "actor_username", "action", "entity_type", "entity_id",
+		"before", "after", "ip_address", "user_agent"})
+	for _, entry := range entries {
+		out.Write([]string{
+			strconv.Itoa(entry.ID), entry.OccurredAt, optionalID(entry.ActorID), entry.ActorUsername,
+			entry.Action, entry.EntityType, optionalID(entry.EntityID),
+			string(entry.Before), string(entry.After), entry.IPAddress, entry.UserAgent,
+		})
 	}
-
-	// Re-insert event titles
-	for _, titleID := range reportData.EventTitleIDs {
-		_, err = tx.Exec("INSERT INTO report_event_titles (report_id, event_title_id) VALUES ($1, $2)", id, titleID)
-		if err != nil {
-			fmt.Printf("Error inserting report_event_titles: %v\n", err)
-			http.Error(w, "Failed to update event titles: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		fmt.Printf("Error writing audit log export: %v\n", err)
 	}
+}
 
-	// Re-insert Part 3 events
-	for _, event := range reportData.EventsPart3 {
-		// Convert time-only values to TIMESTAMP format if needed
-		startTime := event.StartTime
-		endTime := event.EndTime
-		
-		// If we have time-only values (HH:MM format), convert them to TIMESTAMP
-		if startTime != "" && len(startTime) <= 5 { // HH:MM format
-			startTime = "1970-01-01 " + startTime + ":00"
-		}
-		if endTime != "" && len(endTime) <= 5 { // HH:MM format
-			endTime = "1970-01-01 " + endTime + ":00"
-		}
-		
-		_, err = tx.Exec(`
-			INSERT INTO report_events_part3 (report_id, event_summary, trigger_info, start_time, end_time, rca_number) 
-			VALUES ($1, $2, $3, $4, $5, $6)`,
-			id, event.EventSummary, event.Trigger, startTime, endTime, event.RCANumber)
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
 
-		if err != nil {
-			fmt.Printf("Error inserting report_events_part3: %v\n", err)
-			http.Error(w, "Failed to update Part 3 events: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+// parseAuditFilter reads the audit log filters and page from the query string.
+func parseAuditFilter(query url.Values) (AuditFilter, error) {
+	filter := AuditFilter{
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+		DateFrom:   query.Get("date_from"),
+		DateTo:     query.Get("date_to"),
+		Limit:      defaultReportPageSize,
 	}
 
-	// Re-insert Part 4 events
-	for _, event := range reportData.EventsPart4 {
-		// Convert time-only values to TIMESTAMP format if needed
-		startTime := event.StartTime
-		endTime := event.EndTime
-		
-		// If we have time-only values (HH:MM format), convert them to TIMESTAMP
-		if startTime != "" && len(startTime) <= 5 { // HH:MM format
-			startTime = "1970-01-01 " + startTime + ":00"
+	for _, date := range []string{filter.DateFrom, filter.DateTo} {
+		if date == "" {
+			continue
 		}
-		if endTime != "" && len(endTime) <= 5 { // HH:MM format
-			endTime = "1970-01-01 " + endTime + ":00"
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return filter, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
 		}
-		
-		_, err = tx.Exec(`
-			INSERT INTO report_events_part4 (report_id, event_summary, trigger_info, start_time, end_time) 
-			VALUES ($1, $2, $3, $4, $5)`,
-			id, event.EventSummary, event.Trigger, startTime, endTime)
+	}
 
-		if err != nil {
-			fmt.Printf("Error inserting report_events_part4: %v\n", err)
-			http.Error(w, "Failed to update Part 4 events: "+err.Error(), http.StatusInternalServerError)
-			return
+	ints := map[string]*int{
+		"actor_id":  &filter.ActorID,
+		"entity_id": &filter.EntityID,
+		"limit":     &filter.Limit,
+		"offset":    &filter.Offset,
+	}
+	for name, dst := range ints {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid %s %q", name, value)
 		}
+		*dst = n
 	}
-
-	if err = tx.Commit(); err != nil {
-		fmt.Printf("Error committing transaction: %v\n", err)
-		http.Error(w, "Failed to save changes: "+err.Error(), http.StatusInternalServerError)
-		return
+	if filter.Limit == 0 || filter.Limit > maxReportPageSize {
+		return filter, fmt.Errorf("limit must be between 1 and %d", maxReportPageSize)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Report updated successfully"})
+	return filter, nil
 }
 
-func deleteReportHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, _ := strconv.Atoi(vars["id"])
-
-	tx, err := db.Begin()
+// Search handlers
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer tx.Rollback()
 
-	// Delete related records first
-	_, err = tx.Exec("DELETE FROM report_shift_managers WHERE report_id = $1", id)
+	results, total, err := searchStore.SearchEvents(r.Context(), query)
 	if err != nil {
-		http.Error(w, "Error deleting report shift managers", http.StatusInternalServerError)
+		fmt.Printf("Error searching events: %v\n", err)
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	_, err = tx.Exec("DELETE FROM report_event_titles WHERE report_id = $1", id)
-	if err != nil {
-		http.Error(w, "Error deleting report event titles", http.StatusInternalServerError)
-		return
+	if results == nil {
+		results = []SearchResult{}
 	}
 
-	_, err = tx.Exec("DELETE FROM report_events_part3 WHERE report_id = $1", id)
-	if err != nil {
-		http.Error(w, "Error deleting report events part 3", http.StatusInternalServerError)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SearchPage{Results: results, Total: total, Limit: query.Limit, Offset: query.Offset})
+}
 
-	_, err = tx.Exec("DELETE FROM report_events_part4 WHERE report_id = $1", id)
-	if err != nil {
-		http.Error(w, "Error deleting report events part 4", http.StatusInternalServerError)
-		return
+// parseSearchQuery reads the search text, filters and page from the query string.
+func parseSearchQuery(values url.Values) (SearchQuery, error) {
+	query := SearchQuery{
+		Text:     strings.TrimSpace(values.Get("q")),
+		DateFrom: values.Get("date_from"),
+		DateTo:   values.Get("date_to"),
+		Limit:    defaultReportPageSize,
 	}
-
-	// Delete main report
-	_, err = tx.Exec("DELETE FROM daily_reports WHERE id = $1", id)
-	if err != nil {
-		http.Error(w, "Error deleting report", http.StatusInternalServerError)
-		return
+	if query.Text == "" {
+		return query, fmt.Errorf("missing search query q")
 	}
 
-	tx.Commit()
+	for _, date := range []string{query.DateFrom, query.DateTo} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return query, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
 
-	w.WriteHeader(http.StatusOK)
-}
\ No newline at end of file
+	ints := map[string]*int{
+		"part":                &query.Part,
+		"severity_id":         &query.SeverityID,
+		"category_id":         &query.CategoryID,
+		"affected_service_id": &query.AffectedServiceID,
+		"limit":               &query.Limit,
+		"offset":              &query.Offset,
+	}
+	for name, dst := range ints {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return query, fmt.Errorf("invalid %s %q", name, value)
+		}
+		*dst = n
+	}
+	if query.Part != 0 && query.Part != 3 && query.Part != 4 {
+		return query, fmt.Errorf("part must be 3 or 4")
+	}
+	if query.Limit == 0 || query.Limit > maxReportPageSize {
+		return query, fmt.Errorf("limit must be between 1 and %d", maxReportPageSize)
+	}
+	return query, nil
+}
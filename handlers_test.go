@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// useTestStore points the handlers at s.
+func useTestStore(s *sqlStore) {
+	userStore, catalogStore, reportStore, searchStore = s, s, s, s
+	trashStore, auditStore, chainStore, sensorStore = s, s, s, s
+	rcaStore, incidentStore = s, s
+}
+
+// testRequest builds a request made by user, with the route variables the
+// router would set.
+func testRequest(t *testing.T, method string, body interface{}, user sessionUser, vars map[string]string) *http.Request {
+	t.Helper()
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(method, "/", bytes.NewReader(encoded))
+	r = r.WithContext(context.WithValue(r.Context(), sessionUserKey, user))
+	return mux.SetURLVars(r, vars)
+}
+
+// useTestSessions keeps sessions in the database of s.
+func useTestSessions(s *sqlStore) {
+	cfg.Session.IdleTimeout = 5 * time.Minute
+	store = newDBSessionStore(s.db, []byte(strings.Repeat("k", 32)))
+}
+
+// testSessionCookie logs user in and returns the session cookie.
+func testSessionCookie(t *testing.T, user sessionUser) *http.Cookie {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+	session, err := store.New(r, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["user_id"] = user.ID
+	session.Values["username"] = user.Username
+	session.Values["role"] = user.Role
+	session.Values["last_activity"] = time.Now().Unix()
+	w := httptest.NewRecorder()
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	return w.Result().Cookies()[0]
+}
+
+func TestUpdateReportHandler(t *testing.T) {
+	admin := sessionUser{ID: 1, Username: "admin", Role: "admin"}
+	author := sessionUser{ID: 1, Username: "admin", Role: "user"}
+	stale := ReportInput{ReportDate: "2024-03-01", ShiftManagerIDs: []int{}} // fails validation
+
+	tests := []struct {
+		name    string
+		status  string
+		user    sessionUser
+		ifMatch string // "current" sends the report's version
+		input   ReportInput
+		want    int
+	}{
+		{"draft", statusDraft, author, "current", testReport("2024-03-01"), http.StatusOK},
+		{"invalid payload", statusDraft, author, "current", stale, http.StatusUnprocessableEntity},
+		{"no If-Match", statusDraft, author, "", stale, http.StatusPreconditionRequired},
+		{"any version", statusDraft, author, "*", testReport("2024-03-01"), http.StatusPreconditionRequired},
+		{"outdated version", statusDraft, author, `"99"`, stale, http.StatusPreconditionFailed},
+		{"submitted by author", statusSubmitted, author, "current", stale, http.StatusConflict},
+		{"submitted by admin", statusSubmitted, admin, "current", testReport("2024-03-01"), http.StatusOK},
+		{"locked", statusLocked, admin, "current", stale, http.StatusConflict},
+		{"locked, outdated version", statusLocked, admin, `"1"`, stale, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openChainStore(t)
+			useTestStore(s)
+			id := createTestReport(t, s, testReport("2024-03-01"))
+			setTestStatus(t, s, id, tt.status)
+			report, err := s.GetReport(context.Background(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := testRequest(t, http.MethodPut, tt.input, tt.user, map[string]string{"id": strconv.Itoa(id)})
+			switch tt.ifMatch {
+			case "current":
+				r.Header.Set("If-Match", reportETag(report.Version))
+			case "":
+			default:
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			updateReportHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("got %d %q, want %d", w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
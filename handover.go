@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// At handover the incoming shift starts from a draft of its report: the
+// events the previous shift left open, its shift managers and the health
+// checks that failed.
+
+// nextShift returns the shift after current, in order of start time, and the
+// date it falls on. The first shift of the day follows the last one. Reports
+// without a shift are followed by the next day without a shift.
+func nextShift(shifts []ShiftHours, current *ShiftHours, date string) (*ShiftHours, string, error) {
+	if len(date) > 10 {
+		date = date[:10]
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid report date %q", date)
+	}
+	if current == nil || len(shifts) == 0 {
+		return nil, day.AddDate(0, 0, 1).Format("2006-01-02"), nil
+	}
+
+	currentStart, err := clockOffset(current.StartTime)
+	if err != nil {
+		return nil, "", err
+	}
+	ordered := make([]ShiftHours, 0, len(shifts))
+	starts := make(map[int]time.Duration, len(shifts))
+	for _, shift := range shifts {
+		start, err := clockOffset(shift.StartTime)
+		if err != nil {
+			return nil, "", err
+		}
+		starts[shift.ID] = start
+		ordered = append(ordered, shift)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return starts[ordered[i].ID] < starts[ordered[j].ID] })
+
+	for i := range ordered {
+		if starts[ordered[i].ID] > currentStart {
+			return &ordered[i], day.Format("2006-01-02"), nil
+		}
+	}
+	return &ordered[0], day.AddDate(0, 0, 1).Format("2006-01-02"), nil
+}
+
+// reportDraft builds the draft of the report following previous. Open events
+// carry on with their original start time; previous is left as it is.
+func reportDraft(previous DailyReport, shifts []ShiftHours) (ReportDraft, error) {
+	shift, date, err := nextShift(shifts, previous.ShiftHours, previous.ReportDate)
+	if err != nil {
+		return ReportDraft{}, err
+	}
+
+	draft := ReportDraft{
+		ReportInput: ReportInput{
+			ReportDate:      date,
+			ShiftManagerIDs: []int{},
+			EventTitleIDs:   []int{},
+			HealthChecks:    []HealthCheckResult{},
+			EventsPart3:     []EventPart3{},
+			EventsPart4:     []EventPart4{},
+		},
+		PreviousReportID:   previous.ID,
+		FailedHealthChecks: []HealthCheckResult{},
+	}
+	if previous.Site != nil {
+		draft.SiteID = &previous.Site.ID
+	}
+	if shift != nil {
+		draft.ShiftHoursID = &shift.ID
+	}
+
+	for _, manager := range previous.ShiftManagers {
+		draft.ShiftManagerIDs = append(draft.ShiftManagerIDs, manager.ID)
+	}
+	for _, result := range previous.HealthChecks {
+		if result.Status == healthFail {
+			draft.FailedHealthChecks = append(draft.FailedHealthChecks, result)
+		}
+	}
+	for _, event := range previous.EventsPart3 {
+		if event.Open {
+			event.ID = 0
+			draft.EventsPart3 = append(draft.EventsPart3, event)
+		}
+	}
+	for _, event := range previous.EventsPart4 {
+		if event.Open {
+			event.ID = 0
+			draft.EventsPart4 = append(draft.EventsPart4, event)
+		}
+	}
+	return draft, nil
+}
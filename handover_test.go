@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestReportDraftRoundTrip(t *testing.T) {
+	// The previous report is left as it is, whatever its status
+	for _, status := range []string{statusDraft, statusSubmitted, statusLocked} {
+		t.Run(status, func(t *testing.T) {
+			s := openChainStore(t)
+			useTestStore(s)
+			ctx := context.Background()
+			incidentID, err := s.CreateIncident(ctx, Incident{Title: "Chiller outage", Status: "open"}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The night shift leaves the chiller fault and a door check open
+			night := 3
+			input := testReport("2024-03-01")
+			input.ShiftHoursID = &night
+			input.EventsPart3 = []EventPart3{
+				{EventSummary: "Chiller fault", StartTime: "23:00", IncidentID: &incidentID},
+				{EventSummary: "UPS alarm", StartTime: "23:10", EndTime: "23:20"},
+			}
+			input.EventsPart4 = []EventPart4{{EventSummary: "Door check", StartTime: "05:30"}}
+			input.HealthChecks = []HealthCheckResult{{ItemID: 1, Status: healthWarning}, {ItemID: 2, Status: healthFail}, {ItemID: 3, Status: healthNotChecked}}
+			previousID := createTestReport(t, s, input)
+			setTestStatus(t, s, previousID, status)
+			previous, err := s.GetReport(ctx, previousID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shifts, err := s.ListShiftHours(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			draft, err := reportDraft(previous, shifts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if draft.ReportDate != "2024-03-02" || *draft.ShiftHoursID != 1 || len(draft.EventsPart3) != 1 || len(draft.EventsPart4) != 1 {
+				t.Fatalf("got draft %+v, want the morning shift of 2024-03-02 with the two open events", draft.ReportInput)
+			}
+			if len(draft.FailedHealthChecks) != 1 || draft.FailedHealthChecks[0].ItemID != 2 {
+				t.Errorf("got failed health checks %+v, want only item 2", draft.FailedHealthChecks)
+			}
+			carried := draft.EventsPart3[0]
+			if carried.StartTime != "2024-03-01T23:00:00Z" || carried.IncidentID == nil || *carried.IncidentID != incidentID {
+				t.Errorf("got carried event %+v, want it from 23:00 the day before in incident %d", carried, incidentID)
+			}
+
+			// The incoming shift completes the draft and posts it
+			next := draft.ReportInput
+			errs, err := validateReport(ctx, &next)
+			if err != nil || len(errs) == 0 {
+				t.Fatalf("got %v %+v, want the draft to lack event titles and health checks", err, errs)
+			}
+			for _, fieldErr := range errs {
+				if fieldErr.Field != "event_title_ids" && fieldErr.Field != "health_checks" {
+					t.Errorf("draft fails validation on %s: %s", fieldErr.Field, fieldErr.Message)
+				}
+			}
+			next.EventTitleIDs, next.HealthChecks = []int{1}, testReport("").HealthChecks
+			if errs, err = validateReport(ctx, &next); err != nil || len(errs) > 0 {
+				t.Fatalf("got %v %+v for the completed draft", err, errs)
+			}
+			nextID := createTestReport(t, s, next)
+
+			saved, err := s.GetReport(ctx, nextID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(saved.EventsPart3) != 1 || saved.EventsPart3[0].StartTime != "2024-03-01T23:00:00Z" || !saved.EventsPart3[0].Open {
+				t.Errorf("got saved events %+v, want the chiller fault open from 23:00 the day before", saved.EventsPart3)
+			}
+			after, err := s.GetReport(ctx, previousID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Version != previous.Version || !reflect.DeepEqual(after.EventsPart3, previous.EventsPart3) ||
+				!reflect.DeepEqual(after.EventsPart4, previous.EventsPart4) {
+				t.Errorf("previous report changed to version %d with events %+v %+v, want it as written",
+					after.Version, after.EventsPart3, after.EventsPart4)
+			}
+
+			// The incident ends once the next shift closes the event
+			next.EventsPart3[0].EndTime = "07:30"
+			if _, err := s.UpdateReport(ctx, nextID, next, 1, 0); err != nil {
+				t.Fatal(err)
+			}
+			incident, err := s.GetIncident(ctx, incidentID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if incident.StartTime != "2024-03-01T23:00:00Z" || incident.EndTime != "2024-03-02T07:30:00Z" {
+				t.Errorf("got incident from %q to %q, want from 2024-03-01T23:00:00Z to 2024-03-02T07:30:00Z", incident.StartTime, incident.EndTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Health check results, from best to worst.
+const (
+	healthOK         = "ok"
+	healthNotChecked = "not_checked"
+	healthWarning    = "warning"
+	healthFail       = "fail"
+)
+
+var healthStatuses = []string{healthOK, healthNotChecked, healthWarning, healthFail}
+
+// healthSeverity ranks statuses so that merged reports keep the worst result.
+func healthSeverity(status string) int {
+	for i, s := range healthStatuses {
+		if s == status {
+			return i
+		}
+	}
+	return len(healthStatuses)
+}
+
+// Kinds of reading a health check item takes.
+const (
+	readingNone   = "none"
+	readingNumber = "number"
+	readingEnum   = "enum"
+	readingText   = "text"
+)
+
+// checkReadingDefinition returns what is wrong with an item's reading type,
+// limits and options, or nil.
+func (item HealthCheckItem) checkReadingDefinition() error {
+	hasLimits := item.WarningMin != nil || item.WarningMax != nil || item.CriticalMin != nil || item.CriticalMax != nil
+	switch item.ReadingType {
+	case readingNone, readingText:
+	case readingNumber:
+		if below(item.WarningMin, item.CriticalMin) || below(item.CriticalMax, item.WarningMax) ||
+			below(item.WarningMax, item.WarningMin) || below(item.CriticalMax, item.CriticalMin) {
+			return fmt.Errorf("limits must satisfy critical_min <= warning_min <= warning_max <= critical_max")
+		}
+	case readingEnum:
+		if len(item.Options) == 0 {
+			return fmt.Errorf("enum readings need at least one option")
+		}
+		seen := make(map[string]bool, len(item.Options))
+		for _, option := range item.Options {
+			switch {
+			case strings.TrimSpace(option.Value) == "":
+				return fmt.Errorf("option values cannot be empty")
+			case seen[option.Value]:
+				return fmt.Errorf("option %q is listed more than once", option.Value)
+			case option.Status != healthOK && option.Status != healthWarning && option.Status != healthFail:
+				return fmt.Errorf("option %q has invalid status %q, expected ok, warning or fail", option.Value, option.Status)
+			}
+			seen[option.Value] = true
+		}
+	default:
+		return fmt.Errorf("invalid reading type %q, expected one of none, number, enum, text", item.ReadingType)
+	}
+
+	if hasLimits && item.ReadingType != readingNumber {
+		return fmt.Errorf("limits only apply to number readings")
+	}
+	if item.Unit != "" && item.ReadingType != readingNumber {
+		return fmt.Errorf("units only apply to number readings")
+	}
+	if len(item.Options) > 0 && item.ReadingType != readingEnum {
+		return fmt.Errorf("options only apply to enum readings")
+	}
+	return nil
+}
+
+// below reports whether both limits are set and a is less than b.
+func below(a, b *float64) bool {
+	return a != nil && b != nil && *a < *b
+}
+
+// rate returns the status of a reading of the item: the option's status for
+// enum readings and, for number readings, fail outside the critical limits,
+// warning outside the warning limits and ok otherwise. ok is false when the
+// reading does not determine the status.
+func (item HealthCheckItem) rate(result HealthCheckResult) (status string, ok bool) {
+	switch {
+	case item.ReadingType == readingNumber && result.ReadingNumber != nil:
+		value := *result.ReadingNumber
+		switch {
+		case outside(value, item.CriticalMin, item.CriticalMax):
+			return healthFail, true
+		case outside(value, item.WarningMin, item.WarningMax):
+			return healthWarning, true
+		}
+		return healthOK, true
+	case item.ReadingType == readingEnum && result.ReadingText != "":
+		for _, option := range item.Options {
+			if option.Value == result.ReadingText {
+				return option.Status, true
+			}
+		}
+	}
+	return "", false
+}
+
+func outside(value float64, min, max *float64) bool {
+	return (min != nil && value < *min) || (max != nil && value > *max)
+}
+
+// appliesTo reports whether item must be checked on a report for the given
+// shift. Inactive items are no longer asked for.
+func (item HealthCheckItem) appliesTo(shiftHoursID *int) bool {
+	if !item.Active {
+		return false
+	}
+	if len(item.ShiftHoursIDs) == 0 {
+		return true
+	}
+	for _, id := range item.ShiftHoursIDs {
+		if shiftHoursID != nil && id == *shiftHoursID {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyHealthItems are the checklist items migration 0011 created from the
+// former boolean columns, keyed by the old JSON field.
+var legacyHealthItems = []struct {
+	Field  string
+	ItemID int
+	Name   string
+}{
+	{"health_power_sources", 1, "Power Sources"},
+	{"health_humidity_temp", 2, "Humidity & Temperature"},
+	{"health_fire_system", 3, "Fire System"},
+}
+
+// upgradeLegacySnapshot fills the health checks of a revision snapshot taken
+// while health checks were still three booleans.
+func upgradeLegacySnapshot(data []byte, report *DailyReport) error {
+	if report.HealthChecks != nil {
+		return nil
+	}
+	var legacy map[string]json.RawMessage
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	for _, item := range legacyHealthItems {
+		raw, ok := legacy[item.Field]
+		if !ok {
+			continue
+		}
+		var passed bool
+		if err := json.Unmarshal(raw, &passed); err != nil {
+			return err
+		}
+		status := healthFail
+		if passed {
+			status = healthOK
+		}
+		report.HealthChecks = append(report.HealthChecks, HealthCheckResult{ItemID: item.ItemID, Name: item.Name, Status: status})
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import "testing"
+
+func limit(value float64) *float64 {
+	return &value
+}
+
+func TestHealthCheckItemRate(t *testing.T) {
+	temperature := HealthCheckItem{
+		Name: "Room Temperature", ReadingType: readingNumber, Unit: "°C",
+		CriticalMin: limit(10), WarningMin: limit(18), WarningMax: limit(27), CriticalMax: limit(32),
+	}
+	upperOnly := HealthCheckItem{Name: "Humidity", ReadingType: readingNumber, WarningMax: limit(60), CriticalMax: limit(70)}
+	ups := HealthCheckItem{Name: "UPS", ReadingType: readingEnum, Options: []HealthCheckOption{
+		{Value: "online", Status: healthOK}, {Value: "on battery", Status: healthWarning}, {Value: "fault", Status: healthFail},
+	}}
+
+	tests := []struct {
+		name   string
+		item   HealthCheckItem
+		result HealthCheckResult
+		want   string // empty when the reading does not set the status
+	}{
+		{"within limits", temperature, HealthCheckResult{ReadingNumber: limit(22)}, healthOK},
+		{"on the warning limit", temperature, HealthCheckResult{ReadingNumber: limit(27)}, healthOK},
+		{"above warning", temperature, HealthCheckResult{ReadingNumber: limit(27.5)}, healthWarning},
+		{"below warning", temperature, HealthCheckResult{ReadingNumber: limit(17)}, healthWarning},
+		{"on the critical limit", temperature, HealthCheckResult{ReadingNumber: limit(32)}, healthWarning},
+		{"above critical", temperature, HealthCheckResult{ReadingNumber: limit(32.1)}, healthFail},
+		{"below critical", temperature, HealthCheckResult{ReadingNumber: limit(-5)}, healthFail},
+		{"upper limits only, low", upperOnly, HealthCheckResult{ReadingNumber: limit(0)}, healthOK},
+		{"upper limits only, high", upperOnly, HealthCheckResult{ReadingNumber: limit(75)}, healthFail},
+		{"no reading", temperature, HealthCheckResult{Status: healthWarning}, ""},
+		{"enum option", ups, HealthCheckResult{ReadingText: "on battery"}, healthWarning},
+		{"enum failure", ups, HealthCheckResult{ReadingText: "fault"}, healthFail},
+		{"unknown option", ups, HealthCheckResult{ReadingText: "bypass"}, ""},
+		{"text reading", HealthCheckItem{ReadingType: readingText}, HealthCheckResult{ReadingText: "all quiet"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, ok := tt.item.rate(tt.result)
+			if status != tt.want || ok != (tt.want != "") {
+				t.Errorf("got %q %v, want %q", status, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReadingSetsStatus(t *testing.T) {
+	items := []HealthCheckItem{
+		{ID: 1, Name: "Room Temperature", Active: true, ReadingType: readingNumber, Unit: "°C", WarningMax: limit(27), CriticalMax: limit(32)},
+		{ID: 2, Name: "Fire System", Active: true, ReadingType: readingNone},
+	}
+	tests := []struct {
+		name       string
+		results    []HealthCheckResult
+		wantStatus string // of the first result
+		wantUnit   string
+		wantErrors []string
+	}{
+		{"reading overrides the status sent", []HealthCheckResult{{ItemID: 1, Status: healthOK, ReadingNumber: limit(35)}, {ItemID: 2, Status: healthOK}},
+			healthFail, "°C", nil},
+		{"status kept without a reading", []HealthCheckResult{{ItemID: 1, Status: healthWarning}, {ItemID: 2, Status: healthOK}},
+			healthWarning, "", nil},
+		{"number on an item without readings", []HealthCheckResult{{ItemID: 1, Status: healthOK}, {ItemID: 2, Status: healthOK, ReadingNumber: limit(1)}},
+			healthOK, "", []string{`health_checks[1].reading_number: "Fire System" does not take a number reading`}},
+		{"text on a number item", []HealthCheckResult{{ItemID: 1, Status: healthOK, ReadingText: "warm"}, {ItemID: 2, Status: healthOK}},
+			healthOK, "", []string{`health_checks[0].reading_text: "Room Temperature" does not take a text reading`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs ValidationErrors
+			errs.checkHealthChecks(tt.results, items, nil, nil)
+			var got []string
+			for _, fieldErr := range errs {
+				got = append(got, fieldErr.Field+": "+fieldErr.Message)
+			}
+			if len(got) != len(tt.wantErrors) || (len(got) > 0 && got[0] != tt.wantErrors[0]) {
+				t.Errorf("got errors %q, want %q", got, tt.wantErrors)
+			}
+			if tt.results[0].Status != tt.wantStatus || tt.results[0].ReadingUnit != tt.wantUnit {
+				t.Errorf("got %s in %q, want %s in %q", tt.results[0].Status, tt.results[0].ReadingUnit, tt.wantStatus, tt.wantUnit)
+			}
+		})
+	}
+}
+
+func TestCheckReadingDefinition(t *testing.T) {
+	tests := []struct {
+		name  string
+		item  HealthCheckItem
+		valid bool
+	}{
+		{"ordered limits", HealthCheckItem{ReadingType: readingNumber, CriticalMin: limit(10), WarningMin: limit(18), WarningMax: limit(27), CriticalMax: limit(32)}, true},
+		{"warning outside critical", HealthCheckItem{ReadingType: readingNumber, WarningMax: limit(35), CriticalMax: limit(32)}, false},
+		{"warning range reversed", HealthCheckItem{ReadingType: readingNumber, WarningMin: limit(27), WarningMax: limit(18)}, false},
+		{"limits on an enum", HealthCheckItem{ReadingType: readingEnum, Options: []HealthCheckOption{{Value: "on", Status: healthOK}}, WarningMax: limit(1)}, false},
+		{"not_checked option", HealthCheckItem{ReadingType: readingEnum, Options: []HealthCheckOption{{Value: "on", Status: healthNotChecked}}}, false},
+		{"unit on a text item", HealthCheckItem{ReadingType: readingText, Unit: "V"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.item.checkReadingDefinition(); (err == nil) != tt.valid {
+				t.Errorf("got %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "time"
+
+// An incident groups the Part 3 events of one occurrence, possibly logged on
+// several shifts and reports. Its span runs from the earliest start to the
+// latest end of its events.
+
+const (
+	incidentOpen      = "open"
+	incidentMitigated = "mitigated"
+	incidentResolved  = "resolved"
+)
+
+var incidentStatuses = map[string]bool{incidentOpen: true, incidentMitigated: true, incidentResolved: true}
+
+// computeSpan sets the start, end and duration of the incident from its
+// events. The end stays empty while an event has a start but no end. An event
+// carried over to the next shift's report keeps its start time there, so
+// events with the same start are one event, which ends when any of them does.
+func (incident *Incident) computeSpan() {
+	incident.StartTime, incident.EndTime, incident.DurationMinutes = "", "", nil
+	var start, end time.Time
+	ended := map[string]bool{}
+	for _, event := range incident.Events {
+		if t, err := time.Parse(time.RFC3339, event.StartTime); err == nil && (start.IsZero() || t.Before(start)) {
+			start = t
+		}
+		if t, err := time.Parse(time.RFC3339, event.EndTime); err == nil {
+			if t.After(end) {
+				end = t
+			}
+			ended[event.StartTime] = true
+		}
+	}
+	ongoing := false
+	for _, event := range incident.Events {
+		if event.StartTime != "" && !ended[event.StartTime] {
+			ongoing = true
+		}
+	}
+
+	if !start.IsZero() {
+		incident.StartTime = start.Format(time.RFC3339)
+	}
+	if end.IsZero() || ongoing {
+		return
+	}
+	incident.EndTime = end.Format(time.RFC3339)
+	if !start.IsZero() {
+		minutes := int(end.Sub(start).Minutes())
+		incident.DurationMinutes = &minutes
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestIncidentComputeSpan(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []IncidentEvent
+		start    string
+		end      string
+		duration int // -1 for none
+	}{
+		{"no events", nil, "", "", -1},
+		{"one event", []IncidentEvent{{StartTime: "2024-03-11T23:00:00Z", EndTime: "2024-03-12T01:30:00Z"}},
+			"2024-03-11T23:00:00Z", "2024-03-12T01:30:00Z", 150},
+		{"across shifts", []IncidentEvent{
+			{StartTime: "2024-03-12T06:00:00Z", EndTime: "2024-03-12T07:15:00Z"},
+			{StartTime: "2024-03-11T23:00:00Z", EndTime: "2024-03-12T06:00:00Z"},
+		}, "2024-03-11T23:00:00Z", "2024-03-12T07:15:00Z", 495},
+		{"still going on", []IncidentEvent{
+			{StartTime: "2024-03-11T23:00:00Z", EndTime: "2024-03-12T06:00:00Z"},
+			{StartTime: "2024-03-12T06:00:00Z"},
+		}, "2024-03-11T23:00:00Z", "", -1},
+		{"carried over and ended", []IncidentEvent{
+			{StartTime: "2024-03-11T23:00:00Z"},
+			{StartTime: "2024-03-11T23:00:00Z", EndTime: "2024-03-12T07:15:00Z"},
+		}, "2024-03-11T23:00:00Z", "2024-03-12T07:15:00Z", 495},
+		{"carried over and still going on", []IncidentEvent{
+			{StartTime: "2024-03-11T23:00:00Z"},
+			{StartTime: "2024-03-11T23:00:00Z"},
+			{StartTime: "2024-03-11T22:30:00Z", EndTime: "2024-03-11T23:30:00Z"},
+		}, "2024-03-11T22:30:00Z", "", -1},
+		{"without times", []IncidentEvent{{}, {StartTime: "2024-03-11T23:00:00Z", EndTime: "2024-03-11T23:45:00Z"}},
+			"2024-03-11T23:00:00Z", "2024-03-11T23:45:00Z", 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incident := Incident{Events: tt.events, EndTime: "stale"}
+			incident.computeSpan()
+			duration := -1
+			if incident.DurationMinutes != nil {
+				duration = *incident.DurationMinutes
+			}
+			if incident.StartTime != tt.start || incident.EndTime != tt.end || duration != tt.duration {
+				t.Errorf("got %q to %q (%d min), want %q to %q (%d min)",
+					incident.StartTime, incident.EndTime, duration, tt.start, tt.end, tt.duration)
+			}
+		})
+	}
+}
+
+func TestAttachIncidentEventHandler(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	ctx := context.Background()
+	admin := sessionUser{ID: 1, Username: "admin", Role: "admin"}
+
+	// A chiller outage logged by the night shift and carried into the morning
+	night := 3
+	input := testReport("2024-03-11")
+	input.ShiftHoursID = &night
+	input.EventsPart3 = []EventPart3{{EventSummary: "Chiller fault", StartTime: "23:00", EndTime: "06:00"}}
+	nightReport := createTestReport(t, s, input)
+	input = testReport("2024-03-12")
+	input.EventsPart3 = []EventPart3{{EventSummary: "Chiller fault", StartTime: "06:00", EndTime: "07:15"}}
+	morningReport := createTestReport(t, s, input)
+
+	var eventIDs []int
+	for _, id := range []int{nightReport, morningReport} {
+		report, err := s.GetReport(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eventIDs = append(eventIDs, report.EventsPart3[0].ID)
+	}
+	outage, err := s.CreateIncident(ctx, Incident{Title: "Chiller outage", Status: incidentOpen}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.CreateIncident(ctx, Incident{Title: "Cooling alarm", Status: incidentOpen}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attach := func(incidentID, eventID int) int {
+		w := httptest.NewRecorder()
+		attachIncidentEventHandler(w, testRequest(t, http.MethodPost, map[string]int{"event_id": eventID}, admin,
+			map[string]string{"id": strconv.Itoa(incidentID)}))
+		return w.Code
+	}
+	steps := []struct {
+		name     string
+		incident int
+		event    int
+		want     int
+	}{
+		{"night event", outage, eventIDs[0], http.StatusOK},
+		{"morning event", outage, eventIDs[1], http.StatusOK},
+		{"again", outage, eventIDs[1], http.StatusOK},
+		{"to another incident", other, eventIDs[0], http.StatusConflict},
+		{"unknown event", other, 999, http.StatusNotFound},
+		{"unknown incident", 999, eventIDs[0], http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if got := attach(step.incident, step.event); got != step.want {
+			t.Errorf("%s: got %d, want %d", step.name, got, step.want)
+		}
+	}
+
+	incident, err := s.GetIncident(ctx, outage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incident.Events) != 2 || incident.StartTime != "2024-03-11T23:00:00Z" || incident.EndTime != "2024-03-12T07:15:00Z" ||
+		incident.DurationMinutes == nil || *incident.DurationMinutes != 495 {
+		t.Errorf("got incident with %d events from %q to %q, want both events from 23:00 to 07:15 the next day",
+			len(incident.Events), incident.StartTime, incident.EndTime)
+	}
+	if empty, err := s.GetIncident(ctx, other); err != nil || len(empty.Events) != 0 || empty.StartTime != "" {
+		t.Errorf("got %+v (%v), want the other incident without events", empty, err)
+	}
+
+	// Once detached, the event can move to the other incident
+	if err := s.DetachIncidentEvent(ctx, outage, eventIDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := attach(other, eventIDs[0]); got != http.StatusOK {
+		t.Errorf("attaching the detached event got %d, want 200", got)
+	}
+	if incident, err = s.GetIncident(ctx, outage); err != nil || incident.StartTime != "2024-03-12T06:00:00Z" {
+		t.Errorf("got incident from %q (%v), want it to start with the morning event", incident.StartTime, err)
+	}
+}
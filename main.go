@@ -2,74 +2,229 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 
-	"github.com/gorilla/mux"
-	"github.com/gorilla/sessions"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 var (
 	db    *sql.DB
-	store = sessions.NewCookieStore([]byte("your-secret-key-change-this"))
+	store *dbSessionStore
+	cfg   Config
+
+	userStore     UserStore
+	catalogStore  CatalogStore
+	reportStore   ReportStore
+	searchStore   SearchStore
+	trashStore    TrashStore
+	auditStore    AuditStore
+	chainStore    ChainStore
+	sensorStore   SensorStore
+	rcaStore      RCAStore
+	incidentStore IncidentStore
 )
 
 func main() {
-	// Database connection
+	configPath := flag.String("config", "", "path to the YAML configuration file (default: config.yaml if present)")
+	flag.Parse()
+
+	// Configuration: file, then DAILY_REPORT_* environment overrides
+	path, required := *configPath, true
+	if path == "" {
+		path, required = "config.yaml", false
+	}
 	var err error
-	fmt.Println("Attempting to connect to database...")
-	db, err = sql.Open("postgres", "host=localhost port=5432 user=postgres password=password dbname=DB-Name sslmode=disable")
+	cfg, err = loadConfig(path, required)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		log.Fatal("Failed to load configuration: ", err)
 	}
-	defer db.Close()
 
-	// Test database connection
-	fmt.Println("Testing database connection...")
-	if err = db.Ping(); err != nil {
-		log.Fatal("Failed to ping database:", err)
+	// Database connection
+	fmt.Printf("Connecting to %s database...\n", cfg.Database.Driver)
+	db, err = openDatabase(cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
 	}
+	defer db.Close()
 	fmt.Println("Database connection successful!")
 
+	// Subcommands
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := runMigrateCommand(db, cfg.Database.Driver, args[1:]); err != nil {
+				log.Fatal("Migration failed: ", err)
+			}
+		case "verify", "seal":
+			if err := runChainCommand(db, cfg.Database.Driver, cfg.Chain.SigningKeyFile, args[0]); err != nil {
+				log.Fatalf("%s failed: %v", args[0], err)
+			}
+		default:
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		return
+	}
+
+	// Schema migrations
+	if cfg.Database.AutoMigrate {
+		if err := migrateUp(db, cfg.Database.Driver); err != nil {
+			log.Fatal("Failed to apply migrations: ", err)
+		}
+	} else if pending, err := pendingMigrations(db, cfg.Database.Driver); err != nil {
+		log.Fatal("Failed to check migrations: ", err)
+	} else if pending > 0 {
+		log.Fatalf("Database schema has %d pending migration(s); run `daily_report migrate up` or enable database.auto_migrate", pending)
+	}
+
+	// Storage
+	sqlStore, err := newStore(db, cfg.Database.Driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userStore, catalogStore, reportStore, searchStore = sqlStore, sqlStore, sqlStore, sqlStore
+	trashStore, auditStore, chainStore, sensorStore = sqlStore, sqlStore, sqlStore, sqlStore
+	rcaStore, incidentStore = sqlStore, sqlStore
+	if cfg.Trash.Retention > 0 {
+		go purgeTrash(trashStore, cfg.Trash.PurgeInterval, cfg.Trash.Retention)
+	}
+	if cfg.RCA.EscalationDays > 0 {
+		go escalateRCAEvents(rcaStore, cfg.RCA.EscalationInterval, cfg.RCA.EscalationDays)
+	}
+
+	// Locked reports are sealed into the report chain with this key
+	sqlStore.chainKey, err = loadChainKey(cfg.Chain.SigningKeyFile, true)
+	if err != nil {
+		log.Fatal("Failed to load chain signing key: ", err)
+	}
+
+	// Sessions are kept server-side; the cookie only carries the signed token
+	store = newDBSessionStore(db, []byte(cfg.Session.Secret))
+	go store.purgeExpired(cfg.Session.PurgeInterval, cfg.Session.Retention)
+
 	// Configure session store with proper cookie settings
 	store.Options.Path = "/"
+	store.Options.MaxAge = int(cfg.Session.MaxAge.Seconds())
 	store.Options.HttpOnly = true
-	store.Options.Secure = false // Set to true in production with HTTPS
+	store.Options.Secure = cfg.Session.SecureCookie || cfg.Server.TLS.Enabled()
 	store.Options.SameSite = http.SameSiteLaxMode // Use Lax instead of None for better compatibility
-	
-	// We'll handle expiration ourselves in the handlers
+
+	// Idle expiry is handled in the handlers using session.idle_timeout
 	// Note: SameSite=None requires Secure=true, which is why we're using Lax instead
 
-	r := mux.NewRouter()
+	r := newPolicyRouter()
 
 	// Static files
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./static/"))))
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.Server.StaticDir))))
 
 	// Routes
-	r.HandleFunc("/", homeHandler).Methods("GET")
-	r.HandleFunc("/login", loginHandler).Methods("POST")
-	r.HandleFunc("/logout", logoutHandler).Methods("POST")
-	r.HandleFunc("/api/users", getUsersHandler).Methods("GET")
-	r.HandleFunc("/api/users", createUserHandler).Methods("POST")
-	r.HandleFunc("/api/users/{id}", updateUserHandler).Methods("PUT")
-	r.HandleFunc("/api/users/{id}", deleteUserHandler).Methods("DELETE")
-	r.HandleFunc("/api/shift-hours", getShiftHoursHandler).Methods("GET")
-	r.HandleFunc("/api/shift-hours", createShiftHoursHandler).Methods("POST")
-	r.HandleFunc("/api/shift-hours/{id}", updateShiftHoursHandler).Methods("PUT")
-	r.HandleFunc("/api/shift-hours/{id}", deleteShiftHoursHandler).Methods("DELETE")
-	r.HandleFunc("/api/event-titles", getEventTitlesHandler).Methods("GET")
-	r.HandleFunc("/api/event-titles", createEventTitleHandler).Methods("POST")
-	r.HandleFunc("/api/event-titles/{id}", updateEventTitleHandler).Methods("PUT")
-	r.HandleFunc("/api/event-titles/{id}", deleteEventTitleHandler).Methods("DELETE")
-	r.HandleFunc("/api/reports", getReportsHandler).Methods("GET")
-	r.HandleFunc("/api/reports", createReportHandler).Methods("POST")
-	r.HandleFunc("/api/reports/{id}", getReportHandler).Methods("GET")
-	r.HandleFunc("/api/reports/{id}", updateReportHandler).Methods("PUT")
-	r.HandleFunc("/api/reports/{id}", deleteReportHandler).Methods("DELETE")
-	r.HandleFunc("/api/check-auth", checkAuthHandler).Methods("GET")
-
-	fmt.Println("Server starting on :8084")
-	log.Fatal(http.ListenAndServe(":8084", r))
+	r.register([]route{
+		{"GET", "/", public, homeHandler},
+		{"POST", "/login", public, loginHandler},
+		{"POST", "/logout", public, logoutHandler},
+		{"GET", "/api/check-auth", public, checkAuthHandler},
+		{"GET", "/api/users", authenticated, getUsersHandler},
+		{"POST", "/api/users", adminOnly, createUserHandler},
+		{"PUT", "/api/users/{id}", adminOnly, updateUserHandler},
+		{"DELETE", "/api/users/{id}", adminOnly, deleteUserHandler},
+		{"GET", "/api/users/{id}/sessions", adminOnly, getUserSessionsHandler},
+		{"DELETE", "/api/users/{id}/sessions", adminOnly, revokeUserSessionsHandler},
+		{"DELETE", "/api/sessions/{id}", adminOnly, revokeSessionHandler},
+		{"GET", "/api/sites", authenticated, getSitesHandler},
+		{"POST", "/api/sites", adminOnly, createSiteHandler},
+		{"PUT", "/api/sites/{id}", adminOnly, updateSiteHandler},
+		{"DELETE", "/api/sites/{id}", adminOnly, deleteSiteHandler},
+		{"GET", "/api/shift-hours", authenticated, getShiftHoursHandler},
+		{"POST", "/api/shift-hours", adminOnly, createShiftHoursHandler},
+		{"PUT", "/api/shift-hours/{id}", adminOnly, updateShiftHoursHandler},
+		{"DELETE", "/api/shift-hours/{id}", adminOnly, deleteShiftHoursHandler},
+		{"GET", "/api/event-titles", authenticated, getEventTitlesHandler},
+		{"POST", "/api/event-titles", adminOnly, createEventTitleHandler},
+		{"PUT", "/api/event-titles/{id}", adminOnly, updateEventTitleHandler},
+		{"DELETE", "/api/event-titles/{id}", adminOnly, deleteEventTitleHandler},
+		{"GET", "/api/severities", authenticated, getSeveritiesHandler},
+		{"POST", "/api/severities", adminOnly, createSeverityHandler},
+		{"PUT", "/api/severities/{id}", adminOnly, updateSeverityHandler},
+		{"DELETE", "/api/severities/{id}", adminOnly, deleteSeverityHandler},
+		{"GET", "/api/event-categories", authenticated, getEventCategoriesHandler},
+		{"POST", "/api/event-categories", adminOnly, createEventCategoryHandler},
+		{"PUT", "/api/event-categories/{id}", adminOnly, updateEventCategoryHandler},
+		{"DELETE", "/api/event-categories/{id}", adminOnly, deleteEventCategoryHandler},
+		{"GET", "/api/affected-services", authenticated, getAffectedServicesHandler},
+		{"POST", "/api/affected-services", adminOnly, createAffectedServiceHandler},
+		{"PUT", "/api/affected-services/{id}", adminOnly, updateAffectedServiceHandler},
+		{"DELETE", "/api/affected-services/{id}", adminOnly, deleteAffectedServiceHandler},
+
+		{"GET", "/api/health-check-items", authenticated, getHealthCheckItemsHandler},
+		{"POST", "/api/health-check-items", adminOnly, createHealthCheckItemHandler},
+		{"PUT", "/api/health-check-items/{id}", adminOnly, updateHealthCheckItemHandler},
+		{"DELETE", "/api/health-check-items/{id}", adminOnly, deleteHealthCheckItemHandler},
+
+		{"POST", "/api/sensor-readings", apiToken, recordSensorReadingsHandler},
+		{"GET", "/api/sensor-readings/latest", authenticated, getLatestSensorReadingsHandler},
+		{"GET", "/api/admin/api-tokens", adminOnly, getAPITokensHandler},
+		{"POST", "/api/admin/api-tokens", adminOnly, createAPITokenHandler},
+		{"DELETE", "/api/admin/api-tokens/{id}", adminOnly, revokeAPITokenHandler},
+
+		{"GET", "/api/rcas", authenticated, getRCAsHandler},
+		{"POST", "/api/rcas", authenticated, createRCAHandler},
+		{"GET", "/api/rcas/{id}", authenticated, getRCAHandler},
+		{"PUT", "/api/rcas/{id}", ownerOrAdmin(rcaOwner), updateRCAHandler},
+		{"DELETE", "/api/rcas/{id}", adminOnly, deleteRCAHandler},
+		{"POST", "/api/rcas/{id}/actions", ownerOrAdmin(rcaOwner), createRCAActionHandler},
+		{"PUT", "/api/rcas/{id}/actions/{actionId}", ownerOrAdmin(rcaOwner), updateRCAActionHandler},
+		{"DELETE", "/api/rcas/{id}/actions/{actionId}", ownerOrAdmin(rcaOwner), deleteRCAActionHandler},
+		{"GET", "/api/rca-actions", authenticated, getRCAActionsHandler},
+		{"GET", "/api/rca-actions/summary", authenticated, getRCAActionSummaryHandler},
+		// The assignee may update the status too, see changeRCAActionStatusHandler
+		{"POST", "/api/rca-actions/{id}/status", authenticated, changeRCAActionStatusHandler},
+		{"GET", "/api/rca-escalations", authenticated, getRCAEscalationsHandler},
+
+		// Incidents are kept up to date by whichever shift is on duty
+		{"GET", "/api/incidents", authenticated, getIncidentsHandler},
+		{"POST", "/api/incidents", authenticated, createIncidentHandler},
+		{"GET", "/api/incidents/{id}", authenticated, getIncidentHandler},
+		{"PUT", "/api/incidents/{id}", authenticated, updateIncidentHandler},
+		{"DELETE", "/api/incidents/{id}", adminOnly, deleteIncidentHandler},
+		{"POST", "/api/incidents/{id}/events", authenticated, attachIncidentEventHandler},
+		{"DELETE", "/api/incidents/{id}/events/{eventId}", authenticated, detachIncidentEventHandler},
+
+		{"GET", "/api/reports", authenticated, getReportsHandler},
+		{"POST", "/api/reports", authenticated, createReportHandler},
+		{"GET", "/api/reports/new", authenticated, newReportDraftHandler},
+		{"GET", "/api/reports/{id}", authenticated, getReportHandler},
+		{"PUT", "/api/reports/{id}", ownerOrAdmin(reportOwner), updateReportHandler},
+		{"DELETE", "/api/reports/{id}", ownerOrAdmin(reportOwner), deleteReportHandler},
+		// Who may change a status depends on the transition, see workflow.go
+		{"POST", "/api/reports/{id}/status", authenticated, changeReportStatusHandler},
+		{"GET", "/api/reports/{id}/status-history", authenticated, getReportStatusHistoryHandler},
+		{"GET", "/api/reports/{id}/revisions", authenticated, getReportRevisionsHandler},
+		{"GET", "/api/reports/{id}/revisions/diff", authenticated, getReportRevisionDiffHandler},
+		{"GET", "/api/reports/{id}/revisions/{revision:[0-9]+}", authenticated, getReportRevisionHandler},
+		{"POST", "/api/reports/{id}/revisions/{revision:[0-9]+}/restore", adminOnly, restoreReportRevisionHandler},
+		{"GET", "/api/admin/duplicate-reports", adminOnly, getDuplicateReportsHandler},
+		{"POST", "/api/admin/duplicate-reports/{id}/merge", adminOnly, mergeDuplicateReportsHandler},
+		{"GET", "/api/search", authenticated, searchHandler},
+		{"GET", "/api/admin/trash", adminOnly, getTrashHandler},
+		{"POST", "/api/admin/trash/{type}/{id}/restore", adminOnly, restoreTrashHandler},
+		{"GET", "/api/admin/audit-log", adminOnly, getAuditLogHandler},
+		{"GET", "/api/admin/audit-log/export", adminOnly, exportAuditLogHandler},
+		{"GET", "/api/admin/report-chain", adminOnly, getReportChainHandler},
+		{"GET", "/api/admin/report-chain/verify", adminOnly, verifyReportChainHandler},
+	})
+
+	// Refuse to start if any API route was registered without an access policy
+	if err := r.verifyPolicies(); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.Server.TLS.Enabled() {
+		fmt.Printf("Server starting on %s (TLS)\n", cfg.Server.ListenAddr)
+		log.Fatal(http.ListenAndServeTLS(cfg.Server.ListenAddr, cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile, r))
+	}
+	fmt.Printf("Server starting on %s\n", cfg.Server.ListenAddr)
+	log.Fatal(http.ListenAndServe(cfg.Server.ListenAddr, r))
 }
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Each driver has its own directory of migrations with matching versions.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// Arbitrary key for pg_advisory_xact_lock so that concurrent instances
+// starting with auto_migrate do not apply the same migration twice.
+const migrationLockKey = 7294103
+
+// Migrations are embedded files named NNNN_name.up.sql / NNNN_name.down.sql.
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func loadMigrations(driver string) ([]migration, error) {
+	migrationDir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, migrationDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, label, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_name.%s.sql", name, direction)
+		}
+
+		contents, err := fs.ReadFile(migrationFiles, path.Join(migrationDir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %04d has conflicting names %q and %q", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name VARCHAR(200) NOT NULL,
+            applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`)
+	return err
+}
+
+// appliedMigrations returns the applied_at timestamp of every applied version.
+func appliedMigrations(db *sql.DB) (map[int]string, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs one migration script and records (or removes) its
+// version in the same transaction. It returns false if another instance
+// already did the work.
+func applyMigration(db *sql.DB, driver string, m migration, up bool) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// SQLite serializes writers on its own
+	if driver == driverPostgres {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockKey); err != nil {
+			return false, err
+		}
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = $1", m.Version).Scan(&count); err != nil {
+		return false, err
+	}
+	if (count > 0) == up {
+		return false, nil
+	}
+
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return false, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// migrateUp applies every pending migration in version order.
+func migrateUp(db *sql.DB, driver string) error {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		done, err := applyMigration(db, driver, m, true)
+		if err != nil {
+			return err
+		}
+		if done {
+			fmt.Printf("Applied migration %04d_%s\n", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// migrateDown rolls back the given number of most recently applied migrations.
+func migrateDown(db *sql.DB, driver string, steps int) error {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return fmt.Errorf("migration %04d_%s cannot be rolled back", m.Version, m.Name)
+		}
+		done, err := applyMigration(db, driver, m, false)
+		if err != nil {
+			return err
+		}
+		if done {
+			fmt.Printf("Rolled back migration %04d_%s\n", m.Version, m.Name)
+		}
+		steps--
+	}
+	return nil
+}
+
+func pendingMigrations(db *sql.DB, driver string) (int, error) {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func printMigrationStatus(db *sql.DB, driver string) error {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		status := "pending"
+		if appliedAt, ok := applied[m.Version]; ok {
+			status = "applied " + appliedAt
+		}
+		fmt.Printf("%04d_%-40s %s\n", m.Version, m.Name, status)
+	}
+	return nil
+}
+
+// runMigrateCommand implements `daily_report migrate up|down [N]|status`.
+func runMigrateCommand(db *sql.DB, driver string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: daily_report migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(db, driver)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return migrateDown(db, driver, steps)
+	case "status":
+		return printMigrationStatus(db, driver)
+	}
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
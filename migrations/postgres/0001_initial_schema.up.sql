@@ -1,11 +1,8 @@
--- Create database
-CREATE DATABASE daily_reports;
-
--- Connect to the database
-\c daily_reports;
+-- Initial schema. Uses IF NOT EXISTS so databases created from the old
+-- database.sql script can be brought under migration control.
 
 -- Users table
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     username VARCHAR(50) UNIQUE NOT NULL,
     password VARCHAR(255) NOT NULL,
@@ -15,7 +12,7 @@ CREATE TABLE users (
 );
 
 -- Shift hours management
-CREATE TABLE shift_hours (
+CREATE TABLE IF NOT EXISTS shift_hours (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
     start_time TIME NOT NULL,
@@ -24,14 +21,14 @@ CREATE TABLE shift_hours (
 );
 
 -- Event titles management
-CREATE TABLE event_titles (
+CREATE TABLE IF NOT EXISTS event_titles (
     id SERIAL PRIMARY KEY,
     title VARCHAR(200) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Daily reports
-CREATE TABLE daily_reports (
+CREATE TABLE IF NOT EXISTS daily_reports (
     id SERIAL PRIMARY KEY,
     report_date DATE NOT NULL,
     shift_hours_id INTEGER REFERENCES shift_hours(id),
@@ -43,21 +40,21 @@ CREATE TABLE daily_reports (
 );
 
 -- Report shift managers (many-to-many)
-CREATE TABLE report_shift_managers (
+CREATE TABLE IF NOT EXISTS report_shift_managers (
     report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
     user_id INTEGER REFERENCES users(id),
     PRIMARY KEY (report_id, user_id)
 );
 
 -- Report event titles (many-to-many)
-CREATE TABLE report_event_titles (
+CREATE TABLE IF NOT EXISTS report_event_titles (
     report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
     event_title_id INTEGER REFERENCES event_titles(id),
     PRIMARY KEY (report_id, event_title_id)
 );
 
 -- Part 3 events (with RCA)
-CREATE TABLE report_events_part3 (
+CREATE TABLE IF NOT EXISTS report_events_part3 (
     id SERIAL PRIMARY KEY,
     report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
     event_summary TEXT,
@@ -68,7 +65,7 @@ CREATE TABLE report_events_part3 (
 );
 
 -- Part 4 events (without RCA)
-CREATE TABLE report_events_part4 (
+CREATE TABLE IF NOT EXISTS report_events_part4 (
     id SERIAL PRIMARY KEY,
     report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
     event_summary TEXT,
@@ -77,23 +74,42 @@ CREATE TABLE report_events_part4 (
     end_time TIMESTAMP
 );
 
--- Insert default admin user (password: admin123)
-INSERT INTO users (username, password, full_name, role) 
-VALUES ('admin', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'System Administrator', 'admin');
+-- Server-side sessions (the cookie only carries the signed token)
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    data BYTEA NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_activity TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
 
--- Insert sample data
-INSERT INTO shift_hours (name, start_time, end_time) VALUES 
-('Morning Shift', '06:00:00', '14:00:00'),
-('Evening Shift', '14:00:00', '22:00:00'),
-('Night Shift', '22:00:00', '06:00:00');
+-- Default admin user (password: password)
+INSERT INTO users (username, password, full_name, role)
+VALUES ('admin', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'System Administrator', 'admin')
+ON CONFLICT (username) DO NOTHING;
 
-INSERT INTO event_titles (title) VALUES 
-('System Maintenance'),
-('Security Check'),
-('Equipment Inspection'),
-('Emergency Response'),
-('Routine Monitoring');
+-- Default shifts and event titles, only on an empty catalog
+INSERT INTO shift_hours (name, start_time, end_time)
+SELECT * FROM (VALUES
+    ('Morning Shift', TIME '06:00:00', TIME '14:00:00'),
+    ('Evening Shift', TIME '14:00:00', TIME '22:00:00'),
+    ('Night Shift', TIME '22:00:00', TIME '06:00:00')
+) AS defaults
+WHERE NOT EXISTS (SELECT 1 FROM shift_hours);
 
-INSERT INTO users (username, password, full_name, role) VALUES 
-('manager1', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'John Manager', 'user'),
-('manager2', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'Jane Supervisor', 'user');
\ No newline at end of file
+INSERT INTO event_titles (title)
+SELECT * FROM (VALUES
+    ('System Maintenance'),
+    ('Security Check'),
+    ('Equipment Inspection'),
+    ('Emergency Response'),
+    ('Routine Monitoring')
+) AS defaults
+WHERE NOT EXISTS (SELECT 1 FROM event_titles);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_part4_search;
+DROP INDEX IF EXISTS idx_events_part3_search;
+ALTER TABLE report_events_part4 DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE report_events_part3 DROP COLUMN IF EXISTS search_vector;
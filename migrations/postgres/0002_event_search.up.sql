@@ -0,0 +1,15 @@
+-- Full-text search over event summaries (weight A) and trigger info (weight B)
+ALTER TABLE report_events_part3 ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(event_summary, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(trigger_info, '')), 'B')
+    ) STORED;
+
+ALTER TABLE report_events_part4 ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(event_summary, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(trigger_info, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_events_part3_search ON report_events_part3 USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_events_part4_search ON report_events_part4 USING GIN (search_vector);
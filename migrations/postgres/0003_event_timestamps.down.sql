@@ -0,0 +1,5 @@
+ALTER TABLE report_events_part4 DROP CONSTRAINT IF EXISTS report_events_part4_end_after_start;
+ALTER TABLE report_events_part3 DROP CONSTRAINT IF EXISTS report_events_part3_end_after_start;
+
+UPDATE report_events_part3 SET start_time = DATE '1970-01-01' + start_time::time, end_time = DATE '1970-01-01' + end_time::time;
+UPDATE report_events_part4 SET start_time = DATE '1970-01-01' + start_time::time, end_time = DATE '1970-01-01' + end_time::time;
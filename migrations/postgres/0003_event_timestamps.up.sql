@@ -0,0 +1,37 @@
+-- Event times used to be stored on 1970-01-01. Move them onto the report date,
+-- rolling times before the shift start to the next day on shifts that cross
+-- midnight.
+UPDATE report_events_part3 e SET start_time = dr.report_date + e.start_time::time
+    + CASE WHEN sh.end_time <= sh.start_time AND e.start_time::time < sh.start_time
+           THEN INTERVAL '1 day' ELSE INTERVAL '0 days' END
+FROM daily_reports dr
+LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+WHERE dr.id = e.report_id AND e.start_time::date = DATE '1970-01-01';
+
+UPDATE report_events_part3 e SET end_time = dr.report_date + e.end_time::time
+    + CASE WHEN sh.end_time <= sh.start_time AND e.end_time::time < sh.start_time
+           THEN INTERVAL '1 day' ELSE INTERVAL '0 days' END
+FROM daily_reports dr
+LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+WHERE dr.id = e.report_id AND e.end_time::date = DATE '1970-01-01';
+
+UPDATE report_events_part4 e SET start_time = dr.report_date + e.start_time::time
+    + CASE WHEN sh.end_time <= sh.start_time AND e.start_time::time < sh.start_time
+           THEN INTERVAL '1 day' ELSE INTERVAL '0 days' END
+FROM daily_reports dr
+LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+WHERE dr.id = e.report_id AND e.start_time::date = DATE '1970-01-01';
+
+UPDATE report_events_part4 e SET end_time = dr.report_date + e.end_time::time
+    + CASE WHEN sh.end_time <= sh.start_time AND e.end_time::time < sh.start_time
+           THEN INTERVAL '1 day' ELSE INTERVAL '0 days' END
+FROM daily_reports dr
+LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+WHERE dr.id = e.report_id AND e.end_time::date = DATE '1970-01-01';
+
+-- End times were never validated; an end before the start ran past midnight
+UPDATE report_events_part3 SET end_time = end_time + INTERVAL '1 day' WHERE end_time < start_time;
+UPDATE report_events_part4 SET end_time = end_time + INTERVAL '1 day' WHERE end_time < start_time;
+
+ALTER TABLE report_events_part3 ADD CONSTRAINT report_events_part3_end_after_start CHECK (end_time >= start_time);
+ALTER TABLE report_events_part4 ADD CONSTRAINT report_events_part4_end_after_start CHECK (end_time >= start_time);
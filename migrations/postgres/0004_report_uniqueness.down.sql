@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_daily_reports_site_date_shift;
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS duplicate_of;
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS site_id;
+DROP TABLE IF EXISTS sites;
@@ -0,0 +1,29 @@
+-- Sites. Every existing report belongs to the default site.
+CREATE TABLE IF NOT EXISTS sites (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO sites (id, name) VALUES (1, 'Main Site') ON CONFLICT DO NOTHING;
+SELECT setval('sites_id_seq', (SELECT MAX(id) FROM sites));
+
+ALTER TABLE daily_reports ADD COLUMN IF NOT EXISTS site_id INTEGER NOT NULL DEFAULT 1 REFERENCES sites(id);
+
+-- One report per site, date and shift. Reports that already break the rule
+-- point at the oldest report of their group until an admin merges them.
+ALTER TABLE daily_reports ADD COLUMN IF NOT EXISTS duplicate_of INTEGER;
+
+UPDATE daily_reports dr SET duplicate_of = first.id
+FROM (
+    SELECT site_id, report_date, COALESCE(shift_hours_id, 0) AS shift_key, MIN(id) AS id
+    FROM daily_reports
+    GROUP BY site_id, report_date, COALESCE(shift_hours_id, 0)
+    HAVING COUNT(*) > 1
+) first
+WHERE dr.site_id = first.site_id AND dr.report_date = first.report_date
+  AND COALESCE(dr.shift_hours_id, 0) = first.shift_key AND dr.id <> first.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_reports_site_date_shift
+    ON daily_reports (site_id, report_date, COALESCE(shift_hours_id, 0))
+    WHERE duplicate_of IS NULL;
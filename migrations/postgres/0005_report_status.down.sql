@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS report_status_history;
+DROP INDEX IF EXISTS idx_daily_reports_status;
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS status_comment;
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS status;
@@ -0,0 +1,22 @@
+-- Report lifecycle: draft -> submitted -> approved/rejected -> locked.
+-- Reports filed before the workflow existed are treated as submitted.
+ALTER TABLE daily_reports ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'draft'
+    CHECK (status IN ('draft', 'submitted', 'approved', 'rejected', 'locked'));
+ALTER TABLE daily_reports ADD COLUMN IF NOT EXISTS status_comment TEXT;
+
+UPDATE daily_reports SET status = 'submitted';
+
+CREATE INDEX IF NOT EXISTS idx_daily_reports_status ON daily_reports (status);
+
+-- Every status change, with the reviewer's comment
+CREATE TABLE IF NOT EXISTS report_status_history (
+    id SERIAL PRIMARY KEY,
+    report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    comment TEXT,
+    changed_by INTEGER REFERENCES users(id),
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_status_history_report_id ON report_status_history (report_id);
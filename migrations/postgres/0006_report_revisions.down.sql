@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS report_revisions;
+DROP FUNCTION IF EXISTS report_revisions_immutable();
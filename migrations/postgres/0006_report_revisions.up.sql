@@ -0,0 +1,22 @@
+-- Immutable snapshot of a report after every save. Reports saved before this
+-- migration get their first revision from their state before the next save.
+CREATE TABLE IF NOT EXISTS report_revisions (
+    id SERIAL PRIMARY KEY,
+    report_id INTEGER NOT NULL REFERENCES daily_reports(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    snapshot TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (report_id, revision)
+);
+
+CREATE OR REPLACE FUNCTION report_revisions_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'report revisions cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS report_revisions_immutable ON report_revisions;
+CREATE TRIGGER report_revisions_immutable
+BEFORE UPDATE ON report_revisions
+FOR EACH ROW EXECUTE FUNCTION report_revisions_immutable();
@@ -0,0 +1 @@
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS version;
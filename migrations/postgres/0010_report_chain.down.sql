@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS report_chain;
+DROP FUNCTION IF EXISTS report_chain_append_only();
@@ -0,0 +1,23 @@
+-- Tamper-evident chain over locked reports. Each link holds the SHA-256 of the
+-- report's canonical form, the previous link's hash and an Ed25519 signature
+-- of its own hash.
+CREATE TABLE IF NOT EXISTS report_chain (
+    seq INTEGER PRIMARY KEY,
+    report_id INTEGER NOT NULL UNIQUE REFERENCES daily_reports(id),
+    content_hash VARCHAR(64) NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL UNIQUE,
+    hash VARCHAR(64) NOT NULL,
+    signature TEXT NOT NULL,
+    sealed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION report_chain_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'report chain links cannot be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS report_chain_append_only ON report_chain;
+CREATE TRIGGER report_chain_append_only
+BEFORE UPDATE OR DELETE ON report_chain
+FOR EACH ROW EXECUTE FUNCTION report_chain_append_only();
@@ -0,0 +1,13 @@
+ALTER TABLE daily_reports ADD COLUMN IF NOT EXISTS health_power_sources BOOLEAN DEFAULT FALSE;
+ALTER TABLE daily_reports ADD COLUMN IF NOT EXISTS health_humidity_temp BOOLEAN DEFAULT FALSE;
+ALTER TABLE daily_reports ADD COLUMN IF NOT EXISTS health_fire_system BOOLEAN DEFAULT FALSE;
+
+-- Only items 1 to 3 map back; a check passes only if it was ok
+UPDATE daily_reports SET
+    health_power_sources = EXISTS (SELECT 1 FROM report_health_checks hc WHERE hc.report_id = daily_reports.id AND hc.item_id = 1 AND hc.status = 'ok'),
+    health_humidity_temp = EXISTS (SELECT 1 FROM report_health_checks hc WHERE hc.report_id = daily_reports.id AND hc.item_id = 2 AND hc.status = 'ok'),
+    health_fire_system = EXISTS (SELECT 1 FROM report_health_checks hc WHERE hc.report_id = daily_reports.id AND hc.item_id = 3 AND hc.status = 'ok');
+
+DROP TABLE IF EXISTS report_health_checks;
+DROP TABLE IF EXISTS health_check_item_shifts;
+DROP TABLE IF EXISTS health_check_items;
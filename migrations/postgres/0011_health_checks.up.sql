@@ -0,0 +1,49 @@
+-- Admin-managed health checklist replacing the three fixed health columns.
+CREATE TABLE IF NOT EXISTS health_check_items (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    description TEXT,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INTEGER REFERENCES users(id)
+);
+
+-- An item without rows here applies to every shift
+CREATE TABLE IF NOT EXISTS health_check_item_shifts (
+    item_id INTEGER NOT NULL REFERENCES health_check_items(id) ON DELETE CASCADE,
+    shift_hours_id INTEGER NOT NULL REFERENCES shift_hours(id) ON DELETE CASCADE,
+    PRIMARY KEY (item_id, shift_hours_id)
+);
+
+CREATE TABLE IF NOT EXISTS report_health_checks (
+    report_id INTEGER NOT NULL REFERENCES daily_reports(id) ON DELETE CASCADE,
+    item_id INTEGER NOT NULL REFERENCES health_check_items(id),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('ok', 'warning', 'fail', 'not_checked')),
+    comment TEXT,
+    PRIMARY KEY (report_id, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_health_checks_item ON report_health_checks(item_id);
+
+-- The former columns become items 1 to 3; an unticked box was a failed check
+INSERT INTO health_check_items (id, name, sort_order) VALUES
+    (1, 'Power Sources', 10),
+    (2, 'Humidity & Temperature', 20),
+    (3, 'Fire System', 30);
+SELECT setval(pg_get_serial_sequence('health_check_items', 'id'), 3);
+
+INSERT INTO report_health_checks (report_id, item_id, status)
+SELECT id, 1, CASE WHEN health_power_sources IS NULL THEN 'not_checked' WHEN health_power_sources THEN 'ok' ELSE 'fail' END
+FROM daily_reports;
+INSERT INTO report_health_checks (report_id, item_id, status)
+SELECT id, 2, CASE WHEN health_humidity_temp IS NULL THEN 'not_checked' WHEN health_humidity_temp THEN 'ok' ELSE 'fail' END
+FROM daily_reports;
+INSERT INTO report_health_checks (report_id, item_id, status)
+SELECT id, 3, CASE WHEN health_fire_system IS NULL THEN 'not_checked' WHEN health_fire_system THEN 'ok' ELSE 'fail' END
+FROM daily_reports;
+
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS health_power_sources;
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS health_humidity_temp;
+ALTER TABLE daily_reports DROP COLUMN IF EXISTS health_fire_system;
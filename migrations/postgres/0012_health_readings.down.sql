@@ -0,0 +1,12 @@
+ALTER TABLE report_health_checks DROP COLUMN IF EXISTS reading_unit;
+ALTER TABLE report_health_checks DROP COLUMN IF EXISTS reading_text;
+ALTER TABLE report_health_checks DROP COLUMN IF EXISTS reading_number;
+
+DROP TABLE IF EXISTS health_check_item_options;
+
+ALTER TABLE health_check_items DROP COLUMN IF EXISTS critical_max;
+ALTER TABLE health_check_items DROP COLUMN IF EXISTS critical_min;
+ALTER TABLE health_check_items DROP COLUMN IF EXISTS warning_max;
+ALTER TABLE health_check_items DROP COLUMN IF EXISTS warning_min;
+ALTER TABLE health_check_items DROP COLUMN IF EXISTS unit;
+ALTER TABLE health_check_items DROP COLUMN IF EXISTS reading_type;
@@ -0,0 +1,23 @@
+-- Typed readings on health check items. Number readings are rated against the
+-- warning and critical limits, enum readings by the status of their option.
+ALTER TABLE health_check_items ADD COLUMN IF NOT EXISTS reading_type VARCHAR(10) NOT NULL DEFAULT 'none'
+    CHECK (reading_type IN ('none', 'number', 'enum', 'text'));
+ALTER TABLE health_check_items ADD COLUMN IF NOT EXISTS unit VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE health_check_items ADD COLUMN IF NOT EXISTS warning_min DOUBLE PRECISION;
+ALTER TABLE health_check_items ADD COLUMN IF NOT EXISTS warning_max DOUBLE PRECISION;
+ALTER TABLE health_check_items ADD COLUMN IF NOT EXISTS critical_min DOUBLE PRECISION;
+ALTER TABLE health_check_items ADD COLUMN IF NOT EXISTS critical_max DOUBLE PRECISION;
+
+CREATE TABLE IF NOT EXISTS health_check_item_options (
+    item_id INTEGER NOT NULL REFERENCES health_check_items(id) ON DELETE CASCADE,
+    value VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('ok', 'warning', 'fail')),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, value)
+);
+
+-- The unit is kept with the reading so that changing the item does not
+-- change recorded values
+ALTER TABLE report_health_checks ADD COLUMN IF NOT EXISTS reading_number DOUBLE PRECISION;
+ALTER TABLE report_health_checks ADD COLUMN IF NOT EXISTS reading_text TEXT;
+ALTER TABLE report_health_checks ADD COLUMN IF NOT EXISTS reading_unit VARCHAR(20);
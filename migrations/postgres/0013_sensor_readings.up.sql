@@ -0,0 +1,30 @@
+-- Sensor readings sent by monitoring devices. A health check item with a
+-- sensor_key receives the readings sent for that key.
+ALTER TABLE health_check_items ADD COLUMN IF NOT EXISTS sensor_key VARCHAR(100);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_health_check_items_sensor_key ON health_check_items(sensor_key)
+    WHERE sensor_key IS NOT NULL AND deleted_at IS NULL;
+
+-- Only the SHA-256 of a token is stored; the token itself is shown once
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sensor_readings (
+    id SERIAL PRIMARY KEY,
+    item_id INTEGER NOT NULL REFERENCES health_check_items(id) ON DELETE CASCADE,
+    recorded_at TIMESTAMP NOT NULL,
+    reading_number DOUBLE PRECISION,
+    reading_text TEXT,
+    reading_unit VARCHAR(20),
+    token_id INTEGER NOT NULL REFERENCES api_tokens(id),
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_readings_item_time ON sensor_readings(item_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_sensor_readings_time ON sensor_readings(recorded_at);
@@ -0,0 +1,6 @@
+ALTER TABLE report_events_part3 ADD COLUMN IF NOT EXISTS rca_number VARCHAR(50);
+UPDATE report_events_part3 SET rca_number = (SELECT r.number FROM rcas r WHERE r.id = report_events_part3.rca_id);
+
+DROP INDEX IF EXISTS idx_events_part3_rca;
+ALTER TABLE report_events_part3 DROP COLUMN IF EXISTS rca_id;
+DROP TABLE IF EXISTS rcas;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS rca_escalations;
+
+ALTER TABLE rcas ADD COLUMN IF NOT EXISTS corrective_actions TEXT;
+UPDATE rcas SET corrective_actions = (SELECT string_agg(a.description, E'\n' ORDER BY a.id) FROM rca_actions a WHERE a.rca_id = rcas.id);
+DROP TABLE IF EXISTS rca_actions;
@@ -0,0 +1,29 @@
+-- Corrective actions become records of their own with an assignee, a due
+-- date and a status, replacing the free-text corrective_actions column.
+CREATE TABLE IF NOT EXISTS rca_actions (
+    id SERIAL PRIMARY KEY,
+    rca_id INTEGER NOT NULL REFERENCES rcas(id) ON DELETE CASCADE,
+    description TEXT NOT NULL,
+    assignee_id INTEGER REFERENCES users(id),
+    due_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'in_progress', 'done', 'cancelled')),
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rca_actions_rca ON rca_actions(rca_id);
+CREATE INDEX IF NOT EXISTS idx_rca_actions_assignee ON rca_actions(assignee_id, status);
+
+-- The text written so far becomes one open action of its RCA
+INSERT INTO rca_actions (rca_id, description)
+SELECT id, corrective_actions FROM rcas WHERE COALESCE(corrective_actions, '') <> '';
+ALTER TABLE rcas DROP COLUMN IF EXISTS corrective_actions;
+
+-- Part 3 events the escalation job flagged for follow-up
+CREATE TABLE IF NOT EXISTS rca_escalations (
+    event_id INTEGER NOT NULL REFERENCES report_events_part3(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('missing_rca', 'overdue_actions')),
+    flagged_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, reason)
+);
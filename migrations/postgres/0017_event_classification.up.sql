@@ -0,0 +1,50 @@
+-- Admin-managed catalogs that classify events. A higher severity level is
+-- more severe.
+CREATE TABLE IF NOT EXISTS severities (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    level INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INTEGER REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS event_categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INTEGER REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS affected_services (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INTEGER REFERENCES users(id)
+);
+
+-- Default severities, only on an empty catalog
+INSERT INTO severities (name, level)
+SELECT * FROM (VALUES
+    ('Low', 1),
+    ('Medium', 2),
+    ('High', 3),
+    ('Critical', 4)
+) AS defaults
+WHERE NOT EXISTS (SELECT 1 FROM severities);
+
+ALTER TABLE report_events_part3 ADD COLUMN IF NOT EXISTS severity_id INTEGER REFERENCES severities(id);
+ALTER TABLE report_events_part3 ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES event_categories(id);
+ALTER TABLE report_events_part3 ADD COLUMN IF NOT EXISTS affected_service_id INTEGER REFERENCES affected_services(id);
+ALTER TABLE report_events_part4 ADD COLUMN IF NOT EXISTS severity_id INTEGER REFERENCES severities(id);
+ALTER TABLE report_events_part4 ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES event_categories(id);
+ALTER TABLE report_events_part4 ADD COLUMN IF NOT EXISTS affected_service_id INTEGER REFERENCES affected_services(id);
+
+CREATE INDEX IF NOT EXISTS idx_events_part3_severity ON report_events_part3(severity_id);
+CREATE INDEX IF NOT EXISTS idx_events_part3_category ON report_events_part3(category_id);
+CREATE INDEX IF NOT EXISTS idx_events_part3_affected_service ON report_events_part3(affected_service_id);
+CREATE INDEX IF NOT EXISTS idx_events_part4_severity ON report_events_part4(severity_id);
+CREATE INDEX IF NOT EXISTS idx_events_part4_category ON report_events_part4(category_id);
+CREATE INDEX IF NOT EXISTS idx_events_part4_affected_service ON report_events_part4(affected_service_id);
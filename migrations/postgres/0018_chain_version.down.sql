@@ -0,0 +1 @@
+ALTER TABLE report_chain DROP COLUMN IF EXISTS canonical_version;
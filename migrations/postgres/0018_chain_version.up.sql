@@ -0,0 +1,3 @@
+-- Version of the canonical report form each link was sealed with. Links
+-- sealed before it was recorded get theirs in 0019.
+ALTER TABLE report_chain ADD COLUMN IF NOT EXISTS canonical_version INTEGER;
@@ -0,0 +1,2 @@
+-- The recorded versions stay: they are what the links were sealed with.
+SELECT 1;
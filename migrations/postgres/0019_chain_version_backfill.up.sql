@@ -0,0 +1,13 @@
+-- Record the canonical form version of links sealed before versions were:
+-- links sealed before the health checklist replaced the fixed health columns
+-- are version 1, later ones version 2. Links are otherwise append-only.
+ALTER TABLE report_chain DISABLE TRIGGER report_chain_append_only;
+
+UPDATE report_chain
+SET canonical_version = CASE
+    WHEN sealed_at < (SELECT applied_at FROM schema_migrations WHERE version = 11) THEN 1
+    ELSE 2
+END
+WHERE canonical_version IS NULL;
+
+ALTER TABLE report_chain ENABLE TRIGGER report_chain_append_only;
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS report_events_part4;
+DROP TABLE IF EXISTS report_events_part3;
+DROP TABLE IF EXISTS report_event_titles;
+DROP TABLE IF EXISTS report_shift_managers;
+DROP TABLE IF EXISTS daily_reports;
+DROP TABLE IF EXISTS event_titles;
+DROP TABLE IF EXISTS shift_hours;
+DROP TABLE IF EXISTS users;
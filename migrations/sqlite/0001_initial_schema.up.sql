@@ -0,0 +1,114 @@
+-- Initial schema (SQLite). Mirrors migrations/postgres/0001_initial_schema.up.sql.
+
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) UNIQUE NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    full_name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Shift hours management
+CREATE TABLE IF NOT EXISTS shift_hours (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Event titles management
+CREATE TABLE IF NOT EXISTS event_titles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(200) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Daily reports
+CREATE TABLE IF NOT EXISTS daily_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    report_date DATE NOT NULL,
+    shift_hours_id INTEGER REFERENCES shift_hours(id),
+    health_power_sources BOOLEAN DEFAULT FALSE,
+    health_humidity_temp BOOLEAN DEFAULT FALSE,
+    health_fire_system BOOLEAN DEFAULT FALSE,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Report shift managers (many-to-many)
+CREATE TABLE IF NOT EXISTS report_shift_managers (
+    report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id),
+    PRIMARY KEY (report_id, user_id)
+);
+
+-- Report event titles (many-to-many)
+CREATE TABLE IF NOT EXISTS report_event_titles (
+    report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
+    event_title_id INTEGER REFERENCES event_titles(id),
+    PRIMARY KEY (report_id, event_title_id)
+);
+
+-- Part 3 events (with RCA)
+CREATE TABLE IF NOT EXISTS report_events_part3 (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
+    event_summary TEXT,
+    trigger_info TEXT,
+    start_time TIMESTAMP,
+    end_time TIMESTAMP,
+    rca_number VARCHAR(50)
+);
+
+-- Part 4 events (without RCA)
+CREATE TABLE IF NOT EXISTS report_events_part4 (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    report_id INTEGER REFERENCES daily_reports(id) ON DELETE CASCADE,
+    event_summary TEXT,
+    trigger_info TEXT,
+    start_time TIMESTAMP,
+    end_time TIMESTAMP
+);
+
+-- Server-side sessions (the cookie only carries the signed token)
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    data BLOB NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_activity TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- Default admin user (password: password)
+INSERT INTO users (username, password, full_name, role)
+VALUES ('admin', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'System Administrator', 'admin')
+ON CONFLICT (username) DO NOTHING;
+
+-- Default shifts and event titles, only on an empty catalog
+INSERT INTO shift_hours (name, start_time, end_time)
+SELECT * FROM (VALUES
+    ('Morning Shift', '06:00:00', '14:00:00'),
+    ('Evening Shift', '14:00:00', '22:00:00'),
+    ('Night Shift', '22:00:00', '06:00:00')
+)
+WHERE NOT EXISTS (SELECT 1 FROM shift_hours);
+
+INSERT INTO event_titles (title)
+SELECT * FROM (VALUES
+    ('System Maintenance'),
+    ('Security Check'),
+    ('Equipment Inspection'),
+    ('Emergency Response'),
+    ('Routine Monitoring')
+)
+WHERE NOT EXISTS (SELECT 1 FROM event_titles);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS events_part4_search_delete;
+DROP TRIGGER IF EXISTS events_part4_search_update;
+DROP TRIGGER IF EXISTS events_part4_search_insert;
+DROP TRIGGER IF EXISTS events_part3_search_delete;
+DROP TRIGGER IF EXISTS events_part3_search_update;
+DROP TRIGGER IF EXISTS events_part3_search_insert;
+DROP TABLE IF EXISTS event_search;
@@ -0,0 +1,47 @@
+-- Full-text search over event summaries and trigger info (SQLite FTS5).
+-- One index covers both parts: the rowid is the event id * 2 for Part 3 and
+-- event id * 2 + 1 for Part 4. Triggers keep it in sync with the event tables.
+CREATE VIRTUAL TABLE IF NOT EXISTS event_search USING fts5(
+    event_summary,
+    trigger_info,
+    part UNINDEXED,
+    event_id UNINDEXED,
+    report_id UNINDEXED,
+    tokenize = 'porter unicode61'
+);
+
+INSERT INTO event_search (rowid, event_summary, trigger_info, part, event_id, report_id)
+SELECT id * 2, COALESCE(event_summary, ''), COALESCE(trigger_info, ''), 3, id, report_id FROM report_events_part3;
+
+INSERT INTO event_search (rowid, event_summary, trigger_info, part, event_id, report_id)
+SELECT id * 2 + 1, COALESCE(event_summary, ''), COALESCE(trigger_info, ''), 4, id, report_id FROM report_events_part4;
+
+CREATE TRIGGER IF NOT EXISTS events_part3_search_insert AFTER INSERT ON report_events_part3 BEGIN
+    INSERT INTO event_search (rowid, event_summary, trigger_info, part, event_id, report_id)
+    VALUES (new.id * 2, COALESCE(new.event_summary, ''), COALESCE(new.trigger_info, ''), 3, new.id, new.report_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_part3_search_update AFTER UPDATE ON report_events_part3 BEGIN
+    DELETE FROM event_search WHERE rowid = old.id * 2;
+    INSERT INTO event_search (rowid, event_summary, trigger_info, part, event_id, report_id)
+    VALUES (new.id * 2, COALESCE(new.event_summary, ''), COALESCE(new.trigger_info, ''), 3, new.id, new.report_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_part3_search_delete AFTER DELETE ON report_events_part3 BEGIN
+    DELETE FROM event_search WHERE rowid = old.id * 2;
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_part4_search_insert AFTER INSERT ON report_events_part4 BEGIN
+    INSERT INTO event_search (rowid, event_summary, trigger_info, part, event_id, report_id)
+    VALUES (new.id * 2 + 1, COALESCE(new.event_summary, ''), COALESCE(new.trigger_info, ''), 4, new.id, new.report_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_part4_search_update AFTER UPDATE ON report_events_part4 BEGIN
+    DELETE FROM event_search WHERE rowid = old.id * 2 + 1;
+    INSERT INTO event_search (rowid, event_summary, trigger_info, part, event_id, report_id)
+    VALUES (new.id * 2 + 1, COALESCE(new.event_summary, ''), COALESCE(new.trigger_info, ''), 4, new.id, new.report_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_part4_search_delete AFTER DELETE ON report_events_part4 BEGIN
+    DELETE FROM event_search WHERE rowid = old.id * 2 + 1;
+END;
@@ -0,0 +1,2 @@
+UPDATE report_events_part3 SET start_time = '1970-01-01 ' || time(start_time), end_time = '1970-01-01 ' || time(end_time);
+UPDATE report_events_part4 SET start_time = '1970-01-01 ' || time(start_time), end_time = '1970-01-01 ' || time(end_time);
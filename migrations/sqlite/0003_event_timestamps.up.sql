@@ -0,0 +1,43 @@
+-- Event times used to be stored on 1970-01-01. Move them onto the report date,
+-- rolling times before the shift start to the next day on shifts that cross
+-- midnight. Mirrors migrations/postgres/0003_event_timestamps.up.sql; SQLite
+-- cannot add the end >= start CHECK to existing tables, the server enforces it.
+UPDATE report_events_part3 SET start_time = (
+    SELECT datetime(date(dr.report_date) || ' ' || time(report_events_part3.start_time),
+                    CASE WHEN time(sh.end_time) <= time(sh.start_time) AND time(report_events_part3.start_time) < time(sh.start_time)
+                         THEN '+1 day' ELSE '+0 days' END)
+    FROM daily_reports dr
+    LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+    WHERE dr.id = report_events_part3.report_id)
+WHERE date(start_time) = '1970-01-01';
+
+UPDATE report_events_part3 SET end_time = (
+    SELECT datetime(date(dr.report_date) || ' ' || time(report_events_part3.end_time),
+                    CASE WHEN time(sh.end_time) <= time(sh.start_time) AND time(report_events_part3.end_time) < time(sh.start_time)
+                         THEN '+1 day' ELSE '+0 days' END)
+    FROM daily_reports dr
+    LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+    WHERE dr.id = report_events_part3.report_id)
+WHERE date(end_time) = '1970-01-01';
+
+UPDATE report_events_part4 SET start_time = (
+    SELECT datetime(date(dr.report_date) || ' ' || time(report_events_part4.start_time),
+                    CASE WHEN time(sh.end_time) <= time(sh.start_time) AND time(report_events_part4.start_time) < time(sh.start_time)
+                         THEN '+1 day' ELSE '+0 days' END)
+    FROM daily_reports dr
+    LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+    WHERE dr.id = report_events_part4.report_id)
+WHERE date(start_time) = '1970-01-01';
+
+UPDATE report_events_part4 SET end_time = (
+    SELECT datetime(date(dr.report_date) || ' ' || time(report_events_part4.end_time),
+                    CASE WHEN time(sh.end_time) <= time(sh.start_time) AND time(report_events_part4.end_time) < time(sh.start_time)
+                         THEN '+1 day' ELSE '+0 days' END)
+    FROM daily_reports dr
+    LEFT JOIN shift_hours sh ON sh.id = dr.shift_hours_id
+    WHERE dr.id = report_events_part4.report_id)
+WHERE date(end_time) = '1970-01-01';
+
+-- End times were never validated; an end before the start ran past midnight
+UPDATE report_events_part3 SET end_time = datetime(end_time, '+1 day') WHERE end_time < start_time;
+UPDATE report_events_part4 SET end_time = datetime(end_time, '+1 day') WHERE end_time < start_time;
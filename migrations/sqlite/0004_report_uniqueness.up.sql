@@ -0,0 +1,29 @@
+-- Sites. Every existing report belongs to the default site. Mirrors
+-- migrations/postgres/0004_report_uniqueness.up.sql, except that SQLite cannot
+-- add a column with both a REFERENCES clause and a non-NULL default.
+CREATE TABLE IF NOT EXISTS sites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO sites (id, name) VALUES (1, 'Main Site');
+
+ALTER TABLE daily_reports ADD COLUMN site_id INTEGER NOT NULL DEFAULT 1;
+
+-- One report per site, date and shift. Reports that already break the rule
+-- point at the oldest report of their group until an admin merges them.
+ALTER TABLE daily_reports ADD COLUMN duplicate_of INTEGER;
+
+UPDATE daily_reports SET duplicate_of = (
+    SELECT MIN(first.id) FROM daily_reports first
+    WHERE first.site_id = daily_reports.site_id AND first.report_date = daily_reports.report_date
+      AND COALESCE(first.shift_hours_id, 0) = COALESCE(daily_reports.shift_hours_id, 0))
+WHERE id <> (
+    SELECT MIN(first.id) FROM daily_reports first
+    WHERE first.site_id = daily_reports.site_id AND first.report_date = daily_reports.report_date
+      AND COALESCE(first.shift_hours_id, 0) = COALESCE(daily_reports.shift_hours_id, 0));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_reports_site_date_shift
+    ON daily_reports (site_id, report_date, COALESCE(shift_hours_id, 0))
+    WHERE duplicate_of IS NULL;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS report_revisions_immutable;
+DROP TABLE IF EXISTS report_revisions;
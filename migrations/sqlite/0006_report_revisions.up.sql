@@ -0,0 +1,17 @@
+-- Immutable snapshot of a report after every save. Reports saved before this
+-- migration get their first revision from their state before the next save.
+CREATE TABLE IF NOT EXISTS report_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    report_id INTEGER NOT NULL REFERENCES daily_reports(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    snapshot TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (report_id, revision)
+);
+
+CREATE TRIGGER IF NOT EXISTS report_revisions_immutable
+BEFORE UPDATE ON report_revisions
+BEGIN
+    SELECT RAISE(ABORT, 'report revisions cannot be changed');
+END;
@@ -0,0 +1,3 @@
+-- Incremented on every change to a report; sent as its ETag so concurrent
+-- edits can be detected.
+ALTER TABLE daily_reports ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_daily_reports_site_date_shift;
+ALTER TABLE daily_reports DROP COLUMN deleted_by;
+ALTER TABLE daily_reports DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deleted_by;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE shift_hours DROP COLUMN deleted_by;
+ALTER TABLE shift_hours DROP COLUMN deleted_at;
+ALTER TABLE event_titles DROP COLUMN deleted_by;
+ALTER TABLE event_titles DROP COLUMN deleted_at;
+ALTER TABLE sites DROP COLUMN deleted_by;
+ALTER TABLE sites DROP COLUMN deleted_at;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_reports_site_date_shift
+    ON daily_reports (site_id, report_date, COALESCE(shift_hours_id, 0))
+    WHERE duplicate_of IS NULL;
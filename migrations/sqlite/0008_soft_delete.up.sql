@@ -0,0 +1,18 @@
+-- Soft delete: deleted rows stay in place so historic reports still render
+-- them, and are hidden everywhere else until restored or purged.
+ALTER TABLE daily_reports ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE daily_reports ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+ALTER TABLE shift_hours ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE shift_hours ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+ALTER TABLE event_titles ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE event_titles ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+ALTER TABLE sites ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE sites ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+
+-- Deleted reports no longer take up their site, date and shift
+DROP INDEX IF EXISTS idx_daily_reports_site_date_shift;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_reports_site_date_shift
+    ON daily_reports (site_id, report_date, COALESCE(shift_hours_id, 0))
+    WHERE duplicate_of IS NULL AND deleted_at IS NULL;
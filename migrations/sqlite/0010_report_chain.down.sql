@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS report_chain_no_delete;
+DROP TRIGGER IF EXISTS report_chain_no_update;
+DROP TABLE IF EXISTS report_chain;
@@ -0,0 +1,24 @@
+-- Tamper-evident chain over locked reports. Each link holds the SHA-256 of the
+-- report's canonical form, the previous link's hash and an Ed25519 signature
+-- of its own hash.
+CREATE TABLE IF NOT EXISTS report_chain (
+    seq INTEGER PRIMARY KEY,
+    report_id INTEGER NOT NULL UNIQUE REFERENCES daily_reports(id),
+    content_hash VARCHAR(64) NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL UNIQUE,
+    hash VARCHAR(64) NOT NULL,
+    signature TEXT NOT NULL,
+    sealed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER IF NOT EXISTS report_chain_no_update
+BEFORE UPDATE ON report_chain
+BEGIN
+    SELECT RAISE(ABORT, 'report chain links cannot be changed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS report_chain_no_delete
+BEFORE DELETE ON report_chain
+BEGIN
+    SELECT RAISE(ABORT, 'report chain links cannot be deleted');
+END;
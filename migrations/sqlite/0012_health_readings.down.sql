@@ -0,0 +1,12 @@
+ALTER TABLE report_health_checks DROP COLUMN reading_unit;
+ALTER TABLE report_health_checks DROP COLUMN reading_text;
+ALTER TABLE report_health_checks DROP COLUMN reading_number;
+
+DROP TABLE IF EXISTS health_check_item_options;
+
+ALTER TABLE health_check_items DROP COLUMN critical_max;
+ALTER TABLE health_check_items DROP COLUMN critical_min;
+ALTER TABLE health_check_items DROP COLUMN warning_max;
+ALTER TABLE health_check_items DROP COLUMN warning_min;
+ALTER TABLE health_check_items DROP COLUMN unit;
+ALTER TABLE health_check_items DROP COLUMN reading_type;
@@ -0,0 +1,23 @@
+-- Typed readings on health check items. Number readings are rated against the
+-- warning and critical limits, enum readings by the status of their option.
+ALTER TABLE health_check_items ADD COLUMN reading_type VARCHAR(10) NOT NULL DEFAULT 'none'
+    CHECK (reading_type IN ('none', 'number', 'enum', 'text'));
+ALTER TABLE health_check_items ADD COLUMN unit VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE health_check_items ADD COLUMN warning_min REAL;
+ALTER TABLE health_check_items ADD COLUMN warning_max REAL;
+ALTER TABLE health_check_items ADD COLUMN critical_min REAL;
+ALTER TABLE health_check_items ADD COLUMN critical_max REAL;
+
+CREATE TABLE IF NOT EXISTS health_check_item_options (
+    item_id INTEGER NOT NULL REFERENCES health_check_items(id) ON DELETE CASCADE,
+    value VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('ok', 'warning', 'fail')),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, value)
+);
+
+-- The unit is kept with the reading so that changing the item does not
+-- change recorded values
+ALTER TABLE report_health_checks ADD COLUMN reading_number REAL;
+ALTER TABLE report_health_checks ADD COLUMN reading_text TEXT;
+ALTER TABLE report_health_checks ADD COLUMN reading_unit VARCHAR(20);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS sensor_readings;
+DROP TABLE IF EXISTS api_tokens;
+DROP INDEX IF EXISTS idx_health_check_items_sensor_key;
+ALTER TABLE health_check_items DROP COLUMN sensor_key;
@@ -0,0 +1,28 @@
+-- Root cause analyses. Part 3 events refer to an RCA by ID, so one RCA can
+-- cover events of several reports. New RCAs are numbered RCA-YYYY-NNN.
+CREATE TABLE IF NOT EXISTS rcas (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    number VARCHAR(50) NOT NULL UNIQUE,
+    title VARCHAR(200) NOT NULL,
+    timeline TEXT,
+    root_cause TEXT,
+    contributing_factors TEXT,
+    corrective_actions TEXT,
+    owner_id INTEGER REFERENCES users(id),
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INTEGER REFERENCES users(id)
+);
+
+ALTER TABLE report_events_part3 ADD COLUMN rca_id INTEGER REFERENCES rcas(id);
+CREATE INDEX IF NOT EXISTS idx_events_part3_rca ON report_events_part3(rca_id);
+
+-- Every RCA number entered so far becomes an RCA of its own, keeping the number
+INSERT INTO rcas (number, title)
+SELECT DISTINCT rca_number, rca_number FROM report_events_part3 WHERE COALESCE(rca_number, '') <> '';
+UPDATE report_events_part3 SET rca_id = (SELECT r.id FROM rcas r WHERE r.number = report_events_part3.rca_number)
+WHERE COALESCE(rca_number, '') <> '';
+
+ALTER TABLE report_events_part3 DROP COLUMN rca_number;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS rca_escalations;
+
+ALTER TABLE rcas ADD COLUMN corrective_actions TEXT;
+UPDATE rcas SET corrective_actions = (SELECT group_concat(a.description, char(10)) FROM rca_actions a WHERE a.rca_id = rcas.id);
+DROP TABLE IF EXISTS rca_actions;
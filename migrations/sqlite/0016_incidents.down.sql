@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_events_part3_incident;
+ALTER TABLE report_events_part3 DROP COLUMN incident_id;
+DROP TABLE IF EXISTS incidents;
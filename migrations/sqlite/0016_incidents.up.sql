@@ -0,0 +1,16 @@
+-- Incidents group Part 3 events of several reports, such as an outage that
+-- starts on one shift and ends on the next.
+CREATE TABLE IF NOT EXISTS incidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(200) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'mitigated', 'resolved')),
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INTEGER REFERENCES users(id)
+);
+
+ALTER TABLE report_events_part3 ADD COLUMN incident_id INTEGER REFERENCES incidents(id);
+CREATE INDEX IF NOT EXISTS idx_events_part3_incident ON report_events_part3(incident_id);
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_events_part3_severity;
+DROP INDEX IF EXISTS idx_events_part3_category;
+DROP INDEX IF EXISTS idx_events_part3_affected_service;
+DROP INDEX IF EXISTS idx_events_part4_severity;
+DROP INDEX IF EXISTS idx_events_part4_category;
+DROP INDEX IF EXISTS idx_events_part4_affected_service;
+ALTER TABLE report_events_part3 DROP COLUMN severity_id;
+ALTER TABLE report_events_part3 DROP COLUMN category_id;
+ALTER TABLE report_events_part3 DROP COLUMN affected_service_id;
+ALTER TABLE report_events_part4 DROP COLUMN severity_id;
+ALTER TABLE report_events_part4 DROP COLUMN category_id;
+ALTER TABLE report_events_part4 DROP COLUMN affected_service_id;
+DROP TABLE IF EXISTS affected_services;
+DROP TABLE IF EXISTS event_categories;
+DROP TABLE IF EXISTS severities;
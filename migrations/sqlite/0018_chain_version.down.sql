@@ -0,0 +1 @@
+ALTER TABLE report_chain DROP COLUMN canonical_version;
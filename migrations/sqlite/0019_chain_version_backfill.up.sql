@@ -0,0 +1,17 @@
+-- Record the canonical form version of links sealed before versions were:
+-- links sealed before the health checklist replaced the fixed health columns
+-- are version 1, later ones version 2. Links are otherwise append-only.
+DROP TRIGGER IF EXISTS report_chain_no_update;
+
+UPDATE report_chain
+SET canonical_version = CASE
+    WHEN sealed_at < (SELECT applied_at FROM schema_migrations WHERE version = 11) THEN 1
+    ELSE 2
+END
+WHERE canonical_version IS NULL;
+
+CREATE TRIGGER IF NOT EXISTS report_chain_no_update
+BEFORE UPDATE ON report_chain
+BEGIN
+    SELECT RAISE(ABORT, 'report chain links cannot be changed');
+END;
@@ -1,5 +1,7 @@
 package main
 
+import "encoding/json"
+
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
@@ -14,33 +16,161 @@ type ShiftHours struct {
 	EndTime   string `json:"end_time"`
 }
 
+type Site struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
 type EventTitle struct {
 	ID    int    `json:"id"`
 	Title string `json:"title"`
 }
 
+// Severity is a step of the admin-managed severity scale; a higher level is
+// more severe.
+type Severity struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+}
+
+type EventCategory struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// AffectedService is a service or system that events can affect.
+type AffectedService struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// HealthCheckItem is an entry of the admin-managed health checklist. An item
+// without shifts applies to every shift.
+type HealthCheckItem struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	SortOrder     int    `json:"sort_order"`
+	Active        bool   `json:"active"`
+	ShiftHoursIDs []int  `json:"shift_hours_ids"`
+	// ReadingType is none, number, enum or text. Number readings are rated
+	// against the limits, enum readings by the status of their option.
+	ReadingType string              `json:"reading_type"`
+	Unit        string              `json:"unit"`
+	WarningMin  *float64            `json:"warning_min"`
+	WarningMax  *float64            `json:"warning_max"`
+	CriticalMin *float64            `json:"critical_min"`
+	CriticalMax *float64            `json:"critical_max"`
+	Options     []HealthCheckOption `json:"options"`
+	// SensorKey names the sensor whose readings are offered for this item.
+	SensorKey string `json:"sensor_key"`
+}
+
+// HealthCheckOption is one of the values an enum reading can take.
+type HealthCheckOption struct {
+	Value  string `json:"value"`
+	Status string `json:"status"`
+}
+
+// HealthCheckResult is the outcome of one health check item on a report.
+type HealthCheckResult struct {
+	ItemID  int    `json:"item_id"`
+	Name    string `json:"name,omitempty"` // filled in when reading
+	Status  string `json:"status"`
+	Comment string `json:"comment,omitempty"`
+	// ReadingNumber holds number readings, ReadingText enum and text ones.
+	// ReadingUnit is the item's unit at the time the reading was saved.
+	ReadingNumber *float64 `json:"reading_number,omitempty"`
+	ReadingText   string   `json:"reading_text,omitempty"`
+	ReadingUnit   string   `json:"reading_unit,omitempty"`
+}
+
 type DailyReport struct {
-	ID                 int          `json:"id"`
-	ReportDate         string       `json:"report_date"`
-	ShiftHours         *ShiftHours  `json:"shift_hours"`
-	ShiftManagers      []User       `json:"shift_managers"`
-	EventTitles        []EventTitle `json:"event_titles"`
-	HealthPowerSources bool         `json:"health_power_sources"`
-	HealthHumidityTemp bool         `json:"health_humidity_temp"`
-	HealthFireSystem   bool         `json:"health_fire_system"`
-	EventsPart3        []EventPart3 `json:"events_part3"`
-	EventsPart4        []EventPart4 `json:"events_part4"`
-	CreatedBy          User         `json:"created_by"`
-	CreatedAt          string       `json:"created_at"`
+	ID            int                 `json:"id"`
+	ReportDate    string              `json:"report_date"`
+	Site          *Site               `json:"site"`
+	ShiftHours    *ShiftHours         `json:"shift_hours"`
+	ShiftManagers []User              `json:"shift_managers"`
+	EventTitles   []EventTitle        `json:"event_titles"`
+	HealthChecks  []HealthCheckResult `json:"health_checks"`
+	EventsPart3   []EventPart3        `json:"events_part3"`
+	EventsPart4   []EventPart4        `json:"events_part4"`
+	CreatedBy     User                `json:"created_by"`
+	CreatedAt     string              `json:"created_at"`
+	Status        string              `json:"status"`
+	StatusComment string              `json:"status_comment,omitempty"`
+	Version       int                 `json:"version"`
+}
+
+// StatusChange is one step in a report's lifecycle.
+type StatusChange struct {
+	ID         int    `json:"id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Comment    string `json:"comment"`
+	ChangedBy  User   `json:"changed_by"`
+	ChangedAt  string `json:"changed_at"`
+}
+
+// ReportRevision is a snapshot of a report as it was after one save.
+type ReportRevision struct {
+	ID        int          `json:"id"`
+	ReportID  int          `json:"report_id"`
+	Revision  int          `json:"revision"`
+	CreatedBy User         `json:"created_by"`
+	CreatedAt string       `json:"created_at"`
+	Snapshot  *DailyReport `json:"snapshot,omitempty"`
+}
+
+// RevisionDiff lists what changed in a report between two revisions. Events
+// are compared by content, as older revisions were saved when every save
+// re-created them under new IDs.
+type RevisionDiff struct {
+	From          int           `json:"from"`
+	To            int           `json:"to"`
+	Fields        []FieldChange `json:"fields"`
+	ShiftManagers ListChange    `json:"shift_managers"`
+	EventTitles   ListChange    `json:"event_titles"`
+	HealthChecks  ListChange    `json:"health_checks"`
+	EventsPart3   ListChange    `json:"events_part3"`
+	EventsPart4   ListChange    `json:"events_part4"`
 }
 
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type ListChange struct {
+	Added   []interface{} `json:"added"`
+	Removed []interface{} `json:"removed"`
+}
+
+// ReportPage is one page of the report list.
+type ReportPage struct {
+	Reports []DailyReport `json:"reports"`
+	Total   int           `json:"total"`
+	Limit   int           `json:"limit"`
+	Offset  int           `json:"offset"`
+}
+
+// EventPart3 is an event that needs an RCA. RCANumber is the number of the
+// RCA when reading; payloads refer to the RCA by RCAID.
+// Events without an end time are still going on; Open is set for them when
+// reading.
 type EventPart3 struct {
 	ID           int    `json:"id"`
 	EventSummary string `json:"event_summary"`
 	Trigger      string `json:"trigger_info"`
 	StartTime    string `json:"start_time"`
 	EndTime      string `json:"end_time"`
+	Open         bool   `json:"open,omitempty"`
 	RCANumber    string `json:"rca_number"`
+	RCAID        *int   `json:"rca_id,omitempty"`
+	IncidentID   *int   `json:"incident_id,omitempty"`
+	EventClassification
 }
 
 type EventPart4 struct {
@@ -49,4 +179,270 @@ type EventPart4 struct {
 	Trigger      string `json:"trigger_info"`
 	StartTime    string `json:"start_time"`
 	EndTime      string `json:"end_time"`
-}
\ No newline at end of file
+	Open         bool   `json:"open,omitempty"`
+	EventClassification
+}
+
+// EventClassification places an event in the severity, category and affected
+// service catalogs. The names are filled in when reading; payloads refer to
+// the entries by ID.
+type EventClassification struct {
+	SeverityID        *int   `json:"severity_id,omitempty"`
+	Severity          string `json:"severity,omitempty"`
+	CategoryID        *int   `json:"category_id,omitempty"`
+	Category          string `json:"category,omitempty"`
+	AffectedServiceID *int   `json:"affected_service_id,omitempty"`
+	AffectedService   string `json:"affected_service,omitempty"`
+}
+
+// RCA is a root cause analysis. It can cover Part 3 events of several reports.
+// Its corrective actions are managed through their own endpoints.
+type RCA struct {
+	ID                  int         `json:"id"`
+	Number              string      `json:"number"`
+	Title               string      `json:"title"`
+	Timeline            string      `json:"timeline"`
+	RootCause           string      `json:"root_cause"`
+	ContributingFactors string      `json:"contributing_factors"`
+	OwnerID             *int        `json:"owner_id"`
+	Owner               *User       `json:"owner"` // filled in when reading
+	CreatedBy           *User       `json:"created_by"`
+	CreatedAt           string      `json:"created_at"`
+	UpdatedAt           string      `json:"updated_at"`
+	Actions             []RCAAction `json:"actions"`
+	Events              []RCAEvent  `json:"events"`
+}
+
+// RCAAction is a corrective action of an RCA. Overdue is set when reading for
+// actions still open after their due date.
+type RCAAction struct {
+	ID          int     `json:"id"`
+	RCAID       int     `json:"rca_id"`
+	RCANumber   string  `json:"rca_number"`
+	RCATitle    string  `json:"rca_title"`
+	Description string  `json:"description"`
+	AssigneeID  *int    `json:"assignee_id"`
+	Assignee    *User   `json:"assignee"` // filled in when reading
+	DueDate     string  `json:"due_date"` // YYYY-MM-DD, empty for no due date
+	Status      string  `json:"status"`
+	Overdue     bool    `json:"overdue"`
+	CompletedAt *string `json:"completed_at"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+// RCAActionSummary counts the open and overdue actions of one assignee.
+type RCAActionSummary struct {
+	Assignee *User `json:"assignee"` // nil for unassigned actions
+	Open     int   `json:"open"`
+	Overdue  int   `json:"overdue"`
+}
+
+// RCAEscalation is a Part 3 event flagged by the escalation job, either
+// because it still has no RCA or because its RCA has overdue actions.
+type RCAEscalation struct {
+	EventID      int    `json:"event_id"`
+	ReportID     int    `json:"report_id"`
+	ReportDate   string `json:"report_date"`
+	EventSummary string `json:"event_summary"`
+	RCAID        *int   `json:"rca_id"`
+	RCANumber    string `json:"rca_number,omitempty"`
+	Reason       string `json:"reason"`
+	FlaggedAt    string `json:"flagged_at"`
+}
+
+// RCAEvent is a Part 3 event covered by an RCA.
+type RCAEvent struct {
+	EventID      int    `json:"event_id"`
+	ReportID     int    `json:"report_id"`
+	ReportDate   string `json:"report_date"`
+	EventSummary string `json:"event_summary"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+}
+
+// Incident groups Part 3 events of several reports, such as an outage logged
+// on two consecutive shifts. StartTime, EndTime and DurationMinutes are
+// computed from its events when reading.
+type Incident struct {
+	ID              int             `json:"id"`
+	Title           string          `json:"title"`
+	Description     string          `json:"description"`
+	Status          string          `json:"status"`
+	StartTime       string          `json:"start_time"`
+	EndTime         string          `json:"end_time"` // empty while an event has not ended
+	DurationMinutes *int            `json:"duration_minutes"`
+	CreatedBy       *User           `json:"created_by"`
+	CreatedAt       string          `json:"created_at"`
+	UpdatedAt       string          `json:"updated_at"`
+	Events          []IncidentEvent `json:"events"`
+}
+
+// IncidentEvent is an event of an incident with the report and shift it was
+// logged on.
+type IncidentEvent struct {
+	EventID      int    `json:"event_id"`
+	ReportID     int    `json:"report_id"`
+	ReportDate   string `json:"report_date"`
+	ShiftName    string `json:"shift_name"`
+	EventSummary string `json:"event_summary"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+}
+
+// DuplicateGroup lists reports that share a site, date and shift.
+type DuplicateGroup struct {
+	SiteID       int    `json:"site_id"`
+	ReportDate   string `json:"report_date"`
+	ShiftHoursID *int   `json:"shift_hours_id"`
+	ReportIDs    []int  `json:"report_ids"`
+}
+
+// SearchResult is an event matching a full-text search. The snippets are
+// HTML-escaped with matches wrapped in <mark>.
+type SearchResult struct {
+	Part           int         `json:"part"`
+	EventID        int         `json:"event_id"`
+	ReportID       int         `json:"report_id"`
+	ReportDate     string      `json:"report_date"`
+	ShiftHours     *ShiftHours `json:"shift_hours"`
+	EventSummary   string      `json:"event_summary"`
+	Trigger        string      `json:"trigger_info"`
+	StartTime      string      `json:"start_time"`
+	EndTime        string      `json:"end_time"`
+	RCANumber      string      `json:"rca_number,omitempty"`
+	SummarySnippet string      `json:"summary_snippet"`
+	TriggerSnippet string      `json:"trigger_snippet"`
+	Rank           float64     `json:"rank"`
+	EventClassification
+}
+
+// SearchPage is one page of search results.
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
+
+// TrashItem is a soft-deleted report, user or catalog entry.
+type TrashItem struct {
+	Type      string `json:"type"`
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	DeletedAt string `json:"deleted_at"`
+	DeletedBy *User  `json:"deleted_by"`
+}
+
+// AuditEntry is one row of the append-only audit log. Before and After hold
+// the entity as JSON; either is empty when the action has no such state.
+type AuditEntry struct {
+	ID            int             `json:"id"`
+	OccurredAt    string          `json:"occurred_at"`
+	ActorID       *int            `json:"actor_id"`
+	ActorUsername string          `json:"actor_username"`
+	Action        string          `json:"action"`
+	EntityType    string          `json:"entity_type"`
+	EntityID      *int            `json:"entity_id"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	IPAddress     string          `json:"ip_address"`
+	UserAgent     string          `json:"user_agent"`
+}
+
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+}
+
+// ChainLink seals one locked report into the report chain.
+type ChainLink struct {
+	Seq         int    `json:"seq"`
+	ReportID    int    `json:"report_id"`
+	ContentHash string `json:"content_hash"`
+	Version     int    `json:"canonical_version"`
+	PrevHash    string `json:"prev_hash"`
+	Hash        string `json:"hash"`
+	Signature   string `json:"signature"`
+	SealedAt    string `json:"sealed_at"`
+}
+
+// ChainVerification is the result of walking the report chain.
+type ChainVerification struct {
+	Valid     bool           `json:"valid"`
+	Links     int            `json:"links"`
+	PublicKey string         `json:"public_key"`
+	Problems  []ChainProblem `json:"problems"`
+}
+
+// ChainProblem is a link or report that failed verification. Seq is 0 for a
+// locked report that was never sealed.
+type ChainProblem struct {
+	Seq      int    `json:"seq,omitempty"`
+	ReportID int    `json:"report_id"`
+	Problem  string `json:"problem"`
+}
+
+// APIToken authenticates a device against the sensor ingestion API. The token
+// itself is only returned when it is created.
+type APIToken struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	CreatedBy  *User   `json:"created_by"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at"`
+	RevokedAt  *string `json:"revoked_at"`
+}
+
+// SensorReading is a timestamped reading sent for the sensor of a health
+// check item.
+type SensorReading struct {
+	Sensor        string   `json:"sensor"`
+	ItemID        int      `json:"item_id"`
+	RecordedAt    string   `json:"recorded_at"`
+	ReadingNumber *float64 `json:"reading_number,omitempty"`
+	ReadingText   string   `json:"reading_text,omitempty"`
+	ReadingUnit   string   `json:"reading_unit,omitempty"`
+}
+
+// HealthSuggestion is the latest sensor reading of an item within a shift,
+// offered to prefill a new report. Status is empty when the reading does
+// not determine it.
+type HealthSuggestion struct {
+	HealthCheckResult
+	RecordedAt string `json:"recorded_at"`
+}
+
+type Session struct {
+	ID           int    `json:"id"`
+	UserID       int    `json:"user_id"`
+	IPAddress    string `json:"ip_address"`
+	UserAgent    string `json:"user_agent"`
+	CreatedAt    string `json:"created_at"`
+	LastActivity string `json:"last_activity"`
+	ExpiresAt    string `json:"expires_at"`
+}
+
+// ReportInput is the payload accepted when creating or updating a report.
+type ReportInput struct {
+	ReportDate      string              `json:"report_date"`
+	SiteID          *int                `json:"site_id"` // defaults to the main site
+	ShiftHoursID    *int                `json:"shift_hours_id"`
+	ShiftManagerIDs []int               `json:"shift_manager_ids"`
+	EventTitleIDs   []int               `json:"event_title_ids"`
+	HealthChecks    []HealthCheckResult `json:"health_checks"`
+	EventsPart3     []EventPart3        `json:"events_part3"`
+	EventsPart4     []EventPart4        `json:"events_part4"`
+}
+
+// ReportDraft is an unsaved report for the shift after PreviousReportID,
+// filled in with what that shift handed over. Event titles and health check
+// results are left to the new shift, so it has to be completed before it is
+// posted.
+type ReportDraft struct {
+	ReportInput
+	PreviousReportID   int                 `json:"previous_report_id"`
+	FailedHealthChecks []HealthCheckResult `json:"failed_health_checks"`
+}
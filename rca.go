@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Corrective actions of an RCA are tracked until they are done or cancelled.
+// The escalation job flags Part 3 events that still have no RCA some days
+// after their report, and events whose RCA has actions that many days overdue.
+
+const (
+	rcaActionOpen       = "open"
+	rcaActionInProgress = "in_progress"
+	rcaActionDone       = "done"
+	rcaActionCancelled  = "cancelled"
+)
+
+var rcaActionStatuses = map[string]bool{
+	rcaActionOpen: true, rcaActionInProgress: true, rcaActionDone: true, rcaActionCancelled: true,
+}
+
+// Reasons an event is escalated.
+const (
+	escalationMissingRCA     = "missing_rca"
+	escalationOverdueActions = "overdue_actions"
+)
+
+// rcaActionOverdue reports whether an action is still open after its due
+// date. today is formatted as YYYY-MM-DD.
+func rcaActionOverdue(action RCAAction, today string) bool {
+	if action.Status != rcaActionOpen && action.Status != rcaActionInProgress {
+		return false
+	}
+	return action.DueDate != "" && action.DueDate < today
+}
+
+// escalateRCAEvents periodically flags events without an RCA or with overdue
+// actions for more than days days.
+func escalateRCAEvents(rcas RCAStore, interval time.Duration, days int) {
+	for range time.Tick(interval) {
+		cutoff := time.Now().UTC().AddDate(0, 0, -days).Format("2006-01-02")
+		flagged, err := rcas.EscalateRCAEvents(context.Background(), cutoff)
+		if err != nil {
+			fmt.Printf("Error escalating RCA events: %v\n", err)
+			continue
+		}
+		if flagged > 0 {
+			fmt.Printf("Flagged %d event(s) for RCA follow-up\n", flagged)
+		}
+	}
+}
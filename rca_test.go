@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCreateRCANumbering(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+	year := time.Now().UTC().Year()
+	// Numbers of other years and ones typed in before RCAs were numbered do not count
+	for _, number := range []string{fmt.Sprintf("RCA-%d-041", year-1), "RCA-7"} {
+		if _, err := s.db.Exec("INSERT INTO rcas (number, title) VALUES ($1, 'Imported')", number); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	create := func() (int, string) {
+		t.Helper()
+		id, number, err := s.CreateRCA(ctx, RCA{Title: "UPS failure"}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id, number
+	}
+	var got []string
+	ids := make([]int, 3)
+	for i := range ids {
+		var number string
+		ids[i], number = create()
+		got = append(got, number)
+	}
+	// A deleted RCA keeps its number
+	if err := s.DeleteRCA(ctx, ids[2], 1); err != nil {
+		t.Fatal(err)
+	}
+	_, number := create()
+	got = append(got, number)
+
+	for i, number := range got {
+		if want := fmt.Sprintf("RCA-%d-%03d", year, i+1); number != want {
+			t.Errorf("RCA %d got number %s, want %s", i+1, number, want)
+		}
+	}
+	rca, err := s.GetRCA(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rca.Number != got[0] || rca.CreatedBy == nil || rca.CreatedBy.ID != 1 {
+		t.Errorf("got RCA %s created by %+v, want %s by user 1", rca.Number, rca.CreatedBy, got[0])
+	}
+}
+
+func TestValidateReportLinksRCAByNumber(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	ctx := context.Background()
+	rcaID, number, err := s.CreateRCA(ctx, RCA{Title: "UPS failure"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownID := 99
+
+	tests := []struct {
+		name       string
+		event      EventPart3
+		wantID     int
+		wantNumber string
+		wantError  string
+	}{
+		{"by ID", EventPart3{RCAID: &rcaID}, rcaID, number, ""},
+		{"by number", EventPart3{RCANumber: " " + number + " "}, rcaID, number, ""},
+		{"ID wins over number", EventPart3{RCAID: &rcaID, RCANumber: "RCA-1999-001"}, rcaID, number, ""},
+		{"unknown ID", EventPart3{RCAID: &unknownID}, unknownID, "", "events_part3[0].rca_id: unknown RCA 99"},
+		{"unknown number", EventPart3{RCANumber: "RCA-1999-001"}, 0, "RCA-1999-001", `events_part3[0].rca_number: unknown RCA "RCA-1999-001"`},
+		{"without RCA", EventPart3{}, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := testReport("2024-03-01")
+			tt.event.EventSummary, tt.event.StartTime = "UPS alarm", "07:00"
+			input.EventsPart3 = []EventPart3{tt.event}
+			errs, err := validateReport(ctx, &input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotError := ""
+			if len(errs) > 0 {
+				gotError = errs[0].Field + ": " + errs[0].Message
+			}
+			event := input.EventsPart3[0]
+			gotID := 0
+			if event.RCAID != nil {
+				gotID = *event.RCAID
+			}
+			if gotID != tt.wantID || event.RCANumber != tt.wantNumber || gotError != tt.wantError || len(errs) > 1 {
+				t.Errorf("got RCA %d %q and errors %+v, want %d %q and %q", gotID, event.RCANumber, errs, tt.wantID, tt.wantNumber, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// diffRevisions compares two snapshots of the same report.
+func diffRevisions(from, to ReportRevision) RevisionDiff {
+	before, after := from.Snapshot, to.Snapshot
+	diff := RevisionDiff{From: from.Revision, To: to.Revision, Fields: []FieldChange{}}
+
+	fields := []FieldChange{
+		{"report_date", before.ReportDate, after.ReportDate},
+		{"site", before.Site, after.Site},
+		{"shift_hours", before.ShiftHours, after.ShiftHours},
+		{"status", before.Status, after.Status},
+		{"status_comment", before.StatusComment, after.StatusComment},
+	}
+	for _, field := range fields {
+		if !reflect.DeepEqual(field.From, field.To) {
+			diff.Fields = append(diff.Fields, field)
+		}
+	}
+
+	diff.ShiftManagers = diffList(before.ShiftManagers, after.ShiftManagers,
+		func(u User) string { return strconv.Itoa(u.ID) })
+	diff.EventTitles = diffList(before.EventTitles, after.EventTitles,
+		func(t EventTitle) string { return strconv.Itoa(t.ID) })
+	diff.HealthChecks = diffList(before.HealthChecks, after.HealthChecks,
+		func(r HealthCheckResult) string { r.Name = ""; return contentKey(r) })
+	// Open is derived from the end time and missing from older snapshots;
+	// catalog names may have changed between snapshots
+	diff.EventsPart3 = diffList(before.EventsPart3, after.EventsPart3,
+		func(e EventPart3) string {
+			e.ID, e.Open = 0, false
+			e.Severity, e.Category, e.AffectedService = "", "", ""
+			return contentKey(e)
+		})
+	diff.EventsPart4 = diffList(before.EventsPart4, after.EventsPart4,
+		func(e EventPart4) string {
+			e.ID, e.Open = 0, false
+			e.Severity, e.Category, e.AffectedService = "", "", ""
+			return contentKey(e)
+		})
+	return diff
+}
+
+// diffList returns the items only in before (removed) and only in after
+// (added). Items with the same key are the same item; repeats are counted.
+func diffList[T any](before, after []T, key func(T) string) ListChange {
+	change := ListChange{Added: []interface{}{}, Removed: []interface{}{}}
+	remaining := make(map[string]int)
+	for _, item := range before {
+		remaining[key(item)]++
+	}
+	for _, item := range after {
+		k := key(item)
+		if remaining[k] > 0 {
+			remaining[k]--
+			continue
+		}
+		change.Added = append(change.Added, item)
+	}
+	for _, item := range before {
+		k := key(item)
+		if remaining[k] > 0 {
+			remaining[k]--
+			change.Removed = append(change.Removed, item)
+		}
+	}
+	return change
+}
+
+func contentKey(v interface{}) string {
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
+
+// revisionInput turns a snapshot back into the payload that saves it.
+func revisionInput(report DailyReport) ReportInput {
+	input := ReportInput{
+		ReportDate:   report.ReportDate,
+		HealthChecks: report.HealthChecks,
+		EventsPart3:  report.EventsPart3,
+		EventsPart4:  report.EventsPart4,
+	}
+	// Dates may be read back as timestamps
+	if len(input.ReportDate) > 10 {
+		input.ReportDate = input.ReportDate[:10]
+	}
+	if report.Site != nil {
+		input.SiteID = &report.Site.ID
+	}
+	if report.ShiftHours != nil {
+		input.ShiftHoursID = &report.ShiftHours.ID
+	}
+	for _, manager := range report.ShiftManagers {
+		input.ShiftManagerIDs = append(input.ShiftManagerIDs, manager.ID)
+	}
+	for _, title := range report.EventTitles {
+		input.EventTitleIDs = append(input.EventTitleIDs, title.ID)
+	}
+	return input
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// editTestReport saves a second revision of report id: a day later, with
+// another event title, a failed health check and the Part 4 event rewritten.
+func editTestReport(t *testing.T, s *sqlStore, id int) ReportInput {
+	t.Helper()
+	input := testReport("2024-03-02")
+	input.EventTitleIDs = []int{1, 2}
+	input.HealthChecks[1].Status = healthFail
+	input.EventsPart4[0].EventSummary = "Door and camera check"
+	if _, err := s.UpdateReport(context.Background(), id, input, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	return input
+}
+
+func TestDiffRevisions(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+	id := createTestReport(t, s, testReport("2024-03-01"))
+	editTestReport(t, s, id)
+
+	from, err := s.GetRevision(ctx, id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := s.GetRevision(ctx, id, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := diffRevisions(from, to)
+
+	if diff.From != 1 || diff.To != 2 || len(diff.Fields) != 1 || diff.Fields[0].Field != "report_date" {
+		t.Errorf("got revisions %d to %d with changed fields %+v, want 1 to 2 with only report_date", diff.From, diff.To, diff.Fields)
+	}
+	if len(diff.EventTitles.Added) != 1 || diff.EventTitles.Added[0].(EventTitle).ID != 2 || len(diff.EventTitles.Removed) != 0 {
+		t.Errorf("got event titles %+v, want title 2 added", diff.EventTitles)
+	}
+	if len(diff.HealthChecks.Added) != 1 || diff.HealthChecks.Added[0].(HealthCheckResult).Status != healthFail ||
+		len(diff.HealthChecks.Removed) != 1 || diff.HealthChecks.Removed[0].(HealthCheckResult).Status != healthOK {
+		t.Errorf("got health checks %+v, want item 2 changed from ok to fail", diff.HealthChecks)
+	}
+	if len(diff.EventsPart4.Added) != 1 || diff.EventsPart4.Added[0].(EventPart4).EventSummary != "Door and camera check" ||
+		len(diff.EventsPart4.Removed) != 1 || diff.EventsPart4.Removed[0].(EventPart4).EventSummary != "Door check" {
+		t.Errorf("got Part 4 events %+v, want the door check rewritten", diff.EventsPart4)
+	}
+	if len(diff.ShiftManagers.Added)+len(diff.ShiftManagers.Removed)+len(diff.EventsPart3.Added)+len(diff.EventsPart3.Removed) != 0 {
+		t.Errorf("got shift managers %+v and Part 3 events %+v, want no changes", diff.ShiftManagers, diff.EventsPart3)
+	}
+
+	if same := diffRevisions(to, to); len(same.Fields)+len(same.EventsPart4.Added)+len(same.EventTitles.Added) != 0 {
+		t.Errorf("got changes %+v between a revision and itself", same)
+	}
+}
+
+func TestRestoreReportRevisionHandler(t *testing.T) {
+	admin := sessionUser{ID: 1, Role: "admin"}
+	tests := []struct {
+		name     string
+		status   string
+		revision string
+		want     int
+	}{
+		{"draft", statusDraft, "1", http.StatusOK},
+		{"approved", statusApproved, "1", http.StatusOK},
+		{"locked", statusLocked, "1", http.StatusConflict},
+		{"unknown revision", statusDraft, "9", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openChainStore(t)
+			useTestStore(s)
+			ctx := context.Background()
+			id := createTestReport(t, s, testReport("2024-03-01"))
+			editTestReport(t, s, id)
+			setTestStatus(t, s, id, tt.status)
+			before, err := s.GetReport(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			revisions, err := s.ListRevisions(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			restoreReportRevisionHandler(w, testRequest(t, http.MethodPost, nil, admin,
+				map[string]string{"id": strconv.Itoa(id), "revision": tt.revision}))
+			if w.Code != tt.want {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tt.want)
+			}
+
+			after, err := s.GetReport(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			afterRevisions, err := s.ListRevisions(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != http.StatusOK {
+				if after.Version != before.Version || len(afterRevisions) != len(revisions) {
+					t.Errorf("refused restore changed the report: version %d, %d revisions", after.Version, len(afterRevisions))
+				}
+				return
+			}
+			restored := revisionInput(after)
+			if restored.ReportDate != "2024-03-01" || len(restored.EventTitleIDs) != 1 || after.EventsPart4[0].EventSummary != "Door check" ||
+				after.HealthChecks[1].Status != healthOK {
+				t.Errorf("got %+v, want the report as first saved", restored)
+			}
+			if after.Version != before.Version+1 || len(afterRevisions) != len(revisions)+1 {
+				t.Errorf("got version %d with %d revisions, want %d with %d", after.Version, len(afterRevisions),
+					before.Version+1, len(revisions)+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Access policies for routes. Every /api route must be registered with one.
+type policyKind int
+
+const (
+	policyPublic policyKind = iota + 1
+	policyAuthenticated
+	policyAdmin
+	policyOwnerOrAdmin
+	policyAPIToken
+)
+
+// ownerFunc returns the user ID that owns the resource addressed by the request.
+// It returns errNotFound when the resource does not exist.
+type ownerFunc func(r *http.Request) (int, error)
+
+type routePolicy struct {
+	kind  policyKind
+	owner ownerFunc
+}
+
+var (
+	public        = routePolicy{kind: policyPublic}
+	authenticated = routePolicy{kind: policyAuthenticated}
+	adminOnly     = routePolicy{kind: policyAdmin}
+	// apiToken authenticates devices by bearer token instead of a session.
+	apiToken = routePolicy{kind: policyAPIToken}
+)
+
+func ownerOrAdmin(owner ownerFunc) routePolicy {
+	return routePolicy{kind: policyOwnerOrAdmin, owner: owner}
+}
+
+func (p routePolicy) wrap(next http.HandlerFunc) http.HandlerFunc {
+	switch p.kind {
+	case policyPublic:
+		return next
+	case policyAuthenticated:
+		return requireAuth(next)
+	case policyAdmin:
+		return requireAdmin(next)
+	case policyOwnerOrAdmin:
+		return requireAuth(requireOwnerOrAdmin(p.owner, next))
+	case policyAPIToken:
+		return requireAPIToken(next)
+	}
+	panic(fmt.Sprintf("unknown route policy %d", p.kind))
+}
+
+type route struct {
+	Method  string
+	Path    string
+	Policy  routePolicy
+	Handler http.HandlerFunc
+}
+
+// policyRouter is a mux.Router that remembers which routes were registered
+// through a policy so that unprotected API routes can be detected at startup.
+type policyRouter struct {
+	*mux.Router
+	policies map[*mux.Route]routePolicy
+}
+
+func newPolicyRouter() *policyRouter {
+	return &policyRouter{
+		Router:   mux.NewRouter(),
+		policies: make(map[*mux.Route]routePolicy),
+	}
+}
+
+func (pr *policyRouter) register(routes []route) {
+	for _, rt := range routes {
+		if rt.Policy.kind == policyOwnerOrAdmin && rt.Policy.owner == nil {
+			panic("owner-or-admin policy without owner lookup: " + rt.Method + " " + rt.Path)
+		}
+		muxRoute := pr.HandleFunc(rt.Path, rt.Policy.wrap(rt.Handler)).Methods(rt.Method)
+		pr.policies[muxRoute] = rt.Policy
+	}
+}
+
+// verifyPolicies returns an error listing every /api route that was added to
+// the router without going through register.
+func (pr *policyRouter) verifyPolicies() error {
+	var missing []string
+	err := pr.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		if path != "/api" && !strings.HasPrefix(path, "/api/") {
+			return nil
+		}
+		if _, ok := pr.policies[route]; ok {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"*"}
+		}
+		missing = append(missing, strings.Join(methods, ",")+" "+path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("routes without access policy: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// Owner lookups
+func reportOwner(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, errNotFound
+	}
+	return reportStore.ReportOwner(r.Context(), id)
+}
+
+func rcaOwner(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, errNotFound
+	}
+	return rcaStore.RCAOwner(r.Context(), id)
+}
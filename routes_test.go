@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestVerifyPolicies(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	pr := newPolicyRouter()
+	pr.register([]route{{"GET", "/api/reports", authenticated, ok}})
+	pr.HandleFunc("/login", ok)
+	if err := pr.verifyPolicies(); err != nil {
+		t.Fatalf("got %v, want every /api route covered", err)
+	}
+
+	pr.HandleFunc("/api/reports/export", ok).Methods("GET")
+	pr.HandleFunc("/api", ok)
+	want := "routes without access policy: GET /api/reports/export; * /api"
+	if err := pr.verifyPolicies(); err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestRoutePolicies(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	useTestSessions(s)
+	ctx := context.Background()
+
+	operatorID, err := s.CreateUser(ctx, User{Username: "operator", FullName: "Operator", Role: "user"}, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportID := createTestReport(t, s, testReport("2024-03-01")) // owned by the admin
+	token, hash, err := newAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateAPIToken(ctx, "sensor gateway", hash, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	pr := newPolicyRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	pr.register([]route{
+		{"GET", "/api/public", public, ok},
+		{"GET", "/api/authenticated", authenticated, ok},
+		{"GET", "/api/admin", adminOnly, ok},
+		{"GET", "/api/reports/{id}", ownerOrAdmin(reportOwner), ok},
+		{"POST", "/api/readings", apiToken, ok},
+	})
+
+	admin := testSessionCookie(t, sessionUser{ID: 1, Username: "admin", Role: "admin"})
+	owner := testSessionCookie(t, sessionUser{ID: 1, Username: "admin", Role: "user"})
+	operator := testSessionCookie(t, sessionUser{ID: operatorID, Username: "operator", Role: "user"})
+	report := "/api/reports/" + strconv.Itoa(reportID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		cookie *http.Cookie
+		token  string
+		want   int
+	}{
+		{"public", "GET", "/api/public", nil, "", http.StatusOK},
+		{"authenticated without session", "GET", "/api/authenticated", nil, "", http.StatusUnauthorized},
+		{"authenticated", "GET", "/api/authenticated", operator, "", http.StatusOK},
+		{"admin without session", "GET", "/api/admin", nil, "", http.StatusUnauthorized},
+		{"admin as user", "GET", "/api/admin", operator, "", http.StatusForbidden},
+		{"admin", "GET", "/api/admin", admin, "", http.StatusOK},
+		{"report without session", "GET", report, nil, "", http.StatusUnauthorized},
+		{"report of another user", "GET", report, operator, "", http.StatusForbidden},
+		{"own report", "GET", report, owner, "", http.StatusOK},
+		{"report as admin", "GET", report, admin, "", http.StatusOK},
+		{"unknown report", "GET", "/api/reports/999", operator, "", http.StatusNotFound},
+		{"readings without token", "POST", "/api/readings", nil, "", http.StatusUnauthorized},
+		{"readings with a session", "POST", "/api/readings", admin, "", http.StatusUnauthorized},
+		{"readings with an unknown token", "POST", "/api/readings", nil, "drt_unknown", http.StatusUnauthorized},
+		{"readings", "POST", "/api/readings", nil, token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			pr.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("got %d %q, want %d", w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
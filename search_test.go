@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// seedSearchEvents saves two reports whose events mention a UPS in different
+// places and returns the ID of the RCA of the first event.
+func seedSearchEvents(t *testing.T, s *sqlStore) int {
+	t.Helper()
+	ctx := context.Background()
+	rcaID, _, err := s.CreateRCA(ctx, RCA{Title: "UPS battery failure"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := testReport("2024-03-01")
+	first.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm on rack 4", Trigger: "battery low", StartTime: "07:00", RCAID: &rcaID}}
+	first.EventsPart4 = []EventPart4{{EventSummary: "Door check", Trigger: "monitor", StartTime: "08:00", EndTime: "08:10"}}
+	createTestReport(t, s, first)
+
+	second := testReport("2024-03-02")
+	second.EventsPart3 = []EventPart3{{EventSummary: "Chiller fault", Trigger: "UPS alarm cascade", StartTime: "09:00"}}
+	second.EventsPart4 = []EventPart4{{EventSummary: "Generator test", Trigger: "weekly UPS check", StartTime: "10:00"}}
+	createTestReport(t, s, second)
+	return rcaID
+}
+
+func searchSummaries(results []SearchResult) []string {
+	summaries := []string{}
+	for _, result := range results {
+		summaries = append(summaries, result.EventSummary)
+	}
+	return summaries
+}
+
+func TestSearchEvents(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s *sqlStore) {
+		seedSearchEvents(t, s)
+
+		tests := []struct {
+			name  string
+			query SearchQuery
+			want  []string // summaries in rank order; nil checks only the count
+			count int
+		}{
+			// A match in the summary ranks above one in the trigger info
+			{"ranked", SearchQuery{Text: "rack alarm"}, []string{"UPS alarm on rack 4"}, 1},
+			{"summary before trigger", SearchQuery{Text: "alarm"}, []string{"UPS alarm on rack 4", "Chiller fault"}, 2},
+			{"stemmed", SearchQuery{Text: "alarms"}, nil, 2},
+			{"all words", SearchQuery{Text: "ups battery"}, []string{"UPS alarm on rack 4"}, 1},
+			{"no match", SearchQuery{Text: "sprinkler"}, []string{}, 0},
+			{"from date", SearchQuery{Text: "ups", DateFrom: "2024-03-02"}, nil, 2},
+			{"to date", SearchQuery{Text: "ups", DateTo: "2024-03-01"}, []string{"UPS alarm on rack 4"}, 1},
+			{"part 4", SearchQuery{Text: "ups", Part: 4}, []string{"Generator test"}, 1},
+			{"part 3", SearchQuery{Text: "ups", Part: 3}, nil, 2},
+			{"paged", SearchQuery{Text: "ups", Limit: 1, Offset: 1}, nil, 3},
+			// FTS operators in the input are searched for, not interpreted
+			{"prefix operator", SearchQuery{Text: "alarm*"}, nil, 2},
+			{"unbalanced quote", SearchQuery{Text: `"ups alarm`}, nil, 2},
+			{"operator syntax", SearchQuery{Text: "NEAR(ups"}, []string{}, 0},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				results, total, err := s.SearchEvents(context.Background(), tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := searchSummaries(results)
+				if total != tt.count {
+					t.Errorf("got %d matches %q, want %d", total, got, tt.count)
+				}
+				if tt.query.Limit > 0 && len(results) != tt.query.Limit {
+					t.Errorf("got a page of %d, want %d", len(results), tt.query.Limit)
+				}
+				if tt.want != nil && (len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0])) {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestSearchEventsSnippetsAndRCA(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s *sqlStore) {
+		ctx := context.Background()
+		rcaID := seedSearchEvents(t, s)
+		rca, err := s.GetRCA(ctx, rcaID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results, _, err := s.SearchEvents(ctx, SearchQuery{Text: "battery", Part: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("got %q, want the UPS alarm", searchSummaries(results))
+		}
+		result := results[0]
+		if result.TriggerSnippet != "<mark>battery</mark> low" || result.RCANumber != rca.Number || result.ReportDate[:10] != "2024-03-01" {
+			t.Errorf("got trigger snippet %q, RCA %q and date %q; want the match marked, %s and 2024-03-01",
+				result.TriggerSnippet, result.RCANumber, result.ReportDate, rca.Number)
+		}
+		if result.StartTime != "2024-03-01T07:00:00Z" || result.ShiftHours == nil || result.ShiftHours.ID != 1 {
+			t.Errorf("got start %q in shift %+v, want 2024-03-01T07:00:00Z in the morning shift", result.StartTime, result.ShiftHours)
+		}
+
+		// An RCA in the trash is not shown
+		if err := s.DeleteRCA(ctx, rcaID, 1); err != nil {
+			t.Fatal(err)
+		}
+		results, _, err = s.SearchEvents(ctx, SearchQuery{Text: "battery", Part: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].RCANumber != "" {
+			t.Errorf("got %+v, want the event without its deleted RCA", results)
+		}
+	})
+}
+
+func TestHighlightHTML(t *testing.T) {
+	got := highlightHTML("UPS <b>" + highlightStart + "alarm" + highlightStop + "</b> & fault")
+	want := "UPS &lt;b&gt;<mark>alarm</mark>&lt;/b&gt; &amp; fault"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
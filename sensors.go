@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Monitoring devices post readings to the ingestion API with an API token.
+// A reading is stored for the health check item with the matching sensor key,
+// and the latest reading of each item within a shift is offered when a
+// report for that shift is written.
+
+// apiTokenPrefix marks tokens issued by this server so they are easy to spot
+// in configuration files.
+const apiTokenPrefix = "drs_"
+
+// maxSensorReadings is the most readings accepted in one request.
+const maxSensorReadings = 1000
+
+// newAPIToken returns a random token and the hash it is stored under.
+func newAPIToken() (token, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = apiTokenPrefix + hex.EncodeToString(secret)
+	return token, hashAPIToken(token), nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseRecordedAt reads the time a device took a reading as site wall-clock
+// time, like shifts and event times. A time with a UTC offset is converted to
+// the site time zone; one without is taken to be site time already.
+func parseRecordedAt(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return cfg.Site.wallClock(t), true
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", timestampLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// healthSuggestions turns the latest readings into suggested results, rated
+// against the current item definitions.
+func healthSuggestions(readings []SensorReading, items []HealthCheckItem) []HealthSuggestion {
+	byID := make(map[int]HealthCheckItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	suggestions := []HealthSuggestion{}
+	for _, reading := range readings {
+		item, ok := byID[reading.ItemID]
+		if !ok || !item.Active {
+			continue
+		}
+		result := HealthCheckResult{
+			ItemID:        item.ID,
+			Name:          item.Name,
+			ReadingNumber: reading.ReadingNumber,
+			ReadingText:   reading.ReadingText,
+			ReadingUnit:   reading.ReadingUnit,
+		}
+		result.Status, _ = item.rate(result)
+		suggestions = append(suggestions, HealthSuggestion{HealthCheckResult: result, RecordedAt: cfg.Site.timestamp(reading.RecordedAt)})
+	}
+	return suggestions
+}
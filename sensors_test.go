@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSensorReadingsOnSiteClock(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	useTestSiteZone(t, tehran)
+	ctx := context.Background()
+	itemID, err := s.CreateHealthCheckItem(ctx, HealthCheckItem{Name: "Room Temperature", Active: true,
+		ReadingType: readingNumber, Unit: "°C", WarningMax: limit(27), SensorKey: "dc1-room-temp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenID, err := s.CreateAPIToken(ctx, "sensor gateway", "hash", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sent in UTC: 19:45Z is 23:15 in the night shift of 2024-03-11 on the
+	// site clock, 10:00Z is 13:30 the same day.
+	body := `{"readings": [
+		{"sensor": "dc1-room-temp", "recorded_at": "2024-03-11T19:45:00Z", "reading_number": 28.5},
+		{"sensor": "dc1-room-temp", "recorded_at": "2024-03-11T10:00:00Z", "reading_number": 21}
+	]}`
+	r := httptest.NewRequest(http.MethodPost, "/api/sensor-readings", bytes.NewBufferString(body))
+	r = r.WithContext(context.WithValue(r.Context(), apiTokenKey, APIToken{ID: tokenID}))
+	w := httptest.NewRecorder()
+	recordSensorReadingsHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want the readings accepted", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		shift      string
+		recordedAt string
+		reading    float64
+	}{
+		{"3", "2024-03-11T23:15:00+03:30", 28.5}, // Night Shift 22:00–06:00
+		{"2", "", 0},                             // Evening Shift 14:00–22:00 starts after 13:30
+		{"1", "2024-03-11T13:30:00+03:30", 21},   // Morning Shift 06:00–14:00
+	}
+	for _, tt := range tests {
+		t.Run("shift "+tt.shift, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			getLatestSensorReadingsHandler(w, httptest.NewRequest(http.MethodGet,
+				"/api/sensor-readings/latest?report_date=2024-03-11&shift_hours_id="+tt.shift, nil))
+			var suggestions []HealthSuggestion
+			if err := json.NewDecoder(w.Body).Decode(&suggestions); err != nil {
+				t.Fatal(err)
+			}
+			var got *HealthSuggestion
+			for i := range suggestions {
+				if suggestions[i].ItemID == itemID {
+					got = &suggestions[i]
+				}
+			}
+			if tt.recordedAt == "" {
+				if got != nil {
+					t.Errorf("got %+v, want no reading in the shift", got)
+				}
+				return
+			}
+			if got == nil || got.RecordedAt != tt.recordedAt || *got.ReadingNumber != tt.reading {
+				t.Errorf("got %+v, want %v recorded at %s", got, tt.reading, tt.recordedAt)
+			}
+		})
+	}
+}
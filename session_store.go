@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base32"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// dbSessionStore is a sessions.Store that keeps session data in the sessions
+// table. The cookie only carries the signed session token, so a session can be
+// revoked server-side at any time.
+type dbSessionStore struct {
+	db      *sql.DB
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+}
+
+func newDBSessionStore(db *sql.DB, keyPairs ...[]byte) *dbSessionStore {
+	return &dbSessionStore{
+		db:     db,
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400,
+		},
+	}
+}
+
+func (s *dbSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *dbSessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.Codecs...); err != nil {
+		// Unsigned or stale cookie: start over with a fresh session
+		return session, nil
+	}
+
+	var data []byte
+	err = s.db.QueryRow(`
+        SELECT data FROM sessions
+        WHERE token = $1 AND revoked_at IS NULL AND expires_at > $2`, token, time.Now().UTC()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+
+	session.ID = token
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *dbSessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	// A negative MaxAge means logout: revoke the row and expire the cookie
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.revokeToken(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+
+	var userID sql.NullInt64
+	if id, ok := session.Values["user_id"].(int); ok {
+		userID = sql.NullInt64{Int64: int64(id), Valid: true}
+	}
+	expiresAt := time.Now().UTC().Add(time.Duration(session.Options.MaxAge) * time.Second)
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+		_, err := s.db.Exec(`
+            INSERT INTO sessions (token, user_id, data, ip_address, user_agent, created_at, last_activity, expires_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $6, $7)`,
+			session.ID, userID, buf.Bytes(), clientIP(r), r.UserAgent(), time.Now().UTC(), expiresAt)
+		if err != nil {
+			return err
+		}
+	} else {
+		res, err := s.db.Exec(`
+            UPDATE sessions SET user_id = $1, data = $2, last_activity = $3, expires_at = $4
+            WHERE token = $5 AND revoked_at IS NULL`,
+			userID, buf.Bytes(), time.Now().UTC(), expiresAt, session.ID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("session has been revoked")
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// renew discards the current server-side session and gives the session a new
+// token on the next Save. Used on login to prevent session fixation.
+func (s *dbSessionStore) renew(session *sessions.Session) error {
+	if session.ID == "" {
+		return nil
+	}
+	if err := s.revokeToken(session.ID); err != nil {
+		return err
+	}
+	session.ID = ""
+	return nil
+}
+
+func (s *dbSessionStore) revokeToken(token string) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = $1 WHERE token = $2 AND revoked_at IS NULL", time.Now().UTC(), token)
+	return err
+}
+
+func (s *dbSessionStore) revokeSession(id int) (bool, error) {
+	res, err := s.db.Exec("UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *dbSessionStore) revokeUserSessions(userID int) (int64, error) {
+	res, err := s.db.Exec("UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", time.Now().UTC(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *dbSessionStore) activeSessions(userID int) ([]Session, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, ip_address, user_agent, created_at, last_activity, expires_at
+        FROM sessions
+        WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+        ORDER BY last_activity DESC`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessionList := []Session{}
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.UserID, &sess.IPAddress, &sess.UserAgent,
+			&sess.CreatedAt, &sess.LastActivity, &sess.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessionList = append(sessionList, sess)
+	}
+	return sessionList, rows.Err()
+}
+
+// purgeExpired periodically deletes sessions that expired or were revoked more
+// than the given retention ago.
+func (s *dbSessionStore) purgeExpired(interval, retention time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := s.deleteExpired(time.Now().UTC().Add(-retention)); err != nil {
+			fmt.Printf("Error purging sessions: %v\n", err)
+		}
+	}
+}
+
+// deleteExpired deletes sessions that expired or were revoked before cutoff
+// and returns how many it deleted.
+func (s *dbSessionStore) deleteExpired(cutoff time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM sessions WHERE expires_at < $1 OR revoked_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
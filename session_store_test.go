@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sessionRequest is a request carrying cookie.
+func sessionRequest(cookie *http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/reports", nil)
+	r.AddCookie(cookie)
+	return r
+}
+
+func TestDBSessionStoreRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	useTestSessions(s)
+	cookie := testSessionCookie(t, sessionUser{ID: 1, Username: "admin", Role: "admin"})
+
+	session, err := store.New(sessionRequest(cookie), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.IsNew || session.ID == "" || session.Values["user_id"] != 1 || session.Values["role"] != "admin" {
+		t.Fatalf("got session %q new=%v with %v, want the saved admin session", session.ID, session.IsNew, session.Values)
+	}
+	sessions, err := store.activeSessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].IPAddress != "192.0.2.1" {
+		t.Fatalf("got active sessions %+v, want the one from 192.0.2.1", sessions)
+	}
+
+	// Saving again updates the row instead of adding one
+	session.Values["role"] = "user"
+	if err := store.Save(sessionRequest(cookie), httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := store.New(sessionRequest(cookie), "session"); err != nil || again.Values["role"] != "user" {
+		t.Errorf("got %v (%v), want the updated role", again.Values, err)
+	}
+
+	// A cookie signed with another key starts a new session
+	other := newDBSessionStore(s.db, []byte("another-secret-of-at-least-32-bytes"))
+	if forged, err := other.New(sessionRequest(cookie), "session"); err != nil || !forged.IsNew || len(forged.Values) != 0 {
+		t.Errorf("got %v (%v), want a new empty session", forged.Values, err)
+	}
+}
+
+func TestDBSessionStoreRevoke(t *testing.T) {
+	s := openTestStore(t)
+	useTestSessions(s)
+	cookie := testSessionCookie(t, sessionUser{ID: 1, Username: "admin", Role: "admin"})
+	loaded, err := store.New(sessionRequest(cookie), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := store.activeSessions(1)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("got %+v (%v), want one session", sessions, err)
+	}
+
+	if revoked, err := store.revokeSession(sessions[0].ID); err != nil || !revoked {
+		t.Fatalf("got %v (%v), want the session revoked", revoked, err)
+	}
+	if revoked, err := store.revokeSession(sessions[0].ID); err != nil || revoked {
+		t.Errorf("revoking twice got %v (%v), want nothing to revoke", revoked, err)
+	}
+	session, err := store.New(sessionRequest(cookie), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.IsNew || session.Values["user_id"] != nil {
+		t.Errorf("got %v, want a revoked token to start a new session", session.Values)
+	}
+	if err := store.Save(sessionRequest(cookie), httptest.NewRecorder(), loaded); err == nil {
+		t.Error("saving a revoked session succeeded")
+	}
+	w := httptest.NewRecorder()
+	requireAuth(func(w http.ResponseWriter, r *http.Request) {})(w, sessionRequest(cookie))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d for a revoked session, want 401", w.Code)
+	}
+}
+
+func TestDBSessionStoreRenew(t *testing.T) {
+	s := openTestStore(t)
+	useTestSessions(s)
+	cookie := testSessionCookie(t, sessionUser{ID: 1, Username: "admin", Role: "admin"})
+	session, err := store.New(sessionRequest(cookie), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := session.ID
+
+	if err := store.renew(session); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	if err := store.Save(sessionRequest(cookie), w, session); err != nil {
+		t.Fatal(err)
+	}
+	if session.ID == "" || session.ID == oldID {
+		t.Errorf("got token %q after renewing %q, want a new one", session.ID, oldID)
+	}
+	if old, err := store.New(sessionRequest(cookie), "session"); err != nil || !old.IsNew {
+		t.Errorf("got %v (%v), want the old cookie rejected", old.Values, err)
+	}
+	if renewed, err := store.New(sessionRequest(w.Result().Cookies()[0]), "session"); err != nil || renewed.Values["user_id"] != 1 {
+		t.Errorf("got %v (%v), want the new cookie to carry the session", renewed.Values, err)
+	}
+}
+
+func TestDBSessionStoreExpiry(t *testing.T) {
+	s := openTestStore(t)
+	useTestSessions(s)
+	user := sessionUser{ID: 1, Username: "admin", Role: "admin"}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	// Past its max age
+	cookie := testSessionCookie(t, user)
+	if _, err := s.db.Exec("UPDATE sessions SET expires_at = $1", time.Now().UTC().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := store.New(sessionRequest(cookie), "session"); err != nil || !session.IsNew {
+		t.Errorf("got %v (%v), want an expired session rejected", session.Values, err)
+	}
+
+	// Idle for longer than the idle timeout
+	cookie = testSessionCookie(t, user)
+	session, err := store.New(sessionRequest(cookie), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["last_activity"] = time.Now().Add(-cfg.Session.IdleTimeout - time.Second).Unix()
+	if err := store.Save(sessionRequest(cookie), httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	requireAuth(ok)(w, sessionRequest(cookie))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d for an idle session, want 401", w.Code)
+	}
+	if sessions, err := store.activeSessions(1); err != nil || len(sessions) != 0 {
+		t.Errorf("got active sessions %+v (%v), want the idle one revoked", sessions, err)
+	}
+}
+
+func TestDBSessionStoreDeleteExpired(t *testing.T) {
+	s := openTestStore(t)
+	useTestSessions(s)
+	now := time.Now().UTC()
+	cutoff := now.Add(-24 * time.Hour)
+	rows := []struct {
+		token     string
+		expiresAt time.Time
+		revokedAt interface{}
+		kept      bool
+	}{
+		{"active", now.Add(time.Hour), nil, true},
+		{"expired-recently", now.Add(-time.Hour), nil, true},
+		{"revoked-recently", now.Add(time.Hour), now.Add(-time.Hour), true},
+		{"expired-long-ago", now.Add(-48 * time.Hour), nil, false},
+		{"revoked-long-ago", now.Add(time.Hour), now.Add(-48 * time.Hour), false},
+	}
+	for _, row := range rows {
+		if _, err := s.db.ExecContext(context.Background(), "INSERT INTO sessions (token, user_id, data, expires_at, revoked_at) VALUES ($1, 1, $2, $3, $4)",
+			row.token, []byte{}, row.expiresAt, row.revokedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := store.deleteExpired(cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("got %d deleted, want 2", deleted)
+	}
+	for _, row := range rows {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE token = $1", row.token).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if (count == 1) != row.kept {
+			t.Errorf("session %s kept: got %v, want %v", row.token, count == 1, row.kept)
+		}
+	}
+}
@@ -0,0 +1,2935 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Users
+
+func (s *sqlStore) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, username, full_name, role FROM users WHERE deleted_at IS NULL ORDER BY full_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.FullName, &user.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlStore) GetUser(ctx context.Context, id int) (User, error) {
+	var user User
+	err := s.db.QueryRowContext(ctx, "SELECT id, username, full_name, role FROM users WHERE id = $1 AND deleted_at IS NULL", id).
+		Scan(&user.ID, &user.Username, &user.FullName, &user.Role)
+	return user, notFound(err)
+}
+
+func (s *sqlStore) GetUserCredentials(ctx context.Context, username string) (User, string, error) {
+	var user User
+	var hashedPassword string
+	err := s.db.QueryRowContext(ctx, "SELECT id, username, full_name, role, password FROM users WHERE username = $1 AND deleted_at IS NULL",
+		username).Scan(&user.ID, &user.Username, &user.FullName, &user.Role, &hashedPassword)
+	return user, hashedPassword, notFound(err)
+}
+
+func (s *sqlStore) CreateUser(ctx context.Context, user User, passwordHash string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO users (username, password, full_name, role) VALUES ($1, $2, $3, $4) RETURNING id",
+		user.Username, passwordHash, user.FullName, user.Role).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateUser(ctx context.Context, user User, passwordHash string) error {
+	var res sql.Result
+	var err error
+	if passwordHash != "" {
+		res, err = s.db.ExecContext(ctx, "UPDATE users SET username = $1, full_name = $2, role = $3, password = $4 WHERE id = $5 AND deleted_at IS NULL",
+			user.Username, user.FullName, user.Role, passwordHash, user.ID)
+	} else {
+		res, err = s.db.ExecContext(ctx, "UPDATE users SET username = $1, full_name = $2, role = $3 WHERE id = $4 AND deleted_at IS NULL",
+			user.Username, user.FullName, user.Role, user.ID)
+	}
+	return affectedOne(res, err)
+}
+
+func (s *sqlStore) DeleteUser(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "users", id, deletedBy)
+}
+
+// Catalog
+
+func (s *sqlStore) ListSites(ctx context.Context) ([]Site, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name FROM sites WHERE deleted_at IS NULL ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []Site
+	for rows.Next() {
+		var site Site
+		if err := rows.Scan(&site.ID, &site.Name); err != nil {
+			return nil, err
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+func (s *sqlStore) CreateSite(ctx context.Context, site Site) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO sites (name) VALUES ($1) RETURNING id", site.Name).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateSite(ctx context.Context, site Site) error {
+	return affectedOne(s.db.ExecContext(ctx, "UPDATE sites SET name = $1 WHERE id = $2 AND deleted_at IS NULL", site.Name, site.ID))
+}
+
+func (s *sqlStore) DeleteSite(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "sites", id, deletedBy)
+}
+
+func (s *sqlStore) ListShiftHours(ctx context.Context) ([]ShiftHours, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, start_time, end_time FROM shift_hours WHERE deleted_at IS NULL ORDER BY start_time")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []ShiftHours
+	for rows.Next() {
+		var shift ShiftHours
+		if err := rows.Scan(&shift.ID, &shift.Name, &shift.StartTime, &shift.EndTime); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+	return shifts, rows.Err()
+}
+
+func (s *sqlStore) CreateShiftHours(ctx context.Context, shift ShiftHours) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO shift_hours (name, start_time, end_time) VALUES ($1, $2, $3) RETURNING id",
+		shift.Name, shift.StartTime, shift.EndTime).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateShiftHours(ctx context.Context, shift ShiftHours) error {
+	return affectedOne(s.db.ExecContext(ctx, "UPDATE shift_hours SET name = $1, start_time = $2, end_time = $3 WHERE id = $4 AND deleted_at IS NULL",
+		shift.Name, shift.StartTime, shift.EndTime, shift.ID))
+}
+
+func (s *sqlStore) DeleteShiftHours(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "shift_hours", id, deletedBy)
+}
+
+func (s *sqlStore) ListEventTitles(ctx context.Context) ([]EventTitle, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, title FROM event_titles WHERE deleted_at IS NULL ORDER BY title")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var titles []EventTitle
+	for rows.Next() {
+		var title EventTitle
+		if err := rows.Scan(&title.ID, &title.Title); err != nil {
+			return nil, err
+		}
+		titles = append(titles, title)
+	}
+	return titles, rows.Err()
+}
+
+func (s *sqlStore) CreateEventTitle(ctx context.Context, title EventTitle) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO event_titles (title) VALUES ($1) RETURNING id", title.Title).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateEventTitle(ctx context.Context, title EventTitle) error {
+	return affectedOne(s.db.ExecContext(ctx, "UPDATE event_titles SET title = $1 WHERE id = $2 AND deleted_at IS NULL", title.Title, title.ID))
+}
+
+func (s *sqlStore) DeleteEventTitle(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "event_titles", id, deletedBy)
+}
+
+func (s *sqlStore) ListSeverities(ctx context.Context) ([]Severity, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, level FROM severities WHERE deleted_at IS NULL ORDER BY level, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var severities []Severity
+	for rows.Next() {
+		var severity Severity
+		if err := rows.Scan(&severity.ID, &severity.Name, &severity.Level); err != nil {
+			return nil, err
+		}
+		severities = append(severities, severity)
+	}
+	return severities, rows.Err()
+}
+
+func (s *sqlStore) CreateSeverity(ctx context.Context, severity Severity) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO severities (name, level) VALUES ($1, $2) RETURNING id",
+		severity.Name, severity.Level).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateSeverity(ctx context.Context, severity Severity) error {
+	return affectedOne(s.db.ExecContext(ctx, "UPDATE severities SET name = $1, level = $2 WHERE id = $3 AND deleted_at IS NULL",
+		severity.Name, severity.Level, severity.ID))
+}
+
+func (s *sqlStore) DeleteSeverity(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "severities", id, deletedBy)
+}
+
+func (s *sqlStore) ListEventCategories(ctx context.Context) ([]EventCategory, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name FROM event_categories WHERE deleted_at IS NULL ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []EventCategory
+	for rows.Next() {
+		var category EventCategory
+		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (s *sqlStore) CreateEventCategory(ctx context.Context, category EventCategory) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO event_categories (name) VALUES ($1) RETURNING id", category.Name).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateEventCategory(ctx context.Context, category EventCategory) error {
+	return affectedOne(s.db.ExecContext(ctx, "UPDATE event_categories SET name = $1 WHERE id = $2 AND deleted_at IS NULL", category.Name, category.ID))
+}
+
+func (s *sqlStore) DeleteEventCategory(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "event_categories", id, deletedBy)
+}
+
+func (s *sqlStore) ListAffectedServices(ctx context.Context) ([]AffectedService, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name FROM affected_services WHERE deleted_at IS NULL ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []AffectedService
+	for rows.Next() {
+		var service AffectedService
+		if err := rows.Scan(&service.ID, &service.Name); err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+func (s *sqlStore) CreateAffectedService(ctx context.Context, service AffectedService) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO affected_services (name) VALUES ($1) RETURNING id", service.Name).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateAffectedService(ctx context.Context, service AffectedService) error {
+	return affectedOne(s.db.ExecContext(ctx, "UPDATE affected_services SET name = $1 WHERE id = $2 AND deleted_at IS NULL", service.Name, service.ID))
+}
+
+func (s *sqlStore) DeleteAffectedService(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "affected_services", id, deletedBy)
+}
+
+func (s *sqlStore) ListHealthCheckItems(ctx context.Context) ([]HealthCheckItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, name, COALESCE(description, ''), sort_order, active,
+               reading_type, unit, warning_min, warning_max, critical_min, critical_max, COALESCE(sensor_key, '')
+        FROM health_check_items
+        WHERE deleted_at IS NULL
+        ORDER BY sort_order, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []HealthCheckItem
+	byID := make(map[int]int)
+	for rows.Next() {
+		item := HealthCheckItem{ShiftHoursIDs: []int{}, Options: []HealthCheckOption{}}
+		var warningMin, warningMax, criticalMin, criticalMax sql.NullFloat64
+		if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.SortOrder, &item.Active,
+			&item.ReadingType, &item.Unit, &warningMin, &warningMax, &criticalMin, &criticalMax, &item.SensorKey); err != nil {
+			return nil, err
+		}
+		item.WarningMin, item.WarningMax = nullFloat(warningMin), nullFloat(warningMax)
+		item.CriticalMin, item.CriticalMax = nullFloat(criticalMin), nullFloat(criticalMax)
+		byID[item.ID] = len(items)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	shiftRows, err := s.db.QueryContext(ctx, "SELECT item_id, shift_hours_id FROM health_check_item_shifts ORDER BY item_id, shift_hours_id")
+	if err != nil {
+		return nil, err
+	}
+	defer shiftRows.Close()
+
+	for shiftRows.Next() {
+		var itemID, shiftID int
+		if err := shiftRows.Scan(&itemID, &shiftID); err != nil {
+			return nil, err
+		}
+		if i, ok := byID[itemID]; ok {
+			items[i].ShiftHoursIDs = append(items[i].ShiftHoursIDs, shiftID)
+		}
+	}
+	if err := shiftRows.Err(); err != nil {
+		return nil, err
+	}
+	shiftRows.Close()
+
+	optionRows, err := s.db.QueryContext(ctx, "SELECT item_id, value, status FROM health_check_item_options ORDER BY item_id, sort_order")
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+
+	for optionRows.Next() {
+		var itemID int
+		var option HealthCheckOption
+		if err := optionRows.Scan(&itemID, &option.Value, &option.Status); err != nil {
+			return nil, err
+		}
+		if i, ok := byID[itemID]; ok {
+			items[i].Options = append(items[i].Options, option)
+		}
+	}
+	return items, optionRows.Err()
+}
+
+func nullFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+func (s *sqlStore) CreateHealthCheckItem(ctx context.Context, item HealthCheckItem) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO health_check_items (name, description, sort_order, active,
+            reading_type, unit, warning_min, warning_max, critical_min, critical_max, sensor_key)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		item.Name, item.Description, item.SortOrder, item.Active,
+		item.ReadingType, item.Unit, item.WarningMin, item.WarningMax, item.CriticalMin, item.CriticalMax,
+		sql.NullString{String: item.SensorKey, Valid: item.SensorKey != ""}).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := setHealthCheckItemShifts(ctx, tx, id, item.ShiftHoursIDs); err != nil {
+		return 0, err
+	}
+	if err := setHealthCheckItemOptions(ctx, tx, id, item.Options); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (s *sqlStore) UpdateHealthCheckItem(ctx context.Context, item HealthCheckItem) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = affectedOne(tx.ExecContext(ctx, `
+        UPDATE health_check_items SET name = $1, description = $2, sort_order = $3, active = $4,
+            reading_type = $5, unit = $6, warning_min = $7, warning_max = $8, critical_min = $9, critical_max = $10,
+            sensor_key = $11
+        WHERE id = $12 AND deleted_at IS NULL`,
+		item.Name, item.Description, item.SortOrder, item.Active,
+		item.ReadingType, item.Unit, item.WarningMin, item.WarningMax, item.CriticalMin, item.CriticalMax,
+		sql.NullString{String: item.SensorKey, Valid: item.SensorKey != ""}, item.ID))
+	if err != nil {
+		return err
+	}
+	if err := setHealthCheckItemShifts(ctx, tx, item.ID, item.ShiftHoursIDs); err != nil {
+		return err
+	}
+	if err := setHealthCheckItemOptions(ctx, tx, item.ID, item.Options); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setHealthCheckItemShifts(ctx context.Context, tx *sql.Tx, itemID int, shiftIDs []int) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM health_check_item_shifts WHERE item_id = $1", itemID); err != nil {
+		return err
+	}
+	for _, shiftID := range shiftIDs {
+		_, err := tx.ExecContext(ctx, "INSERT INTO health_check_item_shifts (item_id, shift_hours_id) VALUES ($1, $2)", itemID, shiftID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setHealthCheckItemOptions(ctx context.Context, tx *sql.Tx, itemID int, options []HealthCheckOption) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM health_check_item_options WHERE item_id = $1", itemID); err != nil {
+		return err
+	}
+	for i, option := range options {
+		_, err := tx.ExecContext(ctx, "INSERT INTO health_check_item_options (item_id, value, status, sort_order) VALUES ($1, $2, $3, $4)",
+			itemID, option.Value, option.Status, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteHealthCheckItem(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "health_check_items", id, deletedBy)
+}
+
+// API tokens and sensor readings
+
+func (s *sqlStore) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT t.id, t.name, t.created_at, t.last_used_at, t.revoked_at, u.id, u.username, u.full_name, u.role
+        FROM api_tokens t
+        JOIN users u ON t.created_by = u.id
+        ORDER BY t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var token APIToken
+		var lastUsed, revoked sql.NullString
+		user := User{}
+		if err := rows.Scan(&token.ID, &token.Name, &token.CreatedAt, &lastUsed, &revoked,
+			&user.ID, &user.Username, &user.FullName, &user.Role); err != nil {
+			return nil, err
+		}
+		token.CreatedBy = &user
+		if lastUsed.Valid {
+			token.LastUsedAt = &lastUsed.String
+		}
+		if revoked.Valid {
+			token.RevokedAt = &revoked.String
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *sqlStore) CreateAPIToken(ctx context.Context, name, tokenHash string, createdBy int) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO api_tokens (name, token_hash, created_by) VALUES ($1, $2, $3) RETURNING id",
+		name, tokenHash, createdBy).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) RevokeAPIToken(ctx context.Context, id int) error {
+	return affectedOne(s.db.ExecContext(ctx,
+		"UPDATE api_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL", id))
+}
+
+func (s *sqlStore) UseAPIToken(ctx context.Context, tokenHash string) (APIToken, error) {
+	var token APIToken
+	err := s.db.QueryRowContext(ctx, `
+        UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP
+        WHERE token_hash = $1 AND revoked_at IS NULL
+        RETURNING id, name, created_at`, tokenHash).Scan(&token.ID, &token.Name, &token.CreatedAt)
+	return token, notFound(err)
+}
+
+func (s *sqlStore) RecordSensorReadings(ctx context.Context, tokenID int, readings []SensorReading) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, reading := range readings {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO sensor_readings (item_id, recorded_at, reading_number, reading_text, reading_unit, token_id)
+            VALUES ($1, $2, $3, $4, $5, $6)`,
+			reading.ItemID, reading.RecordedAt, reading.ReadingNumber, reading.ReadingText, reading.ReadingUnit, tokenID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) LatestSensorReadings(ctx context.Context, from, to time.Time) ([]SensorReading, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT sr.item_id, COALESCE(i.sensor_key, ''), sr.recorded_at, sr.reading_number,
+               COALESCE(sr.reading_text, ''), COALESCE(sr.reading_unit, '')
+        FROM sensor_readings sr
+        JOIN health_check_items i ON sr.item_id = i.id AND i.deleted_at IS NULL
+        WHERE sr.recorded_at >= $1 AND sr.recorded_at <= $2
+        ORDER BY sr.item_id, sr.recorded_at DESC, sr.id DESC`,
+		from.UTC().Format(timestampLayout), to.UTC().Format(timestampLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []SensorReading
+	for rows.Next() {
+		var reading SensorReading
+		var number sql.NullFloat64
+		if err := rows.Scan(&reading.ItemID, &reading.Sensor, &reading.RecordedAt, &number,
+			&reading.ReadingText, &reading.ReadingUnit); err != nil {
+			return nil, err
+		}
+		if len(readings) > 0 && readings[len(readings)-1].ItemID == reading.ItemID {
+			continue
+		}
+		reading.ReadingNumber = nullFloat(number)
+		readings = append(readings, reading)
+	}
+	return readings, rows.Err()
+}
+
+// Root cause analyses
+
+const rcaSelect = `
+        SELECT r.id, r.number, r.title, COALESCE(r.timeline, ''), COALESCE(r.root_cause, ''),
+               COALESCE(r.contributing_factors, ''), r.created_at, r.updated_at,
+               o.id, o.username, o.full_name, o.role,
+               c.id, c.username, c.full_name, c.role
+        FROM rcas r
+        LEFT JOIN users o ON r.owner_id = o.id
+        LEFT JOIN users c ON r.created_by = c.id
+        WHERE r.deleted_at IS NULL`
+
+func scanRCA(row rowScanner) (RCA, error) {
+	rca := RCA{Actions: []RCAAction{}, Events: []RCAEvent{}}
+	var ownerID, creatorID sql.NullInt64
+	var ownerUsername, ownerName, ownerRole, creatorUsername, creatorName, creatorRole sql.NullString
+	err := row.Scan(&rca.ID, &rca.Number, &rca.Title, &rca.Timeline, &rca.RootCause,
+		&rca.ContributingFactors, &rca.CreatedAt, &rca.UpdatedAt,
+		&ownerID, &ownerUsername, &ownerName, &ownerRole,
+		&creatorID, &creatorUsername, &creatorName, &creatorRole)
+	if err != nil {
+		return rca, err
+	}
+	if rca.Owner = nullUser(ownerID, ownerUsername, ownerName, ownerRole); rca.Owner != nil {
+		rca.OwnerID = &rca.Owner.ID
+	}
+	rca.CreatedBy = nullUser(creatorID, creatorUsername, creatorName, creatorRole)
+	return rca, nil
+}
+
+// nullUser returns the user of a LEFT JOIN, or nil if there was none.
+func nullUser(id sql.NullInt64, username, fullName, role sql.NullString) *User {
+	if !id.Valid {
+		return nil
+	}
+	return &User{ID: int(id.Int64), Username: username.String, FullName: fullName.String, Role: role.String}
+}
+
+func (s *sqlStore) ListRCAs(ctx context.Context) ([]RCA, error) {
+	rows, err := s.db.QueryContext(ctx, rcaSelect+" ORDER BY r.id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rcas []RCA
+	for rows.Next() {
+		rca, err := scanRCA(rows)
+		if err != nil {
+			return nil, err
+		}
+		rcas = append(rcas, rca)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return rcas, s.loadRCAChildren(ctx, rcas)
+}
+
+func (s *sqlStore) GetRCA(ctx context.Context, id int) (RCA, error) {
+	rca, err := scanRCA(s.db.QueryRowContext(ctx, rcaSelect+" AND r.id = $1", id))
+	if err != nil {
+		return rca, notFound(err)
+	}
+	rcas := []RCA{rca}
+	if err := s.loadRCAChildren(ctx, rcas); err != nil {
+		return rca, err
+	}
+	return rcas[0], nil
+}
+
+// loadRCAChildren fills in the actions of the given RCAs and their events that
+// belong to reports not in the trash.
+func (s *sqlStore) loadRCAChildren(ctx context.Context, rcas []RCA) error {
+	if len(rcas) == 0 {
+		return nil
+	}
+	ids := make([]int, len(rcas))
+	byID := make(map[int]*RCA, len(rcas))
+	for i := range rcas {
+		ids[i] = rcas[i].ID
+		byID[rcas[i].ID] = &rcas[i]
+	}
+
+	cond, arg := s.idIn("e.rca_id", 1, ids)
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT e.rca_id, e.id, dr.id, dr.report_date, COALESCE(e.event_summary, ''), e.start_time, e.end_time
+        FROM report_events_part3 e
+        JOIN daily_reports dr ON dr.id = e.report_id AND dr.deleted_at IS NULL
+        WHERE `+cond+`
+        ORDER BY dr.report_date, e.start_time, e.id`, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rcaID int
+		var event RCAEvent
+		var startTime, endTime sql.NullString
+		if err := rows.Scan(&rcaID, &event.EventID, &event.ReportID, &event.ReportDate, &event.EventSummary,
+			&startTime, &endTime); err != nil {
+			return err
+		}
+		event.StartTime, event.EndTime = cfg.Site.timestamp(startTime.String), cfg.Site.timestamp(endTime.String)
+		rca := byID[rcaID]
+		rca.Events = append(rca.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	cond, arg = s.idIn("a.rca_id", 1, ids)
+	actions, err := s.queryRCAActions(ctx, rcaActionSelect+" WHERE "+cond+" ORDER BY a.id", arg)
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		rca := byID[action.RCAID]
+		rca.Actions = append(rca.Actions, action)
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateRCA(ctx context.Context, rca RCA, createdBy int) (int, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	if s.driver == driverPostgres {
+		// Numbers are consecutive within a year, so number one RCA at a time
+		if _, err := tx.ExecContext(ctx, "LOCK TABLE rcas IN EXCLUSIVE MODE"); err != nil {
+			return 0, "", err
+		}
+	}
+	number, err := nextRCANumber(ctx, tx, time.Now().UTC().Year())
+	if err != nil {
+		return 0, "", err
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO rcas (number, title, timeline, root_cause, contributing_factors, owner_id, created_by)
+        VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		number, rca.Title, rca.Timeline, rca.RootCause, rca.ContributingFactors, rca.OwnerID, createdBy).Scan(&id)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, number, tx.Commit()
+}
+
+// nextRCANumber returns the number after the highest one of the year. Deleted
+// RCAs count too, so a number is never given out twice.
+func nextRCANumber(ctx context.Context, tx *sql.Tx, year int) (string, error) {
+	prefix := fmt.Sprintf("RCA-%d-", year)
+	rows, err := tx.QueryContext(ctx, "SELECT number FROM rcas WHERE number LIKE $1", prefix+"%")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	last := 0
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(number, prefix)); err == nil && n > last {
+			last = n
+		}
+	}
+	return fmt.Sprintf("%s%03d", prefix, last+1), rows.Err()
+}
+
+func (s *sqlStore) UpdateRCA(ctx context.Context, rca RCA) error {
+	return affectedOne(s.db.ExecContext(ctx, `
+        UPDATE rcas SET title = $1, timeline = $2, root_cause = $3, contributing_factors = $4,
+            owner_id = $5, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $6 AND deleted_at IS NULL`,
+		rca.Title, rca.Timeline, rca.RootCause, rca.ContributingFactors, rca.OwnerID, rca.ID))
+}
+
+func (s *sqlStore) DeleteRCA(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "rcas", id, deletedBy)
+}
+
+func (s *sqlStore) RCAOwner(ctx context.Context, id int) (int, error) {
+	var owner int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(owner_id, created_by, 0) FROM rcas WHERE id = $1 AND deleted_at IS NULL", id).Scan(&owner)
+	return owner, notFound(err)
+}
+
+const rcaActionSelect = `
+        SELECT a.id, a.rca_id, r.number, r.title, a.description, a.due_date, a.status, a.completed_at,
+               a.created_at, a.updated_at, u.id, u.username, u.full_name, u.role
+        FROM rca_actions a
+        JOIN rcas r ON r.id = a.rca_id
+        LEFT JOIN users u ON a.assignee_id = u.id`
+
+func (s *sqlStore) queryRCAActions(ctx context.Context, query string, args ...interface{}) ([]RCAAction, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	today := time.Now().UTC().Format("2006-01-02")
+	actions := []RCAAction{}
+	for rows.Next() {
+		var action RCAAction
+		var dueDate, completedAt, username, fullName, role sql.NullString
+		var assigneeID sql.NullInt64
+		if err := rows.Scan(&action.ID, &action.RCAID, &action.RCANumber, &action.RCATitle, &action.Description,
+			&dueDate, &action.Status, &completedAt, &action.CreatedAt, &action.UpdatedAt,
+			&assigneeID, &username, &fullName, &role); err != nil {
+			return nil, err
+		}
+		if len(dueDate.String) >= 10 {
+			action.DueDate = dueDate.String[:10]
+		}
+		if completedAt.Valid {
+			action.CompletedAt = &completedAt.String
+		}
+		if action.Assignee = nullUser(assigneeID, username, fullName, role); action.Assignee != nil {
+			action.AssigneeID = &action.Assignee.ID
+		}
+		action.Overdue = rcaActionOverdue(action, today)
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+func (s *sqlStore) ListRCAActions(ctx context.Context, filter RCAActionFilter) ([]RCAAction, error) {
+	query := rcaActionSelect + " WHERE r.deleted_at IS NULL AND a.status IN ('open', 'in_progress')"
+	var args []interface{}
+	if filter.AssigneeID != 0 {
+		args = append(args, filter.AssigneeID)
+		query += fmt.Sprintf(" AND a.assignee_id = $%d", len(args))
+	}
+	if filter.DueBefore != "" {
+		args = append(args, filter.DueBefore)
+		query += fmt.Sprintf(" AND a.due_date < $%d", len(args))
+	}
+	return s.queryRCAActions(ctx, query+" ORDER BY a.due_date IS NULL, a.due_date, a.id", args...)
+}
+
+func (s *sqlStore) GetRCAAction(ctx context.Context, id int) (RCAAction, error) {
+	actions, err := s.queryRCAActions(ctx, rcaActionSelect+" WHERE a.id = $1 AND r.deleted_at IS NULL", id)
+	if err != nil {
+		return RCAAction{}, err
+	}
+	if len(actions) == 0 {
+		return RCAAction{}, errNotFound
+	}
+	return actions[0], nil
+}
+
+func (s *sqlStore) CreateRCAAction(ctx context.Context, action RCAAction) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO rca_actions (rca_id, description, assignee_id, due_date, status, completed_at)
+        VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		action.RCAID, action.Description, action.AssigneeID,
+		sql.NullString{String: action.DueDate, Valid: action.DueDate != ""},
+		action.Status, rcaActionCompletedAt(action.Status)).Scan(&id)
+	return id, err
+}
+
+// UpdateRCAAction changes an action's contents. Its completion time is kept
+// while it stays done.
+func (s *sqlStore) UpdateRCAAction(ctx context.Context, action RCAAction) error {
+	return affectedOne(s.db.ExecContext(ctx, `
+        UPDATE rca_actions SET description = $1, assignee_id = $2, due_date = $3,
+            completed_at = CASE WHEN status = $4 AND $4 = 'done' THEN completed_at ELSE $5 END,
+            status = $4, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $6`,
+		action.Description, action.AssigneeID, sql.NullString{String: action.DueDate, Valid: action.DueDate != ""},
+		action.Status, rcaActionCompletedAt(action.Status), action.ID))
+}
+
+func (s *sqlStore) SetRCAActionStatus(ctx context.Context, id int, status string) error {
+	return affectedOne(s.db.ExecContext(ctx, `
+        UPDATE rca_actions SET completed_at = CASE WHEN status = $1 AND $1 = 'done' THEN completed_at ELSE $2 END,
+            status = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $3`,
+		status, rcaActionCompletedAt(status), id))
+}
+
+func (s *sqlStore) DeleteRCAAction(ctx context.Context, id int) error {
+	return affectedOne(s.db.ExecContext(ctx, "DELETE FROM rca_actions WHERE id = $1", id))
+}
+
+// rcaActionCompletedAt is the completion time to store for an action moving
+// to status: now for done actions, none otherwise.
+func rcaActionCompletedAt(status string) interface{} {
+	if status == rcaActionDone {
+		return time.Now().UTC().Format(timestampLayout)
+	}
+	return nil
+}
+
+// rcaEscalation identifies a flag of the escalation job.
+type rcaEscalation struct {
+	eventID int
+	reason  string
+}
+
+func (s *sqlStore) EscalateRCAEvents(ctx context.Context, cutoff string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT e.id, 'missing_rca'
+        FROM report_events_part3 e
+        JOIN daily_reports dr ON dr.id = e.report_id AND dr.deleted_at IS NULL
+        LEFT JOIN rcas rc ON rc.id = e.rca_id AND rc.deleted_at IS NULL
+        WHERE rc.id IS NULL AND dr.report_date < $1
+        UNION
+        SELECT e.id, 'overdue_actions'
+        FROM report_events_part3 e
+        JOIN daily_reports dr ON dr.id = e.report_id AND dr.deleted_at IS NULL
+        JOIN rcas rc ON rc.id = e.rca_id AND rc.deleted_at IS NULL
+        WHERE EXISTS (SELECT 1 FROM rca_actions a WHERE a.rca_id = rc.id
+                      AND a.status IN ('open', 'in_progress') AND a.due_date < $1)`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	due, err := scanRCAEscalations(rows)
+	if err != nil {
+		return 0, err
+	}
+	rows, err = tx.QueryContext(ctx, "SELECT event_id, reason FROM rca_escalations")
+	if err != nil {
+		return 0, err
+	}
+	flagged, err := scanRCAEscalations(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	for escalation := range flagged {
+		if due[escalation] {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM rca_escalations WHERE event_id = $1 AND reason = $2",
+			escalation.eventID, escalation.reason)
+		if err != nil {
+			return 0, err
+		}
+	}
+	added := 0
+	for escalation := range due {
+		if flagged[escalation] {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO rca_escalations (event_id, reason) VALUES ($1, $2)",
+			escalation.eventID, escalation.reason)
+		if err != nil {
+			return 0, err
+		}
+		added++
+	}
+	return added, tx.Commit()
+}
+
+func scanRCAEscalations(rows *sql.Rows) (map[rcaEscalation]bool, error) {
+	defer rows.Close()
+	escalations := map[rcaEscalation]bool{}
+	for rows.Next() {
+		var escalation rcaEscalation
+		if err := rows.Scan(&escalation.eventID, &escalation.reason); err != nil {
+			return nil, err
+		}
+		escalations[escalation] = true
+	}
+	return escalations, rows.Err()
+}
+
+func (s *sqlStore) ListRCAEscalations(ctx context.Context) ([]RCAEscalation, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT x.event_id, dr.id, dr.report_date, COALESCE(e.event_summary, ''), rc.id, COALESCE(rc.number, ''),
+               x.reason, x.flagged_at
+        FROM rca_escalations x
+        JOIN report_events_part3 e ON e.id = x.event_id
+        JOIN daily_reports dr ON dr.id = e.report_id AND dr.deleted_at IS NULL
+        LEFT JOIN rcas rc ON rc.id = e.rca_id AND rc.deleted_at IS NULL
+        ORDER BY x.flagged_at, dr.report_date, x.event_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	escalations := []RCAEscalation{}
+	for rows.Next() {
+		var escalation RCAEscalation
+		var rcaID sql.NullInt64
+		if err := rows.Scan(&escalation.EventID, &escalation.ReportID, &escalation.ReportDate,
+			&escalation.EventSummary, &rcaID, &escalation.RCANumber, &escalation.Reason,
+			&escalation.FlaggedAt); err != nil {
+			return nil, err
+		}
+		if rcaID.Valid {
+			id := int(rcaID.Int64)
+			escalation.RCAID = &id
+		}
+		escalations = append(escalations, escalation)
+	}
+	return escalations, rows.Err()
+}
+
+// Incidents
+
+const incidentSelect = `
+        SELECT i.id, i.title, COALESCE(i.description, ''), i.status, i.created_at, i.updated_at,
+               u.id, u.username, u.full_name, u.role
+        FROM incidents i
+        LEFT JOIN users u ON i.created_by = u.id
+        WHERE i.deleted_at IS NULL`
+
+func scanIncident(row rowScanner) (Incident, error) {
+	incident := Incident{Events: []IncidentEvent{}}
+	var creatorID sql.NullInt64
+	var username, fullName, role sql.NullString
+	err := row.Scan(&incident.ID, &incident.Title, &incident.Description, &incident.Status,
+		&incident.CreatedAt, &incident.UpdatedAt, &creatorID, &username, &fullName, &role)
+	incident.CreatedBy = nullUser(creatorID, username, fullName, role)
+	return incident, err
+}
+
+func (s *sqlStore) ListIncidents(ctx context.Context, status string) ([]Incident, error) {
+	query, args := incidentSelect, []interface{}{}
+	if status != "" {
+		query += " AND i.status = $1"
+		args = append(args, status)
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY i.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, incident)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return incidents, s.loadIncidentEvents(ctx, incidents)
+}
+
+func (s *sqlStore) GetIncident(ctx context.Context, id int) (Incident, error) {
+	incident, err := scanIncident(s.db.QueryRowContext(ctx, incidentSelect+" AND i.id = $1", id))
+	if err != nil {
+		return incident, notFound(err)
+	}
+	incidents := []Incident{incident}
+	if err := s.loadIncidentEvents(ctx, incidents); err != nil {
+		return incident, err
+	}
+	return incidents[0], nil
+}
+
+// loadIncidentEvents fills in the events of the given incidents that belong
+// to reports not in the trash, in the order they started, and computes each
+// incident's span.
+func (s *sqlStore) loadIncidentEvents(ctx context.Context, incidents []Incident) error {
+	if len(incidents) == 0 {
+		return nil
+	}
+	ids := make([]int, len(incidents))
+	byID := make(map[int]*Incident, len(incidents))
+	for i := range incidents {
+		ids[i] = incidents[i].ID
+		byID[incidents[i].ID] = &incidents[i]
+	}
+
+	cond, arg := s.idIn("e.incident_id", 1, ids)
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT e.incident_id, e.id, dr.id, dr.report_date, COALESCE(sh.name, ''), COALESCE(e.event_summary, ''),
+               e.start_time, e.end_time
+        FROM report_events_part3 e
+        JOIN daily_reports dr ON dr.id = e.report_id AND dr.deleted_at IS NULL
+        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id
+        WHERE `+cond+`
+        ORDER BY e.start_time IS NULL, e.start_time, dr.report_date, e.id`, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var incidentID int
+		var event IncidentEvent
+		var startTime, endTime sql.NullString
+		if err := rows.Scan(&incidentID, &event.EventID, &event.ReportID, &event.ReportDate, &event.ShiftName,
+			&event.EventSummary, &startTime, &endTime); err != nil {
+			return err
+		}
+		event.StartTime, event.EndTime = cfg.Site.timestamp(startTime.String), cfg.Site.timestamp(endTime.String)
+		incident := byID[incidentID]
+		incident.Events = append(incident.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range incidents {
+		incidents[i].computeSpan()
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateIncident(ctx context.Context, incident Incident, createdBy int) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO incidents (title, description, status, created_by)
+        VALUES ($1, $2, $3, $4) RETURNING id`,
+		incident.Title, incident.Description, incident.Status, createdBy).Scan(&id)
+	return id, err
+}
+
+func (s *sqlStore) UpdateIncident(ctx context.Context, incident Incident) error {
+	return affectedOne(s.db.ExecContext(ctx, `
+        UPDATE incidents SET title = $1, description = $2, status = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $4 AND deleted_at IS NULL`,
+		incident.Title, incident.Description, incident.Status, incident.ID))
+}
+
+func (s *sqlStore) DeleteIncident(ctx context.Context, id, deletedBy int) error {
+	return s.softDelete(ctx, "incidents", id, deletedBy)
+}
+
+// AttachIncidentEvent moves the event from an incident in the trash too.
+// Events of reports in the trash cannot be attached.
+func (s *sqlStore) AttachIncidentEvent(ctx context.Context, incidentID, eventID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+        SELECT i.id
+        FROM report_events_part3 e
+        JOIN daily_reports dr ON dr.id = e.report_id AND dr.deleted_at IS NULL
+        LEFT JOIN incidents i ON i.id = e.incident_id AND i.deleted_at IS NULL
+        WHERE e.id = $1`, eventID).Scan(&current)
+	if err != nil {
+		return notFound(err)
+	}
+	if current.Valid && int(current.Int64) != incidentID {
+		return &eventAttachedError{IncidentID: int(current.Int64)}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE report_events_part3 SET incident_id = $1 WHERE id = $2", incidentID, eventID); err != nil {
+		return err
+	}
+	err = affectedOne(tx.ExecContext(ctx,
+		"UPDATE incidents SET updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL", incidentID))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) DetachIncidentEvent(ctx context.Context, incidentID, eventID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = affectedOne(tx.ExecContext(ctx,
+		"UPDATE report_events_part3 SET incident_id = NULL WHERE id = $1 AND incident_id = $2", eventID, incidentID))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE incidents SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", incidentID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Reports
+
+const reportSelect = `
+        SELECT dr.id, dr.report_date, dr.created_at, dr.status, COALESCE(dr.status_comment, ''), dr.version,
+               u.id, u.username, u.full_name, u.role,
+               sh.id, sh.name, sh.start_time, sh.end_time,
+               st.id, st.name
+        FROM daily_reports dr
+        JOIN users u ON dr.created_by = u.id
+        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id
+        LEFT JOIN sites st ON dr.site_id = st.id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanReport(row rowScanner) (DailyReport, error) {
+	var report DailyReport
+	var shiftID sql.NullInt64
+	var shiftName, shiftStart, shiftEnd sql.NullString
+	var siteID sql.NullInt64
+	var siteName sql.NullString
+
+	err := row.Scan(&report.ID, &report.ReportDate, &report.CreatedAt, &report.Status, &report.StatusComment, &report.Version,
+		&report.CreatedBy.ID, &report.CreatedBy.Username, &report.CreatedBy.FullName, &report.CreatedBy.Role,
+		&shiftID, &shiftName, &shiftStart, &shiftEnd,
+		&siteID, &siteName)
+	if err != nil {
+		return report, err
+	}
+
+	if siteID.Valid {
+		report.Site = &Site{ID: int(siteID.Int64), Name: siteName.String}
+	}
+
+	if shiftID.Valid {
+		report.ShiftHours = &ShiftHours{
+			ID:        int(shiftID.Int64),
+			Name:      shiftName.String,
+			StartTime: shiftStart.String,
+			EndTime:   shiftEnd.String,
+		}
+	}
+	return report, nil
+}
+
+func (s *sqlStore) ListReports(ctx context.Context, filter ReportFilter) ([]DailyReport, int, error) {
+	where, args := reportConditions(filter)
+
+	var total int
+	err := s.db.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM daily_reports dr
+        JOIN users u ON dr.created_by = u.id
+        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id`+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := reportSelect + where + " ORDER BY " + reportOrder(filter.Sort)
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.Limit, filter.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var reports []DailyReport
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	if err := s.loadReportChildren(ctx, s.db, reports); err != nil {
+		return nil, 0, err
+	}
+
+	// The list view only shows the time of day
+	for i := range reports {
+		for j := range reports[i].EventsPart3 {
+			event := &reports[i].EventsPart3[j]
+			event.StartTime, event.EndTime = timeOfDay(event.StartTime), timeOfDay(event.EndTime)
+		}
+		for j := range reports[i].EventsPart4 {
+			event := &reports[i].EventsPart4[j]
+			event.StartTime, event.EndTime = timeOfDay(event.StartTime), timeOfDay(event.EndTime)
+		}
+	}
+	return reports, total, nil
+}
+
+// reportConditions builds the WHERE clause for a report filter.
+func reportConditions(filter ReportFilter) (string, []interface{}) {
+	conds := []string{"dr.deleted_at IS NULL"}
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Date != "" {
+		add("dr.report_date = $%d", filter.Date)
+	}
+	if filter.DateFrom != "" {
+		add("dr.report_date >= $%d", filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		add("dr.report_date <= $%d", filter.DateTo)
+	}
+	if filter.SiteID != 0 {
+		add("dr.site_id = $%d", filter.SiteID)
+	}
+	if filter.ShiftHoursID != 0 {
+		add("dr.shift_hours_id = $%d", filter.ShiftHoursID)
+	}
+	if filter.Status != "" {
+		add("dr.status = $%d", filter.Status)
+	}
+	if filter.CreatedBy != 0 {
+		add("dr.created_by = $%d", filter.CreatedBy)
+	}
+	if filter.ShiftManagerID != 0 {
+		add("EXISTS (SELECT 1 FROM report_shift_managers rsm WHERE rsm.report_id = dr.id AND rsm.user_id = $%d)", filter.ShiftManagerID)
+	}
+	if filter.EventTitleID != 0 {
+		add("EXISTS (SELECT 1 FROM report_event_titles ret WHERE ret.report_id = dr.id AND ret.event_title_id = $%d)", filter.EventTitleID)
+	}
+	if filter.HealthFailed != nil {
+		failed := "EXISTS (SELECT 1 FROM report_health_checks hc WHERE hc.report_id = dr.id AND hc.status = 'fail')"
+		if !*filter.HealthFailed {
+			failed = "NOT " + failed
+		}
+		conds = append(conds, failed)
+	}
+	if filter.HasRCAEvents != nil {
+		exists := "EXISTS (SELECT 1 FROM report_events_part3 p3 WHERE p3.report_id = dr.id)"
+		if !*filter.HasRCAEvents {
+			exists = "NOT " + exists
+		}
+		conds = append(conds, exists)
+	}
+	if filter.HasOpenEvents != nil {
+		exists := `(EXISTS (SELECT 1 FROM report_events_part3 p3 WHERE p3.report_id = dr.id AND p3.end_time IS NULL)
+            OR EXISTS (SELECT 1 FROM report_events_part4 p4 WHERE p4.report_id = dr.id AND p4.end_time IS NULL))`
+		if !*filter.HasOpenEvents {
+			exists = "NOT " + exists
+		}
+		conds = append(conds, exists)
+	}
+	eventWith := func(column string, id int) {
+		if id != 0 {
+			add(`(EXISTS (SELECT 1 FROM report_events_part3 p3 WHERE p3.report_id = dr.id AND p3.`+column+` = $%[1]d)
+            OR EXISTS (SELECT 1 FROM report_events_part4 p4 WHERE p4.report_id = dr.id AND p4.`+column+` = $%[1]d))`, id)
+		}
+	}
+	eventWith("severity_id", filter.SeverityID)
+	eventWith("category_id", filter.CategoryID)
+	eventWith("affected_service_id", filter.AffectedServiceID)
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// reportOrder turns a sort key such as "-report_date" into an ORDER BY list.
+// Unknown keys fall back to newest first; dr.id keeps pages stable.
+func reportOrder(sort string) string {
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+		sort = sort[1:]
+	}
+	column, ok := reportSortColumns[sort]
+	if !ok {
+		return "dr.report_date DESC, dr.created_at DESC, dr.id DESC"
+	}
+	return column + " " + direction + ", dr.id " + direction
+}
+
+func (s *sqlStore) GetReport(ctx context.Context, id int) (DailyReport, error) {
+	return s.getReport(ctx, s.db, id)
+}
+
+// getReport loads a report through q, so a transaction can read what it wrote.
+func (s *sqlStore) getReport(ctx context.Context, q queryer, id int) (DailyReport, error) {
+	report, err := scanReport(q.QueryRowContext(ctx, reportSelect+" WHERE dr.id = $1 AND dr.deleted_at IS NULL", id))
+	if err != nil {
+		return report, notFound(err)
+	}
+
+	reports := []DailyReport{report}
+	if err := s.loadReportChildren(ctx, q, reports); err != nil {
+		return report, err
+	}
+	return reports[0], nil
+}
+
+// idIn returns a condition matching column against a list of IDs bound as a
+// single parameter $n, and the value to bind. PostgreSQL takes an int array;
+// SQLite unpacks a JSON array with json_each.
+func (s *sqlStore) idIn(column string, n int, ids []int) (string, interface{}) {
+	if s.driver == driverSQLite {
+		encoded, _ := json.Marshal(ids)
+		return fmt.Sprintf("%s IN (SELECT value FROM json_each($%d))", column, n), string(encoded)
+	}
+	return fmt.Sprintf("%s = ANY($%d)", column, n), pq.Array(ids)
+}
+
+// textIn is idIn for text values.
+func (s *sqlStore) textIn(column string, n int, values []string) (string, interface{}) {
+	if s.driver == driverSQLite {
+		encoded, _ := json.Marshal(values)
+		return fmt.Sprintf("%s IN (SELECT value FROM json_each($%d))", column, n), string(encoded)
+	}
+	return fmt.Sprintf("%s = ANY($%d)", column, n), pq.Array(values)
+}
+
+// loadReportChildren fills the managers, event titles, health checks and events
+// of all given reports with one query per child table, regardless of how many
+// reports there are.
+func (s *sqlStore) loadReportChildren(ctx context.Context, q queryer, reports []DailyReport) error {
+	if len(reports) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(reports))
+	byID := make(map[int]*DailyReport, len(reports))
+	for i := range reports {
+		ids[i] = reports[i].ID
+		byID[reports[i].ID] = &reports[i]
+	}
+
+	// Load shift managers
+	cond, arg := s.idIn("rsm.report_id", 1, ids)
+	managerRows, err := q.QueryContext(ctx, `
+        SELECT rsm.report_id, u.id, u.username, u.full_name, u.role
+        FROM users u
+        JOIN report_shift_managers rsm ON u.id = rsm.user_id
+        WHERE `+cond+`
+        ORDER BY rsm.report_id, u.id`, arg)
+	if err != nil {
+		return err
+	}
+	defer managerRows.Close()
+
+	for managerRows.Next() {
+		var reportID int
+		var manager User
+		if err := managerRows.Scan(&reportID, &manager.ID, &manager.Username, &manager.FullName, &manager.Role); err != nil {
+			return err
+		}
+		report := byID[reportID]
+		report.ShiftManagers = append(report.ShiftManagers, manager)
+	}
+	if err := managerRows.Err(); err != nil {
+		return err
+	}
+
+	// Load event titles
+	cond, arg = s.idIn("ret.report_id", 1, ids)
+	titleRows, err := q.QueryContext(ctx, `
+        SELECT ret.report_id, et.id, et.title
+        FROM event_titles et
+        JOIN report_event_titles ret ON et.id = ret.event_title_id
+        WHERE `+cond+`
+        ORDER BY ret.report_id, et.id`, arg)
+	if err != nil {
+		return err
+	}
+	defer titleRows.Close()
+
+	for titleRows.Next() {
+		var reportID int
+		var title EventTitle
+		if err := titleRows.Scan(&reportID, &title.ID, &title.Title); err != nil {
+			return err
+		}
+		report := byID[reportID]
+		report.EventTitles = append(report.EventTitles, title)
+	}
+	if err := titleRows.Err(); err != nil {
+		return err
+	}
+
+	// Load health checks
+	cond, arg = s.idIn("hc.report_id", 1, ids)
+	healthRows, err := q.QueryContext(ctx, `
+        SELECT hc.report_id, i.id, i.name, hc.status, COALESCE(hc.comment, ''),
+               hc.reading_number, COALESCE(hc.reading_text, ''), COALESCE(hc.reading_unit, '')
+        FROM report_health_checks hc
+        JOIN health_check_items i ON hc.item_id = i.id
+        WHERE `+cond+`
+        ORDER BY hc.report_id, i.sort_order, i.id`, arg)
+	if err != nil {
+		return err
+	}
+	defer healthRows.Close()
+
+	for healthRows.Next() {
+		var reportID int
+		var result HealthCheckResult
+		var number sql.NullFloat64
+		if err := healthRows.Scan(&reportID, &result.ItemID, &result.Name, &result.Status, &result.Comment,
+			&number, &result.ReadingText, &result.ReadingUnit); err != nil {
+			return err
+		}
+		result.ReadingNumber = nullFloat(number)
+		report := byID[reportID]
+		report.HealthChecks = append(report.HealthChecks, result)
+	}
+	if err := healthRows.Err(); err != nil {
+		return err
+	}
+
+	// Load Part 3 events
+	cond, arg = s.idIn("e.report_id", 1, ids)
+	part3Rows, err := q.QueryContext(ctx, `
+        SELECT e.report_id, e.id, e.event_summary, e.trigger_info, e.start_time, e.end_time, e.rca_id, rc.number,
+               e.incident_id, `+eventClassificationColumns+`
+        FROM report_events_part3 e
+        LEFT JOIN rcas rc ON e.rca_id = rc.id`+eventClassificationJoins+`
+        WHERE `+cond+`
+        ORDER BY e.report_id, e.id`, arg)
+	if err != nil {
+		return err
+	}
+	defer part3Rows.Close()
+
+	for part3Rows.Next() {
+		var reportID int
+		var event EventPart3
+		var summary, trigger, startTime, endTime, rcaNumber sql.NullString
+		var rcaID, incidentID sql.NullInt64
+		var classification eventClassificationScan
+		dest := append([]interface{}{&reportID, &event.ID, &summary, &trigger, &startTime, &endTime, &rcaID, &rcaNumber,
+			&incidentID}, classification.dest()...)
+		if err := part3Rows.Scan(dest...); err != nil {
+			return err
+		}
+		event.EventClassification = classification.value()
+		event.EventSummary, event.Trigger = summary.String, trigger.String
+		event.StartTime, event.EndTime = cfg.Site.timestamp(startTime.String), cfg.Site.timestamp(endTime.String)
+		if rcaID.Valid {
+			id := int(rcaID.Int64)
+			event.RCAID, event.RCANumber = &id, rcaNumber.String
+		}
+		if incidentID.Valid {
+			id := int(incidentID.Int64)
+			event.IncidentID = &id
+		}
+		event.Open = event.EndTime == ""
+		report := byID[reportID]
+		report.EventsPart3 = append(report.EventsPart3, event)
+	}
+	if err := part3Rows.Err(); err != nil {
+		return err
+	}
+
+	// Load Part 4 events
+	cond, arg = s.idIn("e.report_id", 1, ids)
+	part4Rows, err := q.QueryContext(ctx, `
+        SELECT e.report_id, e.id, e.event_summary, e.trigger_info, e.start_time, e.end_time, `+eventClassificationColumns+`
+        FROM report_events_part4 e`+eventClassificationJoins+`
+        WHERE `+cond+`
+        ORDER BY e.report_id, e.id`, arg)
+	if err != nil {
+		return err
+	}
+	defer part4Rows.Close()
+
+	for part4Rows.Next() {
+		var reportID int
+		var event EventPart4
+		var summary, trigger, startTime, endTime sql.NullString
+		var classification eventClassificationScan
+		dest := append([]interface{}{&reportID, &event.ID, &summary, &trigger, &startTime, &endTime}, classification.dest()...)
+		if err := part4Rows.Scan(dest...); err != nil {
+			return err
+		}
+		event.EventClassification = classification.value()
+		event.EventSummary, event.Trigger = summary.String, trigger.String
+		event.StartTime, event.EndTime = cfg.Site.timestamp(startTime.String), cfg.Site.timestamp(endTime.String)
+		event.Open = event.EndTime == ""
+		report := byID[reportID]
+		report.EventsPart4 = append(report.EventsPart4, event)
+	}
+	return part4Rows.Err()
+}
+
+// eventClassificationColumns selects the classification of the event e,
+// joined by eventClassificationJoins. Entries in the trash keep their names.
+const eventClassificationColumns = `e.severity_id, sv.name, e.category_id, ec.name, e.affected_service_id, af.name`
+
+const eventClassificationJoins = `
+        LEFT JOIN severities sv ON e.severity_id = sv.id
+        LEFT JOIN event_categories ec ON e.category_id = ec.id
+        LEFT JOIN affected_services af ON e.affected_service_id = af.id`
+
+// eventClassificationScan receives the eventClassificationColumns of a row.
+type eventClassificationScan struct {
+	severityID, categoryID, serviceID sql.NullInt64
+	severity, category, service       sql.NullString
+}
+
+func (c *eventClassificationScan) dest() []interface{} {
+	return []interface{}{&c.severityID, &c.severity, &c.categoryID, &c.category, &c.serviceID, &c.service}
+}
+
+func (c *eventClassificationScan) value() EventClassification {
+	var classification EventClassification
+	if c.severityID.Valid {
+		id := int(c.severityID.Int64)
+		classification.SeverityID, classification.Severity = &id, c.severity.String
+	}
+	if c.categoryID.Valid {
+		id := int(c.categoryID.Int64)
+		classification.CategoryID, classification.Category = &id, c.category.String
+	}
+	if c.serviceID.Valid {
+		id := int(c.serviceID.Int64)
+		classification.AffectedServiceID, classification.AffectedService = &id, c.service.String
+	}
+	return classification
+}
+
+func (s *sqlStore) CreateReport(ctx context.Context, input ReportInput, createdBy int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	siteID := defaultSiteID
+	if input.SiteID != nil {
+		siteID = *input.SiteID
+	}
+	existingID, err := findDuplicateReport(ctx, tx, siteID, input, 0)
+	if err != nil {
+		return 0, err
+	}
+	if existingID != 0 {
+		return 0, &duplicateReportError{ExistingID: existingID}
+	}
+
+	var reportID int
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO daily_reports (report_date, site_id, shift_hours_id, created_by)
+        VALUES ($1, $2, $3, $4) RETURNING id`,
+		input.ReportDate, siteID, input.ShiftHoursID, createdBy).Scan(&reportID)
+	if err != nil {
+		// Lost a race with a concurrent insert: report the winner
+		if existingID, _ := findDuplicateReport(ctx, s.db, siteID, input, 0); existingID != 0 {
+			return 0, &duplicateReportError{ExistingID: existingID}
+		}
+		return 0, err
+	}
+
+	if err := insertReportChildren(ctx, tx, reportID, input); err != nil {
+		return 0, err
+	}
+	if err := s.recordRevision(ctx, tx, reportID, createdBy); err != nil {
+		return 0, err
+	}
+	return reportID, tx.Commit()
+}
+
+func (s *sqlStore) UpdateReport(ctx context.Context, id int, input ReportInput, updatedBy, version int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Reports without a site in the payload stay on their current site
+	var siteID, currentVersion int
+	var duplicateOf sql.NullInt64
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT site_id, duplicate_of, status, version FROM daily_reports WHERE id = $1 AND deleted_at IS NULL", id).
+		Scan(&siteID, &duplicateOf, &status, &currentVersion)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	if status == statusLocked {
+		return 0, errReportLocked
+	}
+	if version != 0 && version != currentVersion {
+		return 0, errVersionMismatch
+	}
+	if input.SiteID != nil {
+		siteID = *input.SiteID
+	}
+
+	// A report still flagged as a duplicate may be edited in place until it is
+	// merged; it only conflicts with reports of other groups.
+	existingID, err := findDuplicateReport(ctx, tx, siteID, input, id)
+	if err != nil {
+		return 0, err
+	}
+	if existingID != 0 && int64(existingID) != duplicateOf.Int64 {
+		return 0, &duplicateReportError{ExistingID: existingID}
+	}
+	if existingID == 0 {
+		duplicateOf = sql.NullInt64{}
+	}
+	if err := s.recordBaselineRevision(ctx, tx, id); err != nil {
+		return 0, err
+	}
+
+	// Update main report
+	err = affectedOne(tx.ExecContext(ctx, `
+		UPDATE daily_reports SET
+			report_date = $1,
+			site_id = $2,
+			shift_hours_id = $3,
+			duplicate_of = $4,
+			version = version + 1
+		WHERE id = $5 AND status <> 'locked' AND version = $6`,
+		input.ReportDate, siteID, input.ShiftHoursID, duplicateOf, id, currentVersion))
+	if err == errNotFound {
+		// Changed since it was read
+		tx.QueryRowContext(ctx, "SELECT status FROM daily_reports WHERE id = $1", id).Scan(&status)
+		if status == statusLocked {
+			return 0, errReportLocked
+		}
+		return 0, errVersionMismatch
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Replace existing relationships
+	if err := deleteReportChildren(ctx, tx, id); err != nil {
+		return 0, err
+	}
+	if err := insertReportChildren(ctx, tx, id, input); err != nil {
+		return 0, err
+	}
+	if err := s.recordRevision(ctx, tx, id, updatedBy); err != nil {
+		return 0, err
+	}
+	return currentVersion + 1, tx.Commit()
+}
+
+// DeleteReport moves a report to the trash; its contents are kept until purged.
+func (s *sqlStore) DeleteReport(ctx context.Context, id, deletedBy int) error {
+	status, err := s.ReportStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	if status == statusLocked {
+		return errReportLocked
+	}
+
+	err = affectedOne(s.db.ExecContext(ctx, `
+        UPDATE daily_reports SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $1
+        WHERE id = $2 AND deleted_at IS NULL AND status <> 'locked'`, deletedBy, id))
+	if err == errNotFound {
+		return errReportLocked // locked or deleted since it was read
+	}
+	return err
+}
+
+func (s *sqlStore) LatestReportID(ctx context.Context, siteID int) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `
+        SELECT dr.id
+        FROM daily_reports dr
+        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id
+        WHERE dr.site_id = $1 AND dr.deleted_at IS NULL
+        ORDER BY dr.report_date DESC, sh.start_time IS NULL, sh.start_time DESC, dr.id DESC
+        LIMIT 1`, siteID).Scan(&id)
+	return id, notFound(err)
+}
+
+// ReportReferences runs one query per table the payload refers to, over the
+// IDs it uses, so saving a report does not load whole catalogs.
+func (s *sqlStore) ReportReferences(ctx context.Context, input ReportInput) (ReportReferences, error) {
+	refs := ReportReferences{
+		Sites: map[int]bool{}, ShiftHours: map[int]bool{}, Users: map[int]bool{}, EventTitles: map[int]bool{},
+		RCAs: map[int]bool{}, Incidents: map[int]bool{},
+		Severities: map[int]bool{}, EventCategories: map[int]bool{}, AffectedServices: map[int]bool{},
+		RCANumbers: map[int]string{},
+	}
+
+	var siteIDs, rcaIDs, incidentIDs, severityIDs, categoryIDs, serviceIDs []int
+	var rcaNumbers []string
+	if input.SiteID != nil {
+		siteIDs = append(siteIDs, *input.SiteID)
+	}
+	classification := func(c EventClassification) {
+		if c.SeverityID != nil {
+			severityIDs = append(severityIDs, *c.SeverityID)
+		}
+		if c.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *c.CategoryID)
+		}
+		if c.AffectedServiceID != nil {
+			serviceIDs = append(serviceIDs, *c.AffectedServiceID)
+		}
+	}
+	for _, event := range input.EventsPart3 {
+		if event.RCAID != nil {
+			rcaIDs = append(rcaIDs, *event.RCAID)
+		} else if number := strings.TrimSpace(event.RCANumber); number != "" {
+			rcaNumbers = append(rcaNumbers, number)
+		}
+		if event.IncidentID != nil {
+			incidentIDs = append(incidentIDs, *event.IncidentID)
+		}
+		classification(event.EventClassification)
+	}
+	for _, event := range input.EventsPart4 {
+		classification(event.EventClassification)
+	}
+
+	lookups := []struct {
+		table string
+		ids   []int
+		rows  map[int]bool
+	}{
+		{"sites", siteIDs, refs.Sites},
+		{"users", input.ShiftManagerIDs, refs.Users},
+		{"event_titles", input.EventTitleIDs, refs.EventTitles},
+		{"incidents", incidentIDs, refs.Incidents},
+		{"severities", severityIDs, refs.Severities},
+		{"event_categories", categoryIDs, refs.EventCategories},
+		{"affected_services", serviceIDs, refs.AffectedServices},
+	}
+	for _, lookup := range lookups {
+		if len(lookup.ids) == 0 {
+			continue
+		}
+		cond, arg := s.idIn("id", 1, lookup.ids)
+		if err := s.queryDeleted(ctx, lookup.rows, "SELECT id, deleted_at IS NOT NULL FROM "+lookup.table+" WHERE "+cond, arg); err != nil {
+			return refs, err
+		}
+	}
+
+	if input.ShiftHoursID != nil {
+		var shift ShiftHours
+		var deleted bool
+		err := s.db.QueryRowContext(ctx, "SELECT id, name, start_time, end_time, deleted_at IS NOT NULL FROM shift_hours WHERE id = $1",
+			*input.ShiftHoursID).Scan(&shift.ID, &shift.Name, &shift.StartTime, &shift.EndTime, &deleted)
+		if err != nil && err != sql.ErrNoRows {
+			return refs, err
+		}
+		if err == nil {
+			refs.Shift, refs.ShiftHours[shift.ID] = &shift, deleted
+		}
+	}
+
+	if len(rcaIDs) > 0 || len(rcaNumbers) > 0 {
+		idCond, idArg := s.idIn("id", 1, rcaIDs)
+		numberCond, numberArg := s.textIn("number", 2, rcaNumbers)
+		rows, err := s.db.QueryContext(ctx, "SELECT id, number, deleted_at IS NOT NULL FROM rcas WHERE "+idCond+" OR "+numberCond, idArg, numberArg)
+		if err != nil {
+			return refs, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			var number string
+			var deleted bool
+			if err := rows.Scan(&id, &number, &deleted); err != nil {
+				return refs, err
+			}
+			refs.RCAs[id], refs.RCANumbers[id] = deleted, number
+		}
+		if err := rows.Err(); err != nil {
+			return refs, err
+		}
+	}
+	return refs, nil
+}
+
+// queryDeleted reads (id, deleted) rows into rows.
+func (s *sqlStore) queryDeleted(ctx context.Context, rows map[int]bool, query string, args ...interface{}) error {
+	result, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	for result.Next() {
+		var id int
+		var deleted bool
+		if err := result.Scan(&id, &deleted); err != nil {
+			return err
+		}
+		rows[id] = deleted
+	}
+	return result.Err()
+}
+
+func (s *sqlStore) ReportOwner(ctx context.Context, id int) (int, error) {
+	var createdBy sql.NullInt64
+	err := s.db.QueryRowContext(ctx, "SELECT created_by FROM daily_reports WHERE id = $1 AND deleted_at IS NULL", id).Scan(&createdBy)
+	return int(createdBy.Int64), notFound(err)
+}
+
+func (s *sqlStore) ReportStatus(ctx context.Context, id int) (string, error) {
+	var status string
+	err := s.db.QueryRowContext(ctx, "SELECT status FROM daily_reports WHERE id = $1 AND deleted_at IS NULL", id).Scan(&status)
+	return status, notFound(err)
+}
+
+func (s *sqlStore) SetReportStatus(ctx context.Context, id int, from, to, comment string, changedBy int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The comment belongs to the new status, e.g. why a report was rejected
+	err = affectedOne(tx.ExecContext(ctx, `
+        UPDATE daily_reports SET status = $1, status_comment = $2, version = version + 1
+        WHERE id = $3 AND status = $4 AND deleted_at IS NULL`,
+		to, comment, id, from))
+	if err == errNotFound {
+		if _, statusErr := s.ReportStatus(ctx, id); statusErr != nil {
+			return statusErr
+		}
+		return errStatusChanged
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO report_status_history (report_id, from_status, to_status, comment, changed_by)
+        VALUES ($1, $2, $3, $4, $5)`, id, from, to, comment, changedBy)
+	if err != nil {
+		return err
+	}
+
+	if to == statusLocked {
+		if err := s.sealReport(ctx, tx, id); err != nil {
+			return fmt.Errorf("sealing report %d: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) ListStatusHistory(ctx context.Context, id int) ([]StatusChange, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT h.id, h.from_status, h.to_status, COALESCE(h.comment, ''), h.changed_at,
+               u.id, u.username, u.full_name, u.role
+        FROM report_status_history h
+        JOIN users u ON h.changed_by = u.id
+        WHERE h.report_id = $1
+        ORDER BY h.changed_at, h.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []StatusChange
+	for rows.Next() {
+		var change StatusChange
+		err := rows.Scan(&change.ID, &change.FromStatus, &change.ToStatus, &change.Comment, &change.ChangedAt,
+			&change.ChangedBy.ID, &change.ChangedBy.Username, &change.ChangedBy.FullName, &change.ChangedBy.Role)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// findDuplicateReport returns the ID of the report that already covers the
+// site, date and shift of input, ignoring excludeID, or 0 if there is none.
+func findDuplicateReport(ctx context.Context, q queryer, siteID int, input ReportInput, excludeID int) (int, error) {
+	shiftKey := 0
+	if input.ShiftHoursID != nil {
+		shiftKey = *input.ShiftHoursID
+	}
+
+	var id int
+	err := q.QueryRowContext(ctx, `
+        SELECT id FROM daily_reports
+        WHERE site_id = $1 AND report_date = $2 AND COALESCE(shift_hours_id, 0) = $3
+          AND duplicate_of IS NULL AND deleted_at IS NULL AND id <> $4
+        ORDER BY id LIMIT 1`,
+		siteID, input.ReportDate, shiftKey, excludeID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+func (s *sqlStore) ListDuplicateReports(ctx context.Context) ([]DuplicateGroup, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT dr.id, dr.site_id, dr.report_date, dr.shift_hours_id
+        FROM daily_reports dr
+        JOIN (
+            SELECT site_id, report_date, COALESCE(shift_hours_id, 0) AS shift_key
+            FROM daily_reports
+            WHERE deleted_at IS NULL
+            GROUP BY site_id, report_date, COALESCE(shift_hours_id, 0)
+            HAVING COUNT(*) > 1
+        ) d ON d.site_id = dr.site_id AND d.report_date = dr.report_date
+           AND d.shift_key = COALESCE(dr.shift_hours_id, 0)
+        WHERE dr.deleted_at IS NULL
+        ORDER BY dr.report_date, dr.site_id, d.shift_key, dr.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []DuplicateGroup
+	for rows.Next() {
+		var id, siteID int
+		var reportDate string
+		var shiftID sql.NullInt64
+		if err := rows.Scan(&id, &siteID, &reportDate, &shiftID); err != nil {
+			return nil, err
+		}
+
+		var shiftHoursID *int
+		if shiftID.Valid {
+			v := int(shiftID.Int64)
+			shiftHoursID = &v
+		}
+		// Rows are ordered by group, so a new group starts when the key changes
+		n := len(groups)
+		if n == 0 || groups[n-1].SiteID != siteID || groups[n-1].ReportDate != reportDate ||
+			!sameShift(groups[n-1].ShiftHoursID, shiftHoursID) {
+			groups = append(groups, DuplicateGroup{SiteID: siteID, ReportDate: reportDate, ShiftHoursID: shiftHoursID})
+			n++
+		}
+		groups[n-1].ReportIDs = append(groups[n-1].ReportIDs, id)
+	}
+	return groups, rows.Err()
+}
+
+func sameShift(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (s *sqlStore) MergeDuplicateReports(ctx context.Context, targetID int, mergedBy int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM daily_reports WHERE id = $1 AND deleted_at IS NULL", targetID).Scan(&status)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	if status != statusDraft && status != statusRejected {
+		return 0, &mergeStatusError{ReportID: targetID, Status: status}
+	}
+	if err := s.recordBaselineRevision(ctx, tx, targetID); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT d.id, d.status
+        FROM daily_reports d
+        JOIN daily_reports t ON t.id = $1
+        WHERE d.id <> t.id AND d.deleted_at IS NULL AND d.site_id = t.site_id AND d.report_date = t.report_date
+          AND COALESCE(d.shift_hours_id, 0) = COALESCE(t.shift_hours_id, 0)
+        ORDER BY d.id`, targetID)
+	if err != nil {
+		return 0, err
+	}
+	var sourceIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id, &status); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if status != statusDraft && status != statusRejected {
+			rows.Close()
+			return 0, &mergeStatusError{ReportID: id, Status: status}
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	healthChecks, err := s.mergedHealthChecks(ctx, tx, append([]int{targetID}, sourceIDs...))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, sourceID := range sourceIDs {
+		statements := []string{
+			`INSERT INTO report_shift_managers (report_id, user_id)
+             SELECT CAST($1 AS INTEGER), user_id FROM report_shift_managers src WHERE src.report_id = $2
+             AND NOT EXISTS (SELECT 1 FROM report_shift_managers t WHERE t.report_id = $1 AND t.user_id = src.user_id)`,
+			`INSERT INTO report_event_titles (report_id, event_title_id)
+             SELECT CAST($1 AS INTEGER), event_title_id FROM report_event_titles src WHERE src.report_id = $2
+             AND NOT EXISTS (SELECT 1 FROM report_event_titles t WHERE t.report_id = $1 AND t.event_title_id = src.event_title_id)`,
+			"UPDATE report_events_part3 SET report_id = $1 WHERE report_id = $2",
+			"UPDATE report_events_part4 SET report_id = $1 WHERE report_id = $2",
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement, targetID, sourceID); err != nil {
+				return 0, err
+			}
+		}
+		// Merged reports go to the trash with their revisions and status history
+		_, err := tx.ExecContext(ctx, "UPDATE daily_reports SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $1 WHERE id = $2",
+			mergedBy, sourceID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM report_health_checks WHERE report_id = $1", targetID); err != nil {
+		return 0, err
+	}
+	for _, result := range healthChecks {
+		if err := insertHealthCheck(ctx, tx, targetID, result); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE daily_reports SET duplicate_of = NULL, version = version + 1 WHERE id = $1", targetID)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.recordRevision(ctx, tx, targetID, mergedBy); err != nil {
+		return 0, err
+	}
+	return len(sourceIDs), tx.Commit()
+}
+
+// mergedHealthChecks combines the health checks of reports being merged. Each
+// item keeps its worst result with its reading, and the comments of all reports.
+func (s *sqlStore) mergedHealthChecks(ctx context.Context, tx *sql.Tx, reportIDs []int) ([]HealthCheckResult, error) {
+	cond, arg := s.idIn("report_id", 1, reportIDs)
+	rows, err := tx.QueryContext(ctx, `
+        SELECT item_id, status, COALESCE(comment, ''), reading_number, COALESCE(reading_text, ''), COALESCE(reading_unit, '')
+        FROM report_health_checks
+        WHERE `+cond+`
+        ORDER BY report_id, item_id`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var merged []HealthCheckResult
+	byItem := make(map[int]int)
+	for rows.Next() {
+		var result HealthCheckResult
+		var number sql.NullFloat64
+		if err := rows.Scan(&result.ItemID, &result.Status, &result.Comment, &number, &result.ReadingText, &result.ReadingUnit); err != nil {
+			return nil, err
+		}
+		result.ReadingNumber = nullFloat(number)
+		i, seen := byItem[result.ItemID]
+		if !seen {
+			byItem[result.ItemID] = len(merged)
+			merged = append(merged, result)
+			continue
+		}
+		if healthSeverity(result.Status) > healthSeverity(merged[i].Status) {
+			merged[i].Status = result.Status
+			merged[i].ReadingNumber, merged[i].ReadingText, merged[i].ReadingUnit = result.ReadingNumber, result.ReadingText, result.ReadingUnit
+		}
+		if result.Comment != "" && !strings.Contains(merged[i].Comment, result.Comment) {
+			merged[i].Comment = strings.TrimPrefix(merged[i].Comment+"; "+result.Comment, "; ")
+		}
+	}
+	return merged, rows.Err()
+}
+
+func insertHealthCheck(ctx context.Context, tx *sql.Tx, reportID int, result HealthCheckResult) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO report_health_checks (report_id, item_id, status, comment, reading_number, reading_text, reading_unit)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		reportID, result.ItemID, result.Status, result.Comment, result.ReadingNumber, result.ReadingText, result.ReadingUnit)
+	return err
+}
+
+// Revisions
+
+// recordRevision stores the report as saved by tx as its next revision.
+func (s *sqlStore) recordRevision(ctx context.Context, tx *sql.Tx, reportID, createdBy int) error {
+	report, err := s.getReport(ctx, tx, reportID)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO report_revisions (report_id, revision, snapshot, created_by)
+        SELECT CAST($1 AS INTEGER), COALESCE(MAX(revision), 0) + 1, $2, CAST($3 AS INTEGER)
+        FROM report_revisions WHERE report_id = $1`,
+		reportID, string(snapshot), createdBy)
+	return err
+}
+
+// recordBaselineRevision snapshots a report saved before revisions existed,
+// so its first change can still be diffed and undone. It is attributed to the
+// report's author.
+func (s *sqlStore) recordBaselineRevision(ctx context.Context, tx *sql.Tx, reportID int) error {
+	var count int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM report_revisions WHERE report_id = $1", reportID).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	report, err := s.getReport(ctx, tx, reportID)
+	if err != nil {
+		return err
+	}
+	return s.recordRevision(ctx, tx, reportID, report.CreatedBy.ID)
+}
+
+func (s *sqlStore) ListRevisions(ctx context.Context, reportID int) ([]ReportRevision, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT r.id, r.report_id, r.revision, r.created_at, u.id, u.username, u.full_name, u.role
+        FROM report_revisions r
+        JOIN users u ON r.created_by = u.id
+        WHERE r.report_id = $1
+        ORDER BY r.revision`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []ReportRevision
+	for rows.Next() {
+		var revision ReportRevision
+		err := rows.Scan(&revision.ID, &revision.ReportID, &revision.Revision, &revision.CreatedAt,
+			&revision.CreatedBy.ID, &revision.CreatedBy.Username, &revision.CreatedBy.FullName, &revision.CreatedBy.Role)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *sqlStore) GetRevision(ctx context.Context, reportID, revision int) (ReportRevision, error) {
+	var result ReportRevision
+	var snapshot string
+	err := s.db.QueryRowContext(ctx, `
+        SELECT r.id, r.report_id, r.revision, r.snapshot, r.created_at, u.id, u.username, u.full_name, u.role
+        FROM report_revisions r
+        JOIN users u ON r.created_by = u.id
+        WHERE r.report_id = $1 AND r.revision = $2`, reportID, revision).
+		Scan(&result.ID, &result.ReportID, &result.Revision, &snapshot, &result.CreatedAt,
+			&result.CreatedBy.ID, &result.CreatedBy.Username, &result.CreatedBy.FullName, &result.CreatedBy.Role)
+	if err != nil {
+		return result, notFound(err)
+	}
+
+	result.Snapshot = &DailyReport{}
+	if err := json.Unmarshal([]byte(snapshot), result.Snapshot); err != nil {
+		return result, fmt.Errorf("revision %d of report %d: %v", revision, reportID, err)
+	}
+	if err := upgradeLegacySnapshot([]byte(snapshot), result.Snapshot); err != nil {
+		return result, fmt.Errorf("revision %d of report %d: %v", revision, reportID, err)
+	}
+	return result, nil
+}
+
+// deleteReportChildren deletes the relationships and health check results of
+// a report. Its events are updated in place by saveReportEvents.
+func deleteReportChildren(ctx context.Context, tx *sql.Tx, reportID int) error {
+	for _, table := range []string{"report_shift_managers", "report_event_titles", "report_health_checks"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE report_id = $1", reportID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertReportChildren(ctx context.Context, tx *sql.Tx, reportID int, input ReportInput) error {
+	window, err := reportWindow(ctx, tx, input)
+	if err != nil {
+		return err
+	}
+
+	// Insert shift managers
+	for _, managerID := range input.ShiftManagerIDs {
+		_, err := tx.ExecContext(ctx, "INSERT INTO report_shift_managers (report_id, user_id) VALUES ($1, $2)",
+			reportID, managerID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Insert event titles
+	for _, titleID := range input.EventTitleIDs {
+		_, err := tx.ExecContext(ctx, "INSERT INTO report_event_titles (report_id, event_title_id) VALUES ($1, $2)",
+			reportID, titleID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Insert health check results
+	for _, result := range input.HealthChecks {
+		if err := insertHealthCheck(ctx, tx, reportID, result); err != nil {
+			return err
+		}
+	}
+
+	return saveReportEvents(ctx, tx, reportID, window, input)
+}
+
+// saveReportEvents updates the events of a report in place by their ID, adds
+// the ones without an ID and deletes the ones left out, so that incidents,
+// escalations and the audit log keep pointing at the same rows. An ID that is
+// not one of the report's events is saved as a new event. An event saved
+// without an incident stays in the one it is attached to; it is detached
+// through the incident.
+func saveReportEvents(ctx context.Context, tx *sql.Tx, reportID int, window shiftWindow, input ReportInput) error {
+	part3, err := reportEventIDs(ctx, tx, "report_events_part3", reportID)
+	if err != nil {
+		return err
+	}
+	for i, event := range input.EventsPart3 {
+		start, end, err := window.eventTimes(event.StartTime, event.EndTime)
+		if err != nil {
+			return fmt.Errorf("%w: events_part3[%d]: %v", errInvalidInput, i, err)
+		}
+		if part3[event.ID] {
+			_, err = tx.ExecContext(ctx, `
+                UPDATE report_events_part3 SET event_summary = $1, trigger_info = $2, start_time = $3, end_time = $4,
+                       rca_id = $5, incident_id = COALESCE($6, incident_id), severity_id = $7, category_id = $8, affected_service_id = $9
+                WHERE id = $10`,
+				event.EventSummary, event.Trigger, start, end, event.RCAID, event.IncidentID,
+				event.SeverityID, event.CategoryID, event.AffectedServiceID, event.ID)
+			delete(part3, event.ID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+                INSERT INTO report_events_part3 (report_id, event_summary, trigger_info, start_time, end_time, rca_id, incident_id,
+                                                 severity_id, category_id, affected_service_id)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+				reportID, event.EventSummary, event.Trigger, start, end, event.RCAID, event.IncidentID,
+				event.SeverityID, event.CategoryID, event.AffectedServiceID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	part4, err := reportEventIDs(ctx, tx, "report_events_part4", reportID)
+	if err != nil {
+		return err
+	}
+	for i, event := range input.EventsPart4 {
+		start, end, err := window.eventTimes(event.StartTime, event.EndTime)
+		if err != nil {
+			return fmt.Errorf("%w: events_part4[%d]: %v", errInvalidInput, i, err)
+		}
+		if part4[event.ID] {
+			_, err = tx.ExecContext(ctx, `
+                UPDATE report_events_part4 SET event_summary = $1, trigger_info = $2, start_time = $3, end_time = $4,
+                       severity_id = $5, category_id = $6, affected_service_id = $7
+                WHERE id = $8`,
+				event.EventSummary, event.Trigger, start, end, event.SeverityID, event.CategoryID, event.AffectedServiceID, event.ID)
+			delete(part4, event.ID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+                INSERT INTO report_events_part4 (report_id, event_summary, trigger_info, start_time, end_time,
+                                                 severity_id, category_id, affected_service_id)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				reportID, event.EventSummary, event.Trigger, start, end, event.SeverityID, event.CategoryID, event.AffectedServiceID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// What is left was removed from the report
+	for table, removed := range map[string]map[int]bool{"report_events_part3": part3, "report_events_part4": part4} {
+		for id := range removed {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = $1", id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reportEventIDs returns the IDs of the events of a report in table.
+func reportEventIDs(ctx context.Context, tx *sql.Tx, table string, reportID int) (map[int]bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM "+table+" WHERE report_id = $1", reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// reportWindow loads the shift of a report being saved and returns the window
+// its event times are placed in.
+func reportWindow(ctx context.Context, tx *sql.Tx, input ReportInput) (shiftWindow, error) {
+	var shift *ShiftHours
+	if input.ShiftHoursID != nil {
+		shift = &ShiftHours{ID: *input.ShiftHoursID}
+		err := tx.QueryRowContext(ctx, "SELECT start_time, end_time FROM shift_hours WHERE id = $1", shift.ID).
+			Scan(&shift.StartTime, &shift.EndTime)
+		if err == sql.ErrNoRows {
+			return shiftWindow{}, fmt.Errorf("%w: unknown shift %d", errInvalidInput, shift.ID)
+		}
+		if err != nil {
+			return shiftWindow{}, err
+		}
+	}
+
+	window, err := newShiftWindow(input.ReportDate, shift)
+	if err != nil {
+		return shiftWindow{}, fmt.Errorf("%w: %v", errInvalidInput, err)
+	}
+	return window, nil
+}
+
+// timeOfDay extracts HH:MM from a TIMESTAMP value ("YYYY-MM-DD HH:MM:SS").
+func timeOfDay(value string) string {
+	if len(value) >= 19 {
+		return value[11:16]
+	}
+	return value
+}
+
+// affectedOne turns an Exec result that touched no rows into errNotFound.
+func affectedOne(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// Report chain
+
+// sealReport appends the report, as saved by tx, to the report chain.
+func (s *sqlStore) sealReport(ctx context.Context, tx *sql.Tx, id int) error {
+	if s.driver == driverPostgres {
+		// Links are numbered consecutively, so seal one report at a time
+		if _, err := tx.ExecContext(ctx, "LOCK TABLE report_chain IN EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+	}
+
+	var last ChainLink
+	var prev *ChainLink
+	err := tx.QueryRowContext(ctx, "SELECT seq, hash FROM report_chain ORDER BY seq DESC LIMIT 1").Scan(&last.Seq, &last.Hash)
+	switch {
+	case err == nil:
+		prev = &last
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	report, err := s.getReport(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	link, err := newChainLink(s.chainKey, prev, report)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO report_chain (seq, report_id, content_hash, canonical_version, prev_hash, hash, signature)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		link.Seq, link.ReportID, link.ContentHash, link.Version, link.PrevHash, link.Hash, link.Signature)
+	return err
+}
+
+// unsealedReports returns the locked reports missing from the chain.
+func (s *sqlStore) unsealedReports(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id FROM daily_reports
+        WHERE status = $1 AND deleted_at IS NULL
+          AND id NOT IN (SELECT report_id FROM report_chain)
+        ORDER BY id`, statusLocked)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *sqlStore) SealLockedReports(ctx context.Context) (int, error) {
+	ids, err := s.unsealedReports(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return i, err
+		}
+		if err := s.sealReport(ctx, tx, id); err != nil {
+			tx.Rollback()
+			return i, fmt.Errorf("sealing report %d: %w", id, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+func (s *sqlStore) ListChain(ctx context.Context) ([]ChainLink, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT seq, report_id, content_hash, canonical_version, prev_hash, hash, signature, sealed_at
+        FROM report_chain
+        ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []ChainLink
+	for rows.Next() {
+		var link ChainLink
+		var version sql.NullInt64
+		err := rows.Scan(&link.Seq, &link.ReportID, &link.ContentHash, &version, &link.PrevHash, &link.Hash, &link.Signature, &link.SealedAt)
+		if err != nil {
+			return nil, err
+		}
+		link.Version = int(version.Int64)
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (s *sqlStore) VerifyChain(ctx context.Context) (ChainVerification, error) {
+	if s.chainKey == nil {
+		return ChainVerification{}, fmt.Errorf("no chain signing key loaded")
+	}
+	links, err := s.ListChain(ctx)
+	if err != nil {
+		return ChainVerification{}, err
+	}
+
+	// Deleted reports count as missing
+	rows, err := s.db.QueryContext(ctx, reportSelect+`
+        WHERE dr.deleted_at IS NULL AND dr.id IN (SELECT report_id FROM report_chain)`)
+	if err != nil {
+		return ChainVerification{}, err
+	}
+	var reports []DailyReport
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			rows.Close()
+			return ChainVerification{}, err
+		}
+		reports = append(reports, report)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return ChainVerification{}, err
+	}
+	if err := s.loadReportChildren(ctx, s.db, reports); err != nil {
+		return ChainVerification{}, err
+	}
+	byID := make(map[int]DailyReport, len(reports))
+	for _, report := range reports {
+		byID[report.ID] = report
+	}
+
+	unsealed, err := s.unsealedReports(ctx)
+	if err != nil {
+		return ChainVerification{}, err
+	}
+	return verifyChain(links, byID, unsealed, s.chainKey.Public().(ed25519.PublicKey)), nil
+}
+
+// Trash
+
+func (s *sqlStore) softDelete(ctx context.Context, table string, id, deletedBy int) error {
+	return affectedOne(s.db.ExecContext(ctx,
+		"UPDATE "+table+" SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $1 WHERE id = $2 AND deleted_at IS NULL",
+		deletedBy, id))
+}
+
+func (s *sqlStore) ListTrash(ctx context.Context, itemType string) ([]TrashItem, error) {
+	types := trashTypeOrder
+	if itemType != "" {
+		types = []string{itemType}
+	}
+
+	var items []TrashItem
+	for _, name := range types {
+		kind, ok := trashTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown trash type %q", errInvalidInput, name)
+		}
+		rows, err := s.db.QueryContext(ctx, `
+            SELECT t.id, `+kind.Name+`, t.deleted_at, u.id, u.username, u.full_name, u.role
+            FROM `+kind.Table+` t
+            LEFT JOIN users u ON t.deleted_by = u.id
+            WHERE t.deleted_at IS NOT NULL`)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			item := TrashItem{Type: name}
+			var userID sql.NullInt64
+			var username, fullName, role sql.NullString
+			if err := rows.Scan(&item.ID, &item.Name, &item.DeletedAt, &userID, &username, &fullName, &role); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if userID.Valid {
+				item.DeletedBy = &User{ID: int(userID.Int64), Username: username.String, FullName: fullName.String, Role: role.String}
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedAt > items[j].DeletedAt })
+	return items, nil
+}
+
+func (s *sqlStore) RestoreTrash(ctx context.Context, itemType string, id int) error {
+	kind, ok := trashTypes[itemType]
+	if !ok {
+		return fmt.Errorf("%w: unknown trash type %q", errInvalidInput, itemType)
+	}
+
+	// A report cannot come back if another one has taken its site, date and
+	// shift. That includes the report a duplicate was merged into, which has
+	// its events now.
+	if itemType == "report" {
+		var existingID int
+		err := s.db.QueryRowContext(ctx, `
+            SELECT o.id FROM daily_reports d
+            JOIN daily_reports o ON o.site_id = d.site_id AND o.report_date = d.report_date
+             AND COALESCE(o.shift_hours_id, 0) = COALESCE(d.shift_hours_id, 0)
+            WHERE d.id = $1 AND o.id <> d.id AND o.deleted_at IS NULL
+            ORDER BY o.id LIMIT 1`, id).Scan(&existingID)
+		if err == nil {
+			return &duplicateReportError{ExistingID: existingID}
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	return affectedOne(s.db.ExecContext(ctx,
+		"UPDATE "+kind.Table+" SET deleted_at = NULL, deleted_by = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id))
+}
+
+func (s *sqlStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := before.UTC().Format(timestampLayout)
+	purged := 0
+	for _, name := range trashTypeOrder {
+		kind := trashTypes[name]
+
+		if name == "report" {
+			// Report history goes with the report
+			for _, table := range []string{"report_shift_managers", "report_event_titles", "report_health_checks",
+				"report_events_part3", "report_events_part4", "report_revisions", "report_status_history"} {
+				_, err := tx.ExecContext(ctx, "DELETE FROM "+table+
+					" WHERE report_id IN (SELECT id FROM daily_reports WHERE deleted_at < $1)", cutoff)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		query := "DELETE FROM " + kind.Table + " WHERE deleted_at < $1"
+		for _, reference := range kind.References {
+			table, column, _ := strings.Cut(reference, ".")
+			query += fmt.Sprintf(" AND NOT EXISTS (SELECT 1 FROM %s r WHERE r.%s = %s.id)", table, column, kind.Table)
+		}
+		res, err := tx.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += int(n)
+	}
+	return purged, tx.Commit()
+}
+
+// Audit log
+
+func (s *sqlStore) RecordAudit(ctx context.Context, entry AuditEntry) error {
+	_, err := s.db.ExecContext(ctx, `
+        INSERT INTO audit_log (actor_id, actor_username, action, entity_type, entity_id,
+                               before_data, after_data, ip_address, user_agent)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		entry.ActorID, entry.ActorUsername, entry.Action, entry.EntityType, entry.EntityID,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.IPAddress, entry.UserAgent)
+	return err
+}
+
+func nullJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+func (s *sqlStore) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, int, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.ActorID != 0 {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		add("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		add("entity_id = $%d", filter.EntityID)
+	}
+	if filter.DateFrom != "" {
+		add("occurred_at >= $%d", filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		// date_to is inclusive: compare against the start of the next day
+		next, err := time.Parse("2006-01-02", filter.DateTo)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: invalid date %q", errInvalidInput, filter.DateTo)
+		}
+		add("occurred_at < $%d", next.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+        SELECT id, occurred_at, actor_id, COALESCE(actor_username, ''), action, entity_type, entity_id,
+               before_data, after_data, COALESCE(ip_address, ''), COALESCE(user_agent, '')
+        FROM audit_log` + where + " ORDER BY occurred_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.Limit, filter.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var actorID, entityID sql.NullInt64
+		var before, after sql.NullString
+		err := rows.Scan(&entry.ID, &entry.OccurredAt, &actorID, &entry.ActorUsername, &entry.Action,
+			&entry.EntityType, &entityID, &before, &after, &entry.IPAddress, &entry.UserAgent)
+		if err != nil {
+			return nil, 0, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			entry.ActorID = &id
+		}
+		if entityID.Valid {
+			id := int(entityID.Int64)
+			entry.EntityID = &id
+		}
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// Search
+
+// Snippet highlight markers. They cannot occur in typed text, so snippets can
+// be HTML-escaped before the markers are turned into <mark> tags.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+const postgresEventSearch = `
+        FROM (
+            SELECT 3 AS part, p3.id, p3.report_id, p3.event_summary, p3.trigger_info, p3.start_time, p3.end_time,
+                   rc.number AS rca_number, p3.severity_id, p3.category_id, p3.affected_service_id, p3.search_vector
+            FROM report_events_part3 p3
+            LEFT JOIN rcas rc ON p3.rca_id = rc.id AND rc.deleted_at IS NULL
+            UNION ALL
+            SELECT 4, id, report_id, event_summary, trigger_info, start_time, end_time, NULL,
+                   severity_id, category_id, affected_service_id, search_vector
+            FROM report_events_part4
+        ) e
+        JOIN daily_reports dr ON dr.id = e.report_id` + eventClassificationJoins + `
+        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id
+        CROSS JOIN websearch_to_tsquery('english', $1) q
+        WHERE e.search_vector @@ q AND dr.deleted_at IS NULL`
+
+const postgresEventSearchSelect = `
+        SELECT e.part, e.id, e.report_id, dr.report_date, sh.id, sh.name, sh.start_time, sh.end_time,
+               COALESCE(e.event_summary, ''), COALESCE(e.trigger_info, ''), e.start_time, e.end_time,
+               COALESCE(e.rca_number, ''),
+               ts_headline('english', COALESCE(e.event_summary, ''), q, 'StartSel=' || chr(2) || ', StopSel=' || chr(3)),
+               ts_headline('english', COALESCE(e.trigger_info, ''), q, 'StartSel=' || chr(2) || ', StopSel=' || chr(3)),
+               ts_rank(e.search_vector, q) AS score, ` + eventClassificationColumns
+
+const sqliteEventSearch = `
+        FROM event_search es
+        JOIN daily_reports dr ON dr.id = es.report_id
+        LEFT JOIN shift_hours sh ON dr.shift_hours_id = sh.id
+        LEFT JOIN report_events_part3 p3 ON es.part = 3 AND p3.id = es.event_id
+        LEFT JOIN report_events_part4 p4 ON es.part = 4 AND p4.id = es.event_id
+        LEFT JOIN rcas rc ON p3.rca_id = rc.id AND rc.deleted_at IS NULL
+        LEFT JOIN severities sv ON sv.id = COALESCE(p3.severity_id, p4.severity_id)
+        LEFT JOIN event_categories ec ON ec.id = COALESCE(p3.category_id, p4.category_id)
+        LEFT JOIN affected_services af ON af.id = COALESCE(p3.affected_service_id, p4.affected_service_id)
+        WHERE event_search MATCH $1 AND dr.deleted_at IS NULL`
+
+// bm25 is lower for better matches; negate it so that both backends rank
+// higher scores first. Summaries weigh twice as much as trigger info.
+const sqliteEventSearchSelect = `
+        SELECT es.part, es.event_id, es.report_id, dr.report_date, sh.id, sh.name, sh.start_time, sh.end_time,
+               es.event_summary, es.trigger_info,
+               COALESCE(p3.start_time, p4.start_time), COALESCE(p3.end_time, p4.end_time),
+               COALESCE(rc.number, ''),
+               snippet(event_search, 0, char(2), char(3), '…', 24),
+               snippet(event_search, 1, char(2), char(3), '…', 24),
+               -bm25(event_search, 2.0, 1.0) AS score,
+               COALESCE(p3.severity_id, p4.severity_id), sv.name, COALESCE(p3.category_id, p4.category_id), ec.name,
+               COALESCE(p3.affected_service_id, p4.affected_service_id), af.name`
+
+func (s *sqlStore) SearchEvents(ctx context.Context, query SearchQuery) ([]SearchResult, int, error) {
+	from, selectList, alias, eventID := postgresEventSearch, postgresEventSearchSelect, "e", "e.id"
+	column := func(name string) string { return "e." + name }
+	args := []interface{}{query.Text}
+	if s.driver == driverSQLite {
+		from, selectList, alias, eventID = sqliteEventSearch, sqliteEventSearchSelect, "es", "es.event_id"
+		column = func(name string) string { return "COALESCE(p3." + name + ", p4." + name + ")" }
+		args[0] = ftsQuery(query.Text)
+	}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		from += fmt.Sprintf(" AND "+cond, len(args))
+	}
+	if query.DateFrom != "" {
+		add("dr.report_date >= $%d", query.DateFrom)
+	}
+	if query.DateTo != "" {
+		add("dr.report_date <= $%d", query.DateTo)
+	}
+	if query.Part != 0 {
+		add(alias+".part = $%d", query.Part)
+	}
+	if query.SeverityID != 0 {
+		add(column("severity_id")+" = $%d", query.SeverityID)
+	}
+	if query.CategoryID != 0 {
+		add(column("category_id")+" = $%d", query.CategoryID)
+	}
+	if query.AffectedServiceID != 0 {
+		add(column("affected_service_id")+" = $%d", query.AffectedServiceID)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sqlQuery := selectList + from + " ORDER BY score DESC, dr.report_date DESC, " + eventID + " DESC"
+	if query.Limit > 0 {
+		sqlQuery += fmt.Sprintf(" LIMIT %d OFFSET %d", query.Limit, query.Offset)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		var shiftID sql.NullInt64
+		var shiftName, shiftStart, shiftEnd, startTime, endTime sql.NullString
+		var classification eventClassificationScan
+		dest := append([]interface{}{&result.Part, &result.EventID, &result.ReportID, &result.ReportDate,
+			&shiftID, &shiftName, &shiftStart, &shiftEnd,
+			&result.EventSummary, &result.Trigger, &startTime, &endTime, &result.RCANumber,
+			&result.SummarySnippet, &result.TriggerSnippet, &result.Rank}, classification.dest()...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, err
+		}
+		result.EventClassification = classification.value()
+		if shiftID.Valid {
+			result.ShiftHours = &ShiftHours{
+				ID:        int(shiftID.Int64),
+				Name:      shiftName.String,
+				StartTime: shiftStart.String,
+				EndTime:   shiftEnd.String,
+			}
+		}
+		result.StartTime, result.EndTime = cfg.Site.timestamp(startTime.String), cfg.Site.timestamp(endTime.String)
+		result.SummarySnippet = highlightHTML(result.SummarySnippet)
+		result.TriggerSnippet = highlightHTML(result.TriggerSnippet)
+		results = append(results, result)
+	}
+	return results, total, rows.Err()
+}
+
+// ftsQuery turns free text into an FTS5 query matching all words, quoting each
+// word so that FTS5 operators in the input are searched for literally.
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// highlightHTML escapes a snippet and turns the highlight markers into <mark> tags.
+func highlightHTML(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
+}
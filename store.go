@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errNotFound is returned by the stores when the addressed row does not exist.
+var errNotFound = errors.New("not found")
+
+// duplicateReportError is returned when saving a report would give a site a
+// second report for the same date and shift.
+type duplicateReportError struct {
+	ExistingID int
+}
+
+func (e *duplicateReportError) Error() string {
+	return fmt.Sprintf("report %d already exists for this site, date and shift", e.ExistingID)
+}
+
+// eventAttachedError is returned when attaching an event that already belongs
+// to another incident.
+type eventAttachedError struct {
+	IncidentID int
+}
+
+func (e *eventAttachedError) Error() string {
+	return fmt.Sprintf("event already belongs to incident %d", e.IncidentID)
+}
+
+// mergeStatusError is returned when a report to be merged has been submitted,
+// approved or locked.
+type mergeStatusError struct {
+	ReportID int
+	Status   string
+}
+
+func (e *mergeStatusError) Error() string {
+	return fmt.Sprintf("report %d is %s; only draft and rejected reports can be merged", e.ReportID, e.Status)
+}
+
+// errReportLocked is returned when changing or deleting a locked report.
+var errReportLocked = errors.New("report is locked and can no longer be changed")
+
+// errStatusChanged is returned when a report's status changed since it was read.
+var errStatusChanged = errors.New("report status has changed")
+
+// errVersionMismatch is returned when a report changed since the version the
+// caller based its update on.
+var errVersionMismatch = errors.New("report has been changed since it was read")
+
+// errInvalidInput wraps errors caused by the submitted data rather than the database.
+var errInvalidInput = errors.New("invalid input")
+
+type UserStore interface {
+	ListUsers(ctx context.Context) ([]User, error)
+	GetUser(ctx context.Context, id int) (User, error)
+	// GetUserCredentials returns the user and bcrypt hash for a username.
+	GetUserCredentials(ctx context.Context, username string) (User, string, error)
+	CreateUser(ctx context.Context, user User, passwordHash string) (int, error)
+	// UpdateUser keeps the current password when passwordHash is empty.
+	UpdateUser(ctx context.Context, user User, passwordHash string) error
+	DeleteUser(ctx context.Context, id, deletedBy int) error
+}
+
+type CatalogStore interface {
+	ListSites(ctx context.Context) ([]Site, error)
+	CreateSite(ctx context.Context, site Site) (int, error)
+	UpdateSite(ctx context.Context, site Site) error
+	DeleteSite(ctx context.Context, id, deletedBy int) error
+
+	ListShiftHours(ctx context.Context) ([]ShiftHours, error)
+	CreateShiftHours(ctx context.Context, shift ShiftHours) (int, error)
+	UpdateShiftHours(ctx context.Context, shift ShiftHours) error
+	DeleteShiftHours(ctx context.Context, id, deletedBy int) error
+
+	ListEventTitles(ctx context.Context) ([]EventTitle, error)
+	CreateEventTitle(ctx context.Context, title EventTitle) (int, error)
+	UpdateEventTitle(ctx context.Context, title EventTitle) error
+	DeleteEventTitle(ctx context.Context, id, deletedBy int) error
+
+	// ListSeverities returns the severity scale, lowest level first.
+	ListSeverities(ctx context.Context) ([]Severity, error)
+	CreateSeverity(ctx context.Context, severity Severity) (int, error)
+	UpdateSeverity(ctx context.Context, severity Severity) error
+	DeleteSeverity(ctx context.Context, id, deletedBy int) error
+
+	ListEventCategories(ctx context.Context) ([]EventCategory, error)
+	CreateEventCategory(ctx context.Context, category EventCategory) (int, error)
+	UpdateEventCategory(ctx context.Context, category EventCategory) error
+	DeleteEventCategory(ctx context.Context, id, deletedBy int) error
+
+	ListAffectedServices(ctx context.Context) ([]AffectedService, error)
+	CreateAffectedService(ctx context.Context, service AffectedService) (int, error)
+	UpdateAffectedService(ctx context.Context, service AffectedService) error
+	DeleteAffectedService(ctx context.Context, id, deletedBy int) error
+
+	// ListHealthCheckItems returns the health checklist in display order,
+	// inactive items included.
+	ListHealthCheckItems(ctx context.Context) ([]HealthCheckItem, error)
+	CreateHealthCheckItem(ctx context.Context, item HealthCheckItem) (int, error)
+	UpdateHealthCheckItem(ctx context.Context, item HealthCheckItem) error
+	DeleteHealthCheckItem(ctx context.Context, id, deletedBy int) error
+}
+
+type ReportStore interface {
+	// ListReports returns one page of reports matching filter and the total
+	// number of matching reports.
+	ListReports(ctx context.Context, filter ReportFilter) ([]DailyReport, int, error)
+	GetReport(ctx context.Context, id int) (DailyReport, error)
+	CreateReport(ctx context.Context, input ReportInput, createdBy int) (int, error)
+	// UpdateReport replaces a report's contents and returns its new version.
+	// It fails with errVersionMismatch unless the report is still at version;
+	// 0 skips the check. Every create, update and merge records a revision
+	// attributed to the given user.
+	UpdateReport(ctx context.Context, id int, input ReportInput, updatedBy, version int) (int, error)
+	DeleteReport(ctx context.Context, id, deletedBy int) error
+	// ReportReferences looks up the rows a report payload refers to.
+	ReportReferences(ctx context.Context, input ReportInput) (ReportReferences, error)
+	// LatestReportID returns the report of the site's most recent shift.
+	LatestReportID(ctx context.Context, siteID int) (int, error)
+	// ReportOwner returns the ID of the user who created the report.
+	ReportOwner(ctx context.Context, id int) (int, error)
+	ReportStatus(ctx context.Context, id int) (string, error)
+	// SetReportStatus moves a report from one status to another and records
+	// the change. It returns errStatusChanged if the report is no longer in from.
+	SetReportStatus(ctx context.Context, id int, from, to, comment string, changedBy int) error
+	ListStatusHistory(ctx context.Context, id int) ([]StatusChange, error)
+	// ListRevisions returns a report's revisions, oldest first, without snapshots.
+	ListRevisions(ctx context.Context, reportID int) ([]ReportRevision, error)
+	GetRevision(ctx context.Context, reportID, revision int) (ReportRevision, error)
+
+	// ListDuplicateReports returns the groups of reports that share a site,
+	// date and shift (created before the uniqueness rule existed).
+	ListDuplicateReports(ctx context.Context) ([]DuplicateGroup, error)
+	// MergeDuplicateReports merges every other report of targetID's group into
+	// it, moves the merged reports to the trash and returns how many there were.
+	// It returns a *mergeStatusError unless every report of the group is a
+	// draft or rejected.
+	MergeDuplicateReports(ctx context.Context, targetID int, mergedBy int) (int, error)
+}
+
+// ReportFilter selects, sorts and pages reports. Zero values mean "no filter".
+type ReportFilter struct {
+	Date           string
+	DateFrom       string
+	DateTo         string
+	SiteID         int
+	ShiftHoursID   int
+	Status         string
+	CreatedBy      int
+	ShiftManagerID int
+	EventTitleID   int
+	HealthFailed   *bool // at least one health check failed
+	HasRCAEvents   *bool // has Part 3 (RCA) events
+	HasOpenEvents  *bool // has events without an end time
+	// Reports with at least one event of this severity, category or
+	// affected service
+	SeverityID        int
+	CategoryID        int
+	AffectedServiceID int
+	Sort              string
+	Limit             int
+	Offset            int
+}
+
+// ReportReferences holds the rows a report payload refers to, looked up by
+// the IDs in the payload. Each map tells whether the row with that ID is
+// deleted; IDs without a row are missing.
+type ReportReferences struct {
+	Sites            map[int]bool
+	ShiftHours       map[int]bool
+	Users            map[int]bool
+	EventTitles      map[int]bool
+	RCAs             map[int]bool
+	Incidents        map[int]bool
+	Severities       map[int]bool
+	EventCategories  map[int]bool
+	AffectedServices map[int]bool
+	// Shift is the report's shift, RCANumbers the number of each RCA. RCAs
+	// given only by number are looked up by it.
+	Shift      *ShiftHours
+	RCANumbers map[int]string
+}
+
+// Sortable report columns; prefix the key with "-" for descending order.
+var reportSortColumns = map[string]string{
+	"report_date": "dr.report_date",
+	"created_at":  "dr.created_at",
+	"shift":       "sh.start_time",
+	"id":          "dr.id",
+}
+
+type ChainStore interface {
+	// SealLockedReports seals locked reports that are not in the chain yet
+	// (ones locked before the chain existed) and returns how many it sealed.
+	SealLockedReports(ctx context.Context) (int, error)
+	ListChain(ctx context.Context) ([]ChainLink, error)
+	VerifyChain(ctx context.Context) (ChainVerification, error)
+}
+
+type RCAStore interface {
+	// ListRCAs returns every RCA with the events it covers, newest first.
+	ListRCAs(ctx context.Context) ([]RCA, error)
+	GetRCA(ctx context.Context, id int) (RCA, error)
+	// CreateRCA gives the RCA the next number of the current year, such as
+	// RCA-2024-007, and returns its ID and number.
+	CreateRCA(ctx context.Context, rca RCA, createdBy int) (int, string, error)
+	UpdateRCA(ctx context.Context, rca RCA) error
+	DeleteRCA(ctx context.Context, id, deletedBy int) error
+	// RCAOwner returns the owner of the RCA, or its creator if it has none.
+	RCAOwner(ctx context.Context, id int) (int, error)
+
+	// ListRCAActions returns the open and in-progress actions of RCAs not in
+	// the trash that match filter, earliest due first.
+	ListRCAActions(ctx context.Context, filter RCAActionFilter) ([]RCAAction, error)
+	GetRCAAction(ctx context.Context, id int) (RCAAction, error)
+	CreateRCAAction(ctx context.Context, action RCAAction) (int, error)
+	UpdateRCAAction(ctx context.Context, action RCAAction) error
+	// SetRCAActionStatus changes an action's status, recording when it was
+	// completed.
+	SetRCAActionStatus(ctx context.Context, id int, status string) error
+	DeleteRCAAction(ctx context.Context, id int) error
+
+	// EscalateRCAEvents flags Part 3 events of reports dated before cutoff
+	// that have no RCA, and events whose RCA has an action due before cutoff
+	// that is still open. Flags that no longer apply are cleared. It returns
+	// how many events were newly flagged.
+	EscalateRCAEvents(ctx context.Context, cutoff string) (int, error)
+	ListRCAEscalations(ctx context.Context) ([]RCAEscalation, error)
+}
+
+// RCAActionFilter selects open RCA actions. Zero values mean "no filter".
+type RCAActionFilter struct {
+	AssigneeID int
+	DueBefore  string // YYYY-MM-DD; set to today for overdue actions
+}
+
+type IncidentStore interface {
+	// ListIncidents returns the incidents with the given status, or all of
+	// them when status is empty, newest first, each with its events.
+	ListIncidents(ctx context.Context, status string) ([]Incident, error)
+	GetIncident(ctx context.Context, id int) (Incident, error)
+	CreateIncident(ctx context.Context, incident Incident, createdBy int) (int, error)
+	UpdateIncident(ctx context.Context, incident Incident) error
+	DeleteIncident(ctx context.Context, id, deletedBy int) error
+	// AttachIncidentEvent adds a Part 3 event to an incident. It returns an
+	// *eventAttachedError if the event belongs to another incident.
+	AttachIncidentEvent(ctx context.Context, incidentID, eventID int) error
+	DetachIncidentEvent(ctx context.Context, incidentID, eventID int) error
+}
+
+type SensorStore interface {
+	ListAPITokens(ctx context.Context) ([]APIToken, error)
+	// CreateAPIToken stores a new token by its SHA-256 hash.
+	CreateAPIToken(ctx context.Context, name, tokenHash string, createdBy int) (int, error)
+	RevokeAPIToken(ctx context.Context, id int) error
+	// UseAPIToken returns the unrevoked token with the given hash and records
+	// that it was used.
+	UseAPIToken(ctx context.Context, tokenHash string) (APIToken, error)
+	// RecordSensorReadings stores readings received with the given token.
+	RecordSensorReadings(ctx context.Context, tokenID int, readings []SensorReading) error
+	// LatestSensorReadings returns the most recent reading of each item
+	// recorded between from and to, inclusive.
+	LatestSensorReadings(ctx context.Context, from, to time.Time) ([]SensorReading, error)
+}
+
+type TrashStore interface {
+	// ListTrash returns deleted items of one type (see trashTypes), or of all
+	// types when itemType is empty, most recently deleted first.
+	ListTrash(ctx context.Context, itemType string) ([]TrashItem, error)
+	RestoreTrash(ctx context.Context, itemType string, id int) error
+	// PurgeTrash permanently removes items deleted before the cutoff. Users and
+	// catalog entries still referenced by a report are kept.
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+}
+
+type AuditStore interface {
+	// RecordAudit appends an entry to the audit log. Entries cannot be changed
+	// or deleted afterwards.
+	RecordAudit(ctx context.Context, entry AuditEntry) error
+	// ListAudit returns one page of entries matching filter, newest first, and
+	// the total number of matching entries.
+	ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, int, error)
+}
+
+// AuditFilter selects and pages audit log entries. Zero values mean "no
+// filter"; a zero Limit returns every entry.
+type AuditFilter struct {
+	ActorID    int
+	Action     string
+	EntityType string
+	EntityID   int
+	DateFrom   string
+	DateTo     string
+	Limit      int
+	Offset     int
+}
+
+type SearchStore interface {
+	// SearchEvents returns one page of Part 3 and Part 4 events matching the
+	// query, most relevant first, and the total number of matches.
+	SearchEvents(ctx context.Context, query SearchQuery) ([]SearchResult, int, error)
+}
+
+// SearchQuery is a full-text event search. Zero values mean "no filter".
+type SearchQuery struct {
+	Text              string
+	DateFrom          string
+	DateTo            string
+	Part              int // 3 or 4
+	SeverityID        int
+	CategoryID        int
+	AffectedServiceID int
+	Limit             int
+	Offset            int
+}
+
+// defaultSiteID is the site created by the migrations; reports saved without
+// a site belong to it.
+const defaultSiteID = 1
+
+const (
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
+)
+
+// sqlStore implements every store interface on top of database/sql. The SQL is
+// shared between PostgreSQL and SQLite ($N placeholders and RETURNING work in
+// both); driver is consulted only where the dialects differ.
+type sqlStore struct {
+	db     *sql.DB
+	driver string
+	// chainKey signs reports as they are locked.
+	chainKey ed25519.PrivateKey
+}
+
+func newPostgresStore(db *sql.DB) *sqlStore {
+	return &sqlStore{db: db, driver: driverPostgres}
+}
+
+func newSQLiteStore(db *sql.DB) *sqlStore {
+	return &sqlStore{db: db, driver: driverSQLite}
+}
+
+func newStore(db *sql.DB, driver string) (*sqlStore, error) {
+	switch driver {
+	case driverPostgres:
+		return newPostgresStore(db), nil
+	case driverSQLite:
+		return newSQLiteStore(db), nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q", driver)
+}
+
+// openDatabase opens and pings the configured database.
+func openDatabase(dbConfig DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open(dbConfig.Driver, dbConfig.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	db.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	db.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return errNotFound
+	}
+	return err
+}
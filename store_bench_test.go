@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// countingDriver wraps the SQLite driver and counts the queries sent to it.
+type countingDriver struct {
+	sqlite.Driver
+	queries atomic.Int64
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, queries: &d.queries}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+	queries *atomic.Int64
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.queries.Add(1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+var benchDriver = &countingDriver{}
+
+func init() {
+	sql.Register("sqlite-counting", benchDriver)
+}
+
+// openTestStore returns a store on a new, fully migrated SQLite database.
+func openTestStore(tb testing.TB) *sqlStore {
+	tb.Helper()
+	dsn := "file:" + filepath.Join(tb.TempDir(), "test.db") + "?_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite-counting", dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := migrateUp(db, driverSQLite); err != nil {
+		tb.Fatal(err)
+	}
+	return newSQLiteStore(db)
+}
+
+func seedReports(b *testing.B, s *sqlStore, count int) {
+	b.Helper()
+	ctx := context.Background()
+	shiftID := 1
+	for i := 0; i < count; i++ {
+		input := ReportInput{
+			ReportDate:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i).Format("2006-01-02"),
+			ShiftHoursID:    &shiftID,
+			ShiftManagerIDs: []int{1},
+			EventTitleIDs:   []int{1, 2},
+			EventsPart3:     []EventPart3{{EventSummary: "UPS alarm", StartTime: "07:00", EndTime: "07:30", RCANumber: "RCA-1"}},
+			EventsPart4:     []EventPart4{{EventSummary: "Door check", StartTime: "08:00", EndTime: "08:10"}},
+		}
+		if _, err := s.CreateReport(ctx, input, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkListReports checks that listing reports issues the same number of
+// queries no matter how many reports are returned.
+func BenchmarkListReports(b *testing.B) {
+	const wantQueries = 7 // count + reports + managers + titles + health checks + part 3 + part 4
+
+	for _, size := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("reports=%d", size), func(b *testing.B) {
+			s := openTestStore(b)
+			seedReports(b, s, size)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				before := benchDriver.queries.Load()
+				reports, _, err := s.ListReports(ctx, ReportFilter{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				queries := benchDriver.queries.Load() - before
+				if len(reports) != size {
+					b.Fatalf("got %d reports, want %d", len(reports), size)
+				}
+				if queries != wantQueries {
+					b.Fatalf("listing %d reports took %d queries, want %d", size, queries, wantQueries)
+				}
+				b.ReportMetric(float64(queries), "queries/op")
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testPostgresDSNEnv names the environment variable with a key=value DSN of a
+// PostgreSQL database the tests may create schemas in. Tests that run on both
+// drivers skip PostgreSQL without it.
+const testPostgresDSNEnv = "DAILY_REPORT_TEST_POSTGRES_DSN"
+
+// forEachDriver runs test on a new, fully migrated store of each driver.
+func forEachDriver(t *testing.T, test func(t *testing.T, s *sqlStore)) {
+	t.Run(driverSQLite, func(t *testing.T) { test(t, openTestStore(t)) })
+	t.Run(driverPostgres, func(t *testing.T) {
+		dsn := os.Getenv(testPostgresDSNEnv)
+		if dsn == "" {
+			t.Skipf("set %s to run on PostgreSQL", testPostgresDSNEnv)
+		}
+		test(t, openPostgresTestStore(t, dsn))
+	})
+}
+
+// openPostgresTestStore returns a store on a new schema of the database at
+// dsn, dropped when the test ends.
+func openPostgresTestStore(t *testing.T, dsn string) *sqlStore {
+	t.Helper()
+	admin, err := sql.Open(driverPostgres, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("daily_report_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	db, err := sql.Open(driverPostgres, dsn+" search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrateUp(db, driverPostgres); err != nil {
+		t.Fatal(err)
+	}
+	return newPostgresStore(db)
+}
+
+// openChainStore is openTestStore with a signing key, so reports can be locked.
+func openChainStore(t *testing.T) *sqlStore {
+	t.Helper()
+	s := openTestStore(t)
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.chainKey = key
+	return s
+}
+
+// testReport is a valid report for the morning shift of date.
+func testReport(date string) ReportInput {
+	shiftID := 1
+	return ReportInput{
+		ReportDate:      date,
+		ShiftHoursID:    &shiftID,
+		ShiftManagerIDs: []int{1},
+		EventTitleIDs:   []int{1},
+		HealthChecks:    []HealthCheckResult{{ItemID: 1, Status: healthOK}, {ItemID: 2, Status: healthOK}, {ItemID: 3, Status: healthOK}},
+		EventsPart4:     []EventPart4{{EventSummary: "Door check", StartTime: "08:00", EndTime: "08:10"}},
+	}
+}
+
+func createTestReport(t *testing.T, s *sqlStore, input ReportInput) int {
+	t.Helper()
+	id, err := s.CreateReport(context.Background(), input, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// setTestStatus moves a draft report to status through the workflow.
+func setTestStatus(t *testing.T, s *sqlStore, id int, status string) {
+	t.Helper()
+	steps := map[string][]string{
+		statusSubmitted: {statusSubmitted},
+		statusApproved:  {statusSubmitted, statusApproved},
+		statusRejected:  {statusSubmitted, statusRejected},
+		statusLocked:    {statusSubmitted, statusApproved, statusLocked},
+	}
+	from := statusDraft
+	for _, to := range steps[status] {
+		if err := s.SetReportStatus(context.Background(), id, from, to, "test", 1); err != nil {
+			t.Fatal(err)
+		}
+		from = to
+	}
+}
+
+func TestMergeDuplicateReports(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		source       string
+		notMergeable int // 0 for the target, 1 for the source, -1 if the merge succeeds
+	}{
+		{"drafts", statusDraft, statusDraft, -1},
+		{"rejected", statusRejected, statusDraft, -1},
+		{"submitted target", statusSubmitted, statusDraft, 0},
+		{"approved target", statusApproved, statusDraft, 0},
+		{"locked target", statusLocked, statusDraft, 0},
+		{"submitted source", statusDraft, statusSubmitted, 1},
+		{"locked source", statusRejected, statusLocked, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openChainStore(t)
+			ctx := context.Background()
+			ids := []int{
+				createTestReport(t, s, testReport("2024-03-01")),
+				createTestReport(t, s, testReport("2024-03-02")),
+			}
+			// Turn the second report into a duplicate, as the migration flags them
+			if _, err := s.db.Exec("UPDATE daily_reports SET report_date = '2024-03-01', duplicate_of = $1 WHERE id = $2", ids[0], ids[1]); err != nil {
+				t.Fatal(err)
+			}
+			setTestStatus(t, s, ids[0], tt.target)
+			setTestStatus(t, s, ids[1], tt.source)
+			before, err := s.GetReport(ctx, ids[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			merged, err := s.MergeDuplicateReports(ctx, ids[0], 1)
+
+			if tt.notMergeable >= 0 {
+				var statusErr *mergeStatusError
+				if !errors.As(err, &statusErr) || statusErr.ReportID != ids[tt.notMergeable] {
+					t.Fatalf("got merged %d, error %v; want report %d refused", merged, err, ids[tt.notMergeable])
+				}
+				after, err := s.GetReport(ctx, ids[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if after.Version != before.Version || len(after.EventsPart4) != 1 {
+					t.Errorf("refused merge changed the target: version %d, %d events", after.Version, len(after.EventsPart4))
+				}
+				if _, err := s.GetReport(ctx, ids[1]); err != nil {
+					t.Errorf("refused merge removed the source: %v", err)
+				}
+				verification, err := s.VerifyChain(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !verification.Valid {
+					t.Errorf("chain broken: %+v", verification.Problems)
+				}
+				return
+			}
+
+			if err != nil || merged != 1 {
+				t.Fatalf("got merged %d, error %v; want 1", merged, err)
+			}
+			after, err := s.GetReport(ctx, ids[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(after.EventsPart4) != 2 {
+				t.Errorf("target has %d events, want 2", len(after.EventsPart4))
+			}
+			if _, err := s.GetReport(ctx, ids[1]); err != errNotFound {
+				t.Errorf("source still readable after merge: %v", err)
+			}
+			trash, err := s.ListTrash(ctx, "report")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(trash) != 1 || trash[0].ID != ids[1] {
+				t.Errorf("trash holds %+v, want report %d", trash, ids[1])
+			}
+			revisions, err := s.ListRevisions(ctx, ids[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) == 0 {
+				t.Error("source lost its revisions")
+			}
+			var duplicate *duplicateReportError
+			if err := s.RestoreTrash(ctx, "report", ids[1]); !errors.As(err, &duplicate) || duplicate.ExistingID != ids[0] {
+				t.Errorf("restoring the merged source got %v, want a conflict with report %d", err, ids[0])
+			}
+		})
+	}
+}
+
+func TestUpdateReportKeepsEventIDs(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+	input := testReport("2024-03-01")
+	input.EventsPart3 = []EventPart3{
+		{EventSummary: "UPS alarm", StartTime: "09:00", EndTime: "09:30"},
+		{EventSummary: "Chiller fault", StartTime: "10:00"},
+	}
+	id := createTestReport(t, s, input)
+	before, err := s.GetReport(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incidentID, err := s.CreateIncident(ctx, Incident{Title: "Chiller outage", Status: incidentOpen}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AttachIncidentEvent(ctx, incidentID, before.EventsPart3[1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Edit one event, add one and remove the Part 4 event, from a form loaded
+	// before the event was attached to the incident
+	input = revisionInput(before)
+	input.EventsPart3[0].EventSummary = "UPS alarm on feed B"
+	input.EventsPart3 = append(input.EventsPart3, EventPart3{EventSummary: "Fire panel test", StartTime: "11:00"})
+	input.EventsPart4 = nil
+	if _, err := s.UpdateReport(ctx, id, input, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := s.GetReport(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.EventsPart3) != 3 || len(after.EventsPart4) != 0 {
+		t.Fatalf("got %d Part 3 and %d Part 4 events, want 3 and 0", len(after.EventsPart3), len(after.EventsPart4))
+	}
+	for i, event := range before.EventsPart3 {
+		if after.EventsPart3[i].ID != event.ID {
+			t.Errorf("%s: got ID %d, want %d", after.EventsPart3[i].EventSummary, after.EventsPart3[i].ID, event.ID)
+		}
+	}
+	if after.EventsPart3[0].EventSummary != "UPS alarm on feed B" {
+		t.Errorf("got %q, want the edited summary", after.EventsPart3[0].EventSummary)
+	}
+	if added := after.EventsPart3[2].ID; added <= before.EventsPart4[0].ID {
+		t.Errorf("added event got ID %d, want a new one", added)
+	}
+	var removed int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM report_events_part4 WHERE id = $1", before.EventsPart4[0].ID).Scan(&removed); err != nil || removed != 0 {
+		t.Errorf("removed event still stored (%v)", err)
+	}
+	incident, err := s.GetIncident(ctx, incidentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incident.Events) != 1 || incident.Events[0].EventID != before.EventsPart3[1].ID {
+		t.Errorf("got incident events %+v, want event %d", incident.Events, before.EventsPart3[1].ID)
+	}
+}
+
+func TestUpdateReportKeepsRCAEscalations(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*ReportInput)
+		flagged []string // summaries still flagged after the save
+	}{
+		{"unchanged", nil, []string{"UPS alarm", "Chiller fault"}},
+		{"other parts edited", func(input *ReportInput) {
+			input.EventsPart4[0].EventSummary = "Door and camera check"
+		}, []string{"UPS alarm", "Chiller fault"}},
+		{"events reordered and added", func(input *ReportInput) {
+			input.EventsPart3 = []EventPart3{{EventSummary: "Fire panel test"}, input.EventsPart3[1], input.EventsPart3[0]}
+		}, []string{"UPS alarm", "Chiller fault"}},
+		{"event rewritten", func(input *ReportInput) {
+			input.EventsPart3[1].EventSummary = "Chiller 2 fault"
+		}, []string{"UPS alarm", "Chiller 2 fault"}},
+		{"event removed", func(input *ReportInput) {
+			input.EventsPart3 = input.EventsPart3[1:]
+		}, []string{"Chiller fault"}},
+		{"events sent without IDs", func(input *ReportInput) {
+			for i := range input.EventsPart3 {
+				input.EventsPart3[i].ID = 0
+			}
+		}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestStore(t)
+			ctx := context.Background()
+			input := testReport("2024-03-01")
+			input.EventsPart3 = []EventPart3{
+				{EventSummary: "UPS alarm", StartTime: "09:00", EndTime: "09:30"},
+				{EventSummary: "Chiller fault", StartTime: "10:00"},
+			}
+			id := createTestReport(t, s, input)
+			if _, err := s.EscalateRCAEvents(ctx, "2024-03-10"); err != nil {
+				t.Fatal(err)
+			}
+			// Flagged well before the save, so a reset shows
+			if _, err := s.db.Exec("UPDATE rca_escalations SET flagged_at = '2024-03-10 08:00:00'"); err != nil {
+				t.Fatal(err)
+			}
+			before, err := s.ListRCAEscalations(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := s.GetReport(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input = revisionInput(report)
+			if tt.change != nil {
+				tt.change(&input)
+			}
+			if _, err := s.UpdateReport(ctx, id, input, 1, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			after, err := s.ListRCAEscalations(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, escalation := range after {
+				if escalation.FlaggedAt != before[0].FlaggedAt || escalation.Reason != escalationMissingRCA {
+					t.Errorf("%s: flagged %s for %s, want %s for %s", escalation.EventSummary,
+						escalation.FlaggedAt, escalation.Reason, before[0].FlaggedAt, escalationMissingRCA)
+				}
+				got = append(got, escalation.EventSummary)
+			}
+			sort.Strings(got)
+			want := append([]string{}, tt.flagged...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("flagged %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Deletes are soft: the row gets deleted_at and deleted_by, disappears from
+// lists and lookups, and still shows up on the reports that refer to it until
+// it is restored or purged from the trash.
+
+// trashType describes a table that supports soft delete.
+type trashType struct {
+	Table string
+	// Name is an SQL expression naming a row of t, shown in the trash.
+	Name string
+	// References are the table.column pairs that keep a row from being purged.
+	References []string
+}
+
+// trashTypeOrder lists the trash types in purge order: reports go first so
+// the users and catalog entries they used can follow.
+var trashTypeOrder = []string{
+	"report", "rca", "incident", "health_check_item", "event_title",
+	"severity", "event_category", "affected_service", "shift_hours", "site", "user",
+}
+
+// trashTypes are keyed by the type name used in the trash API.
+var trashTypes = map[string]trashType{
+	"report": {
+		Table: "daily_reports",
+		Name:  "CAST(t.report_date AS VARCHAR(10)) || COALESCE(' ' || (SELECT sh.name FROM shift_hours sh WHERE sh.id = t.shift_hours_id), '')",
+	},
+	"rca": {
+		Table:      "rcas",
+		Name:       "t.number || ' ' || t.title",
+		References: []string{"report_events_part3.rca_id"},
+	},
+	"incident": {
+		Table:      "incidents",
+		Name:       "t.title",
+		References: []string{"report_events_part3.incident_id"},
+	},
+	"health_check_item": {
+		Table:      "health_check_items",
+		Name:       "t.name",
+		References: []string{"report_health_checks.item_id"},
+	},
+	"event_title": {
+		Table:      "event_titles",
+		Name:       "t.title",
+		References: []string{"report_event_titles.event_title_id"},
+	},
+	"severity": {
+		Table:      "severities",
+		Name:       "t.name",
+		References: []string{"report_events_part3.severity_id", "report_events_part4.severity_id"},
+	},
+	"event_category": {
+		Table:      "event_categories",
+		Name:       "t.name",
+		References: []string{"report_events_part3.category_id", "report_events_part4.category_id"},
+	},
+	"affected_service": {
+		Table:      "affected_services",
+		Name:       "t.name",
+		References: []string{"report_events_part3.affected_service_id", "report_events_part4.affected_service_id"},
+	},
+	"shift_hours": {
+		Table:      "shift_hours",
+		Name:       "t.name",
+		References: []string{"daily_reports.shift_hours_id"},
+	},
+	"site": {
+		Table:      "sites",
+		Name:       "t.name",
+		References: []string{"daily_reports.site_id"},
+	},
+	"user": {
+		Table: "users",
+		Name:  "t.full_name || ' (' || t.username || ')'",
+		References: []string{
+			"daily_reports.created_by", "report_shift_managers.user_id",
+			"report_revisions.created_by", "report_status_history.changed_by",
+			"daily_reports.deleted_by", "users.deleted_by", "shift_hours.deleted_by",
+			"event_titles.deleted_by", "sites.deleted_by", "health_check_items.deleted_by",
+			"api_tokens.created_by", "rcas.owner_id", "rcas.created_by", "rcas.deleted_by",
+			"rca_actions.assignee_id", "incidents.created_by", "incidents.deleted_by",
+			"severities.deleted_by", "event_categories.deleted_by", "affected_services.deleted_by",
+		},
+	},
+}
+
+// purgeTrash periodically removes items that have been in the trash for
+// longer than retention.
+func purgeTrash(trash TrashStore, interval, retention time.Duration) {
+	for range time.Tick(interval) {
+		purged, err := trash.PurgeTrash(context.Background(), time.Now().UTC().Add(-retention))
+		if err != nil {
+			fmt.Printf("Error purging trash: %v\n", err)
+			continue
+		}
+		if purged > 0 {
+			fmt.Printf("Purged %d item(s) from the trash\n", purged)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+	used, err := s.CreateSeverity(ctx, Severity{Name: "Site down", Level: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := s.CreateSeverity(ctx, Severity{Name: "Cosmetic", Level: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	incidentID, err := s.CreateIncident(ctx, Incident{Title: "Chiller outage", Status: incidentOpen}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The kept report still refers to the used severity. The purged one has
+	// revisions, a status change and the only event of the incident.
+	kept := testReport("2024-03-01")
+	kept.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "07:00",
+		EventClassification: EventClassification{SeverityID: &used}}}
+	createTestReport(t, s, kept)
+	purged := testReport("2024-03-02")
+	purged.EventsPart3 = []EventPart3{{EventSummary: "Chiller fault", StartTime: "07:00", IncidentID: &incidentID}}
+	purgedID := createTestReport(t, s, purged)
+	if _, err := s.UpdateReport(ctx, purgedID, purged, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	setTestStatus(t, s, purgedID, statusSubmitted)
+	recentID := createTestReport(t, s, testReport("2024-03-03"))
+
+	for _, deleteItem := range []func() error{
+		func() error { return s.DeleteSeverity(ctx, used, 1) },
+		func() error { return s.DeleteSeverity(ctx, unused, 1) },
+		func() error { return s.DeleteIncident(ctx, incidentID, 1) },
+		func() error { return s.DeleteReport(ctx, purgedID, 1) },
+		func() error { return s.DeleteReport(ctx, recentID, 1) },
+	} {
+		if err := deleteItem(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Everything but the recent report was trashed a month ago
+	for _, table := range []string{"severities", "incidents", "daily_reports"} {
+		if _, err := s.db.Exec("UPDATE "+table+" SET deleted_at = '2024-01-01 00:00:00' WHERE deleted_at IS NOT NULL AND id <> $1", recentID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, table := range []string{"report_revisions", "report_status_history", "report_events_part3"} {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE report_id = $1", purgedID).Scan(&count); err != nil || count == 0 {
+			t.Fatalf("%s holds no rows of the report before the purge (%v)", table, err)
+		}
+	}
+
+	n, err := s.PurgeTrash(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The report goes first, so the incident it referred to goes with it
+	if n != 3 {
+		t.Errorf("purged %d items, want the report, the incident and the unused severity", n)
+	}
+	for _, tt := range []struct {
+		itemType string
+		want     []int
+	}{
+		{"severity", []int{used}},
+		{"incident", nil},
+		{"report", []int{recentID}},
+	} {
+		items, err := s.ListTrash(ctx, tt.itemType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, item := range items {
+			got = append(got, item.ID)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("%s trash holds %v, want %v", tt.itemType, got, tt.want)
+		}
+	}
+	for _, table := range []string{"report_shift_managers", "report_event_titles", "report_health_checks",
+		"report_events_part3", "report_events_part4", "report_revisions", "report_status_history"} {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE report_id = $1", purgedID).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%s still holds %d rows of the purged report", table, count)
+		}
+	}
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM report_revisions WHERE report_id = $1", recentID).Scan(&count); err != nil || count == 0 {
+		t.Errorf("recently trashed report lost its revisions (%v)", err)
+	}
+}
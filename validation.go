@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with one field of a request payload. Field is a
+// JSON path such as "events_part3[0].end_time" so the form can highlight it.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is the list of problems returned with 422 Unprocessable Entity.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func writeValidationErrors(w http.ResponseWriter, errs ValidationErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Validation failed",
+		"errors": errs,
+	})
+}
+
+// validateReport checks a report payload, including that every referenced
+// site, shift, user, event title, RCA, incident and event catalog entry
+// exists, and rates the health check readings, setting their status and unit.
+// Part 3 events that give an RCA number instead of an ID get the ID filled in.
+// Deleted rows are only accepted if one of the saved versions of the report
+// (the stored report, or the revision being restored) already refers to them.
+// The error is only set when the references could not be looked up.
+func validateReport(ctx context.Context, input *ReportInput, saved ...ReportInput) (ValidationErrors, error) {
+	refs, err := reportStore.ReportReferences(ctx, *input)
+	if err != nil {
+		return nil, err
+	}
+	healthItems, err := catalogStore.ListHealthCheckItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	savedItems := make(map[int]bool)
+	for _, version := range saved {
+		refs.keep(version)
+		for _, result := range version.HealthChecks {
+			savedItems[result.ItemID] = true
+		}
+	}
+
+	var errs ValidationErrors
+
+	dateValid := false
+	if input.ReportDate == "" {
+		errs.add("report_date", "report date is required")
+	} else if _, err := time.Parse("2006-01-02", input.ReportDate); err != nil {
+		errs.add("report_date", "invalid date %q, expected YYYY-MM-DD", input.ReportDate)
+	} else {
+		dateValid = true
+	}
+
+	if input.SiteID != nil {
+		errs.checkReference("site_id", "site", *input.SiteID, refs.Sites)
+	}
+
+	var shift *ShiftHours
+	shiftValid := true
+	if input.ShiftHoursID != nil {
+		shift = refs.Shift
+		shiftValid = errs.checkReference("shift_hours_id", "shift", *input.ShiftHoursID, refs.ShiftHours)
+	}
+
+	if len(input.ShiftManagerIDs) == 0 {
+		errs.add("shift_manager_ids", "at least one shift manager is required")
+	}
+	errs.checkIDs("shift_manager_ids", input.ShiftManagerIDs, refs.Users, "user")
+
+	if len(input.EventTitleIDs) == 0 {
+		errs.add("event_title_ids", "at least one event title is required")
+	}
+	errs.checkIDs("event_title_ids", input.EventTitleIDs, refs.EventTitles, "event title")
+
+	errs.checkHealthChecks(input.HealthChecks, healthItems, savedItems, input.ShiftHoursID)
+
+	// Event times can only be placed once the date and shift are known
+	var window *shiftWindow
+	if dateValid && shiftValid {
+		w, err := newShiftWindow(input.ReportDate, shift)
+		if err != nil {
+			errs.add("shift_hours_id", "%v", err)
+		} else {
+			window = &w
+		}
+	}
+
+	for i := range input.EventsPart3 {
+		event := &input.EventsPart3[i]
+		path := fmt.Sprintf("events_part3[%d]", i)
+		errs.checkEvent(path, event.EventSummary, event.StartTime, event.EndTime, window)
+		errs.checkRCA(path, event, refs)
+		if event.IncidentID != nil {
+			errs.checkReference(path+".incident_id", "incident", *event.IncidentID, refs.Incidents)
+		}
+		errs.checkClassification(path, event.EventClassification, refs)
+	}
+	for i, event := range input.EventsPart4 {
+		path := fmt.Sprintf("events_part4[%d]", i)
+		errs.checkEvent(path, event.EventSummary, event.StartTime, event.EndTime, window)
+		errs.checkClassification(path, event.EventClassification, refs)
+	}
+
+	return errs, nil
+}
+
+// checkRCA checks that the RCA of a Part 3 event exists. An event with only
+// an RCA number, as sent before RCAs had IDs, is linked to the RCA by number.
+func (e *ValidationErrors) checkRCA(path string, event *EventPart3, refs ReportReferences) {
+	event.RCANumber = strings.TrimSpace(event.RCANumber)
+	switch {
+	case event.RCAID != nil:
+		if e.checkReference(path+".rca_id", "RCA", *event.RCAID, refs.RCAs) {
+			event.RCANumber = refs.RCANumbers[*event.RCAID]
+		}
+	case event.RCANumber != "":
+		for id, number := range refs.RCANumbers {
+			if number == event.RCANumber && !refs.RCAs[id] {
+				event.RCAID = &id
+				return
+			}
+		}
+		e.add(path+".rca_number", "unknown RCA %q", event.RCANumber)
+	}
+}
+
+// checkClassification checks that the catalog entries of an event exist.
+func (e *ValidationErrors) checkClassification(path string, classification EventClassification, refs ReportReferences) {
+	if id := classification.SeverityID; id != nil {
+		e.checkReference(path+".severity_id", "severity", *id, refs.Severities)
+	}
+	if id := classification.CategoryID; id != nil {
+		e.checkReference(path+".category_id", "category", *id, refs.EventCategories)
+	}
+	if id := classification.AffectedServiceID; id != nil {
+		e.checkReference(path+".affected_service_id", "affected service", *id, refs.AffectedServices)
+	}
+}
+
+// checkReference reports an ID that has no row, or a deleted one, in rows
+// (see ReportReferences).
+func (e *ValidationErrors) checkReference(field, kind string, id int, rows map[int]bool) bool {
+	deleted, ok := rows[id]
+	switch {
+	case !ok:
+		e.add(field, "unknown %s %d", kind, id)
+	case deleted:
+		e.add(field, "%s %d has been deleted", kind, id)
+	default:
+		return true
+	}
+	return false
+}
+
+// keep accepts the deleted rows a saved version of the report refers to, so
+// that reports written before a deletion can still be saved as they are.
+func (refs ReportReferences) keep(saved ReportInput) {
+	keep := func(rows map[int]bool, id *int) {
+		if id != nil && rows[*id] {
+			rows[*id] = false
+		}
+	}
+	keepClassification := func(classification EventClassification) {
+		keep(refs.Severities, classification.SeverityID)
+		keep(refs.EventCategories, classification.CategoryID)
+		keep(refs.AffectedServices, classification.AffectedServiceID)
+	}
+
+	keep(refs.Sites, saved.SiteID)
+	keep(refs.ShiftHours, saved.ShiftHoursID)
+	for i := range saved.ShiftManagerIDs {
+		keep(refs.Users, &saved.ShiftManagerIDs[i])
+	}
+	for i := range saved.EventTitleIDs {
+		keep(refs.EventTitles, &saved.EventTitleIDs[i])
+	}
+	for _, event := range saved.EventsPart3 {
+		keep(refs.RCAs, event.RCAID)
+		keep(refs.Incidents, event.IncidentID)
+		keepClassification(event.EventClassification)
+	}
+	for _, event := range saved.EventsPart4 {
+		keepClassification(event.EventClassification)
+	}
+}
+
+// checkIDs reports IDs missing from rows and IDs listed twice.
+func (e *ValidationErrors) checkIDs(field string, ids []int, rows map[int]bool, kind string) {
+	seen := make(map[int]bool, len(ids))
+	for i, id := range ids {
+		path := fmt.Sprintf("%s[%d]", field, i)
+		if e.checkReference(path, kind, id, rows) && seen[id] {
+			e.add(path, "%s %d is listed more than once", kind, id)
+		}
+		seen[id] = true
+	}
+}
+
+// checkHealthChecks requires a result for every active item that applies to
+// the report's shift. Results for other items, e.g. ones deactivated since the
+// report was written, are accepted as long as the item exists, and results for
+// deleted items as long as the saved report has them (savedItems). Results
+// with a number or enum reading get the status the reading rates.
+func (e *ValidationErrors) checkHealthChecks(results []HealthCheckResult, items []HealthCheckItem, savedItems map[int]bool, shiftHoursID *int) {
+	known := make(map[int]HealthCheckItem, len(items))
+	for _, item := range items {
+		known[item.ID] = item
+	}
+
+	given := make(map[int]bool, len(results))
+	for i := range results {
+		result := &results[i]
+		path := fmt.Sprintf("health_checks[%d]", i)
+		item, ok := known[result.ItemID]
+		switch {
+		case !ok && savedItems[result.ItemID]:
+			// Deleted since; kept as it was saved
+		case !ok:
+			e.add(path+".item_id", "unknown health check item %d", result.ItemID)
+		case given[result.ItemID]:
+			e.add(path+".item_id", "health check item %d is listed more than once", result.ItemID)
+		}
+		given[result.ItemID] = true
+		if ok {
+			e.checkReading(path, result, item)
+		}
+		if healthSeverity(result.Status) == len(healthStatuses) {
+			e.add(path+".status", "invalid status %q, expected one of %s", result.Status, strings.Join(healthStatuses, ", "))
+		}
+	}
+
+	for _, item := range items {
+		if item.appliesTo(shiftHoursID) && !given[item.ID] {
+			e.add("health_checks", "missing result for %q", item.Name)
+		}
+	}
+}
+
+// checkReading checks that a result's reading fits the item's reading type
+// and rates it.
+func (e *ValidationErrors) checkReading(path string, result *HealthCheckResult, item HealthCheckItem) {
+	result.ReadingUnit = ""
+	if result.ReadingNumber != nil && item.ReadingType != readingNumber {
+		e.add(path+".reading_number", "%q does not take a number reading", item.Name)
+	}
+	if result.ReadingText != "" && item.ReadingType != readingEnum && item.ReadingType != readingText {
+		e.add(path+".reading_text", "%q does not take a text reading", item.Name)
+	}
+
+	if status, ok := item.rate(*result); ok {
+		result.Status = status
+	} else if item.ReadingType == readingEnum && result.ReadingText != "" {
+		values := make([]string, len(item.Options))
+		for i, option := range item.Options {
+			values[i] = option.Value
+		}
+		e.add(path+".reading_text", "invalid reading %q, expected one of %s", result.ReadingText, strings.Join(values, ", "))
+	}
+	if item.ReadingType == readingNumber && result.ReadingNumber != nil {
+		result.ReadingUnit = item.Unit
+	}
+}
+
+// validateSensorReadings checks readings sent by a device, setting the item
+// of each from its sensor key and storing its time on the site clock.
+func validateSensorReadings(readings []SensorReading, items []HealthCheckItem) ValidationErrors {
+	var errs ValidationErrors
+	switch {
+	case len(readings) == 0:
+		errs.add("readings", "at least one reading is required")
+	case len(readings) > maxSensorReadings:
+		errs.add("readings", "at most %d readings can be sent at once", maxSensorReadings)
+		return errs
+	}
+
+	bySensor := make(map[string]HealthCheckItem)
+	for _, item := range items {
+		if item.SensorKey != "" {
+			bySensor[item.SensorKey] = item
+		}
+	}
+
+	for i := range readings {
+		reading := &readings[i]
+		path := fmt.Sprintf("readings[%d]", i)
+		if reading.RecordedAt == "" {
+			errs.add(path+".recorded_at", "recorded_at is required")
+		} else if t, ok := parseRecordedAt(reading.RecordedAt); ok {
+			reading.RecordedAt = t.Format(timestampLayout)
+		} else {
+			errs.add(path+".recorded_at", "invalid time %q, expected RFC 3339", reading.RecordedAt)
+		}
+
+		item, ok := bySensor[reading.Sensor]
+		if !ok {
+			errs.add(path+".sensor", "no health check item has sensor %q", reading.Sensor)
+			continue
+		}
+		reading.ItemID = item.ID
+		if reading.ReadingNumber == nil && reading.ReadingText == "" {
+			errs.add(path, "reading_number or reading_text is required")
+			continue
+		}
+		result := HealthCheckResult{ReadingNumber: reading.ReadingNumber, ReadingText: reading.ReadingText}
+		errs.checkReading(path, &result, item)
+		reading.ReadingUnit = result.ReadingUnit
+	}
+	return errs
+}
+
+func (e *ValidationErrors) checkEvent(path, summary, start, end string, window *shiftWindow) {
+	if strings.TrimSpace(summary) == "" {
+		e.add(path+".event_summary", "event summary is required")
+	}
+
+	startAt, startOK := e.checkEventTime(path+".start_time", start, window, true)
+	endAt, endOK := e.checkEventTime(path+".end_time", end, window, false)
+	if startOK && endOK && endAt.Before(startAt) {
+		e.add(path+".end_time", "end time %s is before start time %s",
+			endAt.Format("2006-01-02 15:04"), startAt.Format("2006-01-02 15:04"))
+	}
+}
+
+// checkEventTime validates one event time and, when the shift window is
+// known, that it lies within it. With carried, a full timestamp may be before
+// the window: the start of an event that carries on from an earlier shift.
+func (e *ValidationErrors) checkEventTime(field, value string, window *shiftWindow, carried bool) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if window == nil {
+		// Only the format can be checked
+		if _, err := (shiftWindow{}).eventTime(value); err != nil {
+			e.add(field, "%v", err)
+		}
+		return time.Time{}, false
+	}
+
+	t, err := window.eventTime(value)
+	if err != nil {
+		e.add(field, "%v", err)
+		return t, false
+	}
+	_, clockErr := clockOffset(value)
+	if (t.Before(window.Start) && !(carried && clockErr != nil)) || t.After(window.End) {
+		e.add(field, "%s is outside the shift window %s to %s", t.Format("2006-01-02 15:04"),
+			window.Start.Format("2006-01-02 15:04"), window.End.Format("2006-01-02 15:04"))
+	}
+	return t, true
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestValidateReport(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	unknown := 99
+
+	tests := []struct {
+		name   string
+		change func(*ReportInput)
+		want   []string
+	}{
+		{"valid", nil, nil},
+		{"missing date", func(input *ReportInput) { input.ReportDate = "" }, []string{"report_date: report date is required"}},
+		{"invalid date", func(input *ReportInput) { input.ReportDate = "01/03/2024" }, []string{`report_date: invalid date "01/03/2024", expected YYYY-MM-DD`}},
+		{"unknown shift", func(input *ReportInput) { input.ShiftHoursID = &unknown }, []string{"shift_hours_id: unknown shift 99"}},
+		{"no shift managers or titles", func(input *ReportInput) { input.ShiftManagerIDs, input.EventTitleIDs = nil, nil }, []string{
+			"shift_manager_ids: at least one shift manager is required",
+			"event_title_ids: at least one event title is required",
+		}},
+		{"repeated title", func(input *ReportInput) { input.EventTitleIDs = []int{1, 1} }, []string{"event_title_ids[1]: event title 1 is listed more than once"}},
+		{"missing summary", func(input *ReportInput) { input.EventsPart4[0].EventSummary = " " }, []string{"events_part4[0].event_summary: event summary is required"}},
+		{"end before start", func(input *ReportInput) { input.EventsPart4[0].EndTime = "07:50" }, []string{
+			"events_part4[0].end_time: end time 2024-03-01 07:50 is before start time 2024-03-01 08:00",
+		}},
+		{"outside the shift", func(input *ReportInput) {
+			input.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "15:00"}}
+		}, []string{"events_part3[0].start_time: 2024-03-01 15:00 is outside the shift window 2024-03-01 06:00 to 2024-03-01 14:00"}},
+		{"before the shift", func(input *ReportInput) {
+			input.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "05:00"}}
+		}, []string{"events_part3[0].start_time: 2024-03-01 05:00 is outside the shift window 2024-03-01 06:00 to 2024-03-01 14:00"}},
+		{"carried on from an earlier shift", func(input *ReportInput) {
+			input.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "2024-02-29T23:00:00Z", EndTime: "07:00"}}
+		}, nil},
+		{"ended before the shift", func(input *ReportInput) {
+			input.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "2024-02-29 23:00:00", EndTime: "2024-02-29 23:30:00"}}
+		}, []string{"events_part3[0].end_time: 2024-02-29 23:30 is outside the shift window 2024-03-01 06:00 to 2024-03-01 14:00"}},
+		{"invalid time", func(input *ReportInput) { input.EventsPart4[0].StartTime = "8am" }, []string{`events_part4[0].start_time: invalid time "8am", expected HH:MM`}},
+		{"unknown incident", func(input *ReportInput) {
+			input.EventsPart3 = []EventPart3{{EventSummary: "UPS alarm", StartTime: "07:00", IncidentID: &unknown}}
+		}, []string{"events_part3[0].incident_id: unknown incident 99"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := testReport("2024-03-01")
+			if tt.change != nil {
+				tt.change(&input)
+			}
+			errs, err := validateReport(context.Background(), &input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, fieldErr := range errs {
+				got = append(got, fieldErr.Field+": "+fieldErr.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateReportHandlerValidationErrors(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	input := testReport("2024-03-01")
+	input.EventsPart4[0].EndTime = "07:50"
+
+	w := httptest.NewRecorder()
+	createReportHandler(w, testRequest(t, http.MethodPost, input, sessionUser{ID: 1, Role: "user"}, nil))
+
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusUnprocessableEntity || len(body.Errors) != 1 || body.Errors[0].Field != "events_part4[0].end_time" {
+		t.Errorf("got %d with %+v, want 422 naming events_part4[0].end_time", w.Code, body.Errors)
+	}
+	if reports, _, err := s.ListReports(context.Background(), ReportFilter{}); err != nil || len(reports) != 0 {
+		t.Errorf("got %d reports (%v), want none saved", len(reports), err)
+	}
+}
+
+func TestValidateReportDeletedReferences(t *testing.T) {
+	s := openTestStore(t)
+	useTestStore(s)
+	ctx := context.Background()
+
+	userID, err := s.CreateUser(ctx, User{Username: "operator", FullName: "Operator", Role: "user"}, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const titleID, severityID, itemID = 2, 4, 3
+	deletes := []error{
+		s.DeleteUser(ctx, userID, 1),
+		s.DeleteEventTitle(ctx, titleID, 1),
+		s.DeleteSeverity(ctx, severityID, 1),
+		s.DeleteHealthCheckItem(ctx, itemID, 1),
+	}
+	for _, err := range deletes {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// report refers to every row deleted above
+	report := func(change func(*ReportInput)) ReportInput {
+		input := testReport("2024-03-01")
+		input.ShiftManagerIDs = []int{1, userID}
+		input.EventTitleIDs = []int{1, titleID}
+		severity := severityID
+		input.EventsPart4[0].SeverityID = &severity
+		if change != nil {
+			change(&input)
+		}
+		return input
+	}
+	withoutDeleted := func(input *ReportInput) {
+		input.ShiftManagerIDs, input.EventTitleIDs = []int{1}, []int{1}
+		input.EventsPart4[0].SeverityID = nil
+		input.HealthChecks = input.HealthChecks[:2]
+	}
+
+	tests := []struct {
+		name  string
+		input ReportInput
+		saved []ReportInput
+		want  []string
+	}{
+		{
+			name:  "new report",
+			input: report(nil),
+			want: []string{
+				"shift_manager_ids[1]: user 2 has been deleted",
+				"event_title_ids[1]: event title 2 has been deleted",
+				"health_checks[2].item_id: unknown health check item 3",
+				"events_part4[0].severity_id: severity 4 has been deleted",
+			},
+		},
+		{
+			name:  "saved report",
+			input: report(nil),
+			saved: []ReportInput{report(nil)},
+		},
+		{
+			name:  "restored revision",
+			input: report(nil),
+			saved: []ReportInput{report(withoutDeleted), report(nil)},
+		},
+		{
+			name:  "added to saved report",
+			input: report(func(input *ReportInput) { input.HealthChecks = input.HealthChecks[:2] }),
+			saved: []ReportInput{report(withoutDeleted)},
+			want: []string{
+				"shift_manager_ids[1]: user 2 has been deleted",
+				"event_title_ids[1]: event title 2 has been deleted",
+				"events_part4[0].severity_id: severity 4 has been deleted",
+			},
+		},
+		{
+			name:  "unknown",
+			input: report(func(input *ReportInput) { input.ShiftManagerIDs = []int{1, 99} }),
+			saved: []ReportInput{report(nil)},
+			want:  []string{"shift_manager_ids[1]: unknown user 99"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := validateReport(ctx, &tt.input, tt.saved...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, fieldErr := range errs {
+				got = append(got, fieldErr.Field+": "+fieldErr.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSensorReadingsRecordedAt(t *testing.T) {
+	useTestSiteZone(t, tehran)
+	items := []HealthCheckItem{{ID: 2, SensorKey: "dc1-room-temp", ReadingType: readingNumber}}
+	tests := []struct {
+		recordedAt string
+		want       string // on the site clock
+	}{
+		{"2024-03-11T23:15:00+03:30", "2024-03-11 23:15:00"},
+		{"2024-03-11T19:45:00Z", "2024-03-11 23:15:00"},
+		{"2024-03-11T22:30:00Z", "2024-03-12 02:00:00"},
+		{"2024-03-11T14:45:00-05:00", "2024-03-11 23:15:00"},
+		{"2024-03-11T23:15:00", "2024-03-11 23:15:00"},
+		{"2024-03-11 23:15:00", "2024-03-11 23:15:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recordedAt, func(t *testing.T) {
+			number := 21.5
+			readings := []SensorReading{{Sensor: "dc1-room-temp", RecordedAt: tt.recordedAt, ReadingNumber: &number}}
+			if errs := validateSensorReadings(readings, items); len(errs) > 0 {
+				t.Fatalf("got errors %+v", errs)
+			}
+			if readings[0].RecordedAt != tt.want {
+				t.Errorf("got %s, want %s", readings[0].RecordedAt, tt.want)
+			}
+		})
+	}
+}
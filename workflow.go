@@ -0,0 +1,63 @@
+package main
+
+import "fmt"
+
+// Report lifecycle: draft → submitted → approved/rejected → locked.
+const (
+	statusDraft     = "draft"
+	statusSubmitted = "submitted"
+	statusApproved  = "approved"
+	statusRejected  = "rejected"
+	statusLocked    = "locked"
+)
+
+var reportStatuses = []string{statusDraft, statusSubmitted, statusApproved, statusRejected, statusLocked}
+
+// reportTransition is an allowed status change. The report's author may make
+// it unless it is adminOnly; admins may make every transition.
+type reportTransition struct {
+	From            string
+	To              string
+	AdminOnly       bool
+	CommentRequired bool
+}
+
+var reportTransitions = []reportTransition{
+	{From: statusDraft, To: statusSubmitted},
+	{From: statusSubmitted, To: statusDraft}, // withdraw for further edits
+	{From: statusSubmitted, To: statusApproved, AdminOnly: true},
+	{From: statusSubmitted, To: statusRejected, AdminOnly: true, CommentRequired: true},
+	{From: statusRejected, To: statusSubmitted},
+	{From: statusApproved, To: statusLocked, AdminOnly: true},
+	{From: statusRejected, To: statusLocked, AdminOnly: true},
+}
+
+func findTransition(from, to string) (reportTransition, bool) {
+	for _, t := range reportTransitions {
+		if t.From == from && t.To == to {
+			return t, true
+		}
+	}
+	return reportTransition{}, false
+}
+
+// canTransition reports whether user may make t on a report created by ownerID.
+func canTransition(user sessionUser, ownerID int, t reportTransition) bool {
+	if user.Role == "admin" {
+		return true
+	}
+	return !t.AdminOnly && user.ID == ownerID
+}
+
+// checkReportEditable returns why a report in the given status cannot be
+// edited or deleted by user, or nil. Authors may change draft and rejected
+// reports; admins may change anything that is not locked.
+func checkReportEditable(user sessionUser, status string) error {
+	if status == statusLocked {
+		return errReportLocked
+	}
+	if user.Role != "admin" && status != statusDraft && status != statusRejected {
+		return fmt.Errorf("only draft or rejected reports can be changed, this report is %s", status)
+	}
+	return nil
+}
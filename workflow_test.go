@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestChangeReportStatus(t *testing.T) {
+	admin := sessionUser{ID: 1, Username: "admin", Role: "admin"}
+	author := sessionUser{ID: 1, Username: "admin", Role: "user"}
+	other := sessionUser{ID: 2, Username: "operator", Role: "user"}
+
+	tests := []struct {
+		from    string
+		to      string
+		user    sessionUser
+		comment string
+		want    int
+	}{
+		{statusDraft, statusSubmitted, author, "", http.StatusOK},
+		{statusDraft, statusSubmitted, other, "", http.StatusForbidden},
+		{statusDraft, statusSubmitted, admin, "", http.StatusOK},
+		{statusSubmitted, statusDraft, author, "", http.StatusOK},
+		{statusSubmitted, statusDraft, other, "", http.StatusForbidden},
+		{statusSubmitted, statusApproved, author, "", http.StatusForbidden},
+		{statusSubmitted, statusApproved, admin, "", http.StatusOK},
+		{statusSubmitted, statusRejected, author, "missing alarms", http.StatusForbidden},
+		{statusSubmitted, statusRejected, admin, "missing alarms", http.StatusOK},
+		{statusSubmitted, statusRejected, admin, "  ", http.StatusUnprocessableEntity},
+		{statusRejected, statusSubmitted, author, "", http.StatusOK},
+		{statusRejected, statusSubmitted, other, "", http.StatusForbidden},
+		{statusApproved, statusLocked, author, "", http.StatusForbidden},
+		{statusApproved, statusLocked, admin, "", http.StatusOK},
+		{statusRejected, statusLocked, author, "", http.StatusForbidden},
+		{statusRejected, statusLocked, admin, "", http.StatusOK},
+		// Not a transition, whoever asks
+		{statusDraft, statusApproved, admin, "", http.StatusConflict},
+		{statusDraft, statusDraft, admin, "", http.StatusConflict},
+		{statusApproved, statusDraft, admin, "", http.StatusConflict},
+		{statusApproved, statusRejected, admin, "too late", http.StatusConflict},
+		{statusDraft, "archived", admin, "", http.StatusConflict},
+		{statusLocked, statusDraft, admin, "", http.StatusConflict},
+		{statusLocked, statusSubmitted, author, "", http.StatusConflict},
+		{statusLocked, statusApproved, admin, "", http.StatusConflict},
+		{statusLocked, statusRejected, admin, "reopen", http.StatusConflict},
+		{statusLocked, statusLocked, admin, "", http.StatusConflict},
+	}
+
+	s := openChainStore(t)
+	useTestStore(s)
+	if _, err := s.db.Exec("INSERT INTO users (username, password, full_name, role) VALUES ('operator', '', 'Operator', 'user')"); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s to %s by %s %s", tt.from, tt.to, tt.user.Username, tt.user.Role), func(t *testing.T) {
+			id := createTestReport(t, s, testReport(fmt.Sprintf("2024-03-%02d", i+1)))
+			setTestStatus(t, s, id, tt.from)
+
+			w := httptest.NewRecorder()
+			changeReportStatusHandler(w, testRequest(t, http.MethodPost, map[string]string{"status": tt.to, "comment": tt.comment},
+				tt.user, map[string]string{"id": strconv.Itoa(id)}))
+			if w.Code != tt.want {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tt.want)
+			}
+
+			status, err := s.ReportStatus(context.Background(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.from
+			if tt.want == http.StatusOK {
+				want = tt.to
+			}
+			if status != want {
+				t.Errorf("report is %s, want %s", status, want)
+			}
+		})
+	}
+}